@@ -0,0 +1,74 @@
+// Copyright 2025 Christophe Eymard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/ceymard/pgrel/pg"
+)
+
+// pgrel diff [-json] [options] <old> <new>
+//
+// Both sides are either snapshot files or connection strings. The exit code is 1 when there are breaking changes,
+// so that it can be used to stop a CI pipeline.
+func runDiff(args []string) int {
+	var flags = flag.NewFlagSet("diff", flag.ExitOnError)
+	var as_json = flags.Bool("json", false, "output the changes as JSON")
+	var opts = optionsFlags(flags)
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: pgrel diff [-json] [options] <old snapshot or uri> <new snapshot or uri>")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return 2
+	}
+
+	var catalogs [2]*pg.DbInfos
+	for i, src := range flags.Args() {
+		infos, err := loadCatalog(src, *opts)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Failed to load", src+":", err)
+			printStackTrace(err)
+			return 2
+		}
+		defer closeCatalog(infos)
+		catalogs[i] = infos
+	}
+
+	diff := pg.Diff(catalogs[0], catalogs[1])
+
+	if *as_json {
+		var enc = json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(diff); err != nil {
+			fmt.Fprintln(os.Stderr, "Failed to encode the diff:", err)
+			return 2
+		}
+	} else {
+		fmt.Print(diff.Summary())
+	}
+
+	if diff.HasBreakingChanges() {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,51 @@
+// Copyright 2025 Christophe Eymard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"strings"
+
+	"github.com/ceymard/pgrel/pg"
+)
+
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+// Lists can be given as comma separated values, by repeating the flag, or both.
+func (l *listFlag) Set(value string) error {
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			*l = append(*l, v)
+		}
+	}
+	return nil
+}
+
+// Register the flags that restrict what is loaded from the catalog
+func optionsFlags(flags *flag.FlagSet) *pg.Options {
+	var opts = &pg.Options{}
+	flags.Var((*listFlag)(&opts.Schemas), "schemas", "schemas to load, all the non-system ones by default")
+	flags.Var((*listFlag)(&opts.ExcludeSchemas), "exclude-schemas", "schemas not to load")
+	flags.Var((*listFlag)(&opts.Relations), "relations", "glob patterns of the schema.relation to load")
+	flags.Var((*listFlag)(&opts.ExcludeRelations), "exclude-relations", "glob patterns of the schema.relation not to load")
+	flags.Var((*listFlag)(&opts.Functions), "functions", "glob patterns of the schema.function to load")
+	flags.Var((*listFlag)(&opts.ExcludeFunctions), "exclude-functions", "glob patterns of the schema.function not to load")
+	flags.Var((*listFlag)(&opts.ExposedSchemas), "expose", "schemas that are published, in search_path order")
+	return opts
+}
@@ -0,0 +1,89 @@
+// Copyright 2025 Christophe Eymard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/ceymard/pgrel/pg"
+	"gitlab.com/tozd/go/errors"
+)
+
+// Load a catalog from a snapshot file if src is one, or from the database it is the connection string of.
+// A snapshot was filtered when it was taken, so asking to filter it again is an error. The exposed schemas
+// still apply to it. The caller has to close the Pool of the result when it comes from a database.
+func loadCatalog(src string, opts pg.Options) (*pg.DbInfos, error) {
+	if st, err := os.Stat(src); err == nil && !st.IsDir() {
+		if hasFilters(opts) {
+			return nil, errors.Errorf("%s is a snapshot, it can't be filtered with -schemas, -relations, -functions or their -exclude variants", src)
+		}
+		infos, err := pg.LoadInfos(src)
+		if err != nil {
+			return nil, err
+		}
+		if len(opts.ExposedSchemas) > 0 {
+			infos.Options.ExposedSchemas = opts.ExposedSchemas
+		}
+		return infos, nil
+	}
+	return pg.NewInfos(src, opts)
+}
+
+func hasFilters(opts pg.Options) bool {
+	return len(opts.Schemas) > 0 || len(opts.ExcludeSchemas) > 0 ||
+		len(opts.Relations) > 0 || len(opts.ExcludeRelations) > 0 ||
+		len(opts.Functions) > 0 || len(opts.ExcludeFunctions) > 0
+}
+
+func closeCatalog(infos *pg.DbInfos) {
+	if infos.Pool != nil {
+		infos.Pool.Close()
+	}
+}
+
+// pgrel snapshot [options] <uri> <file>
+func runSnapshot(args []string) int {
+	var flags = flag.NewFlagSet("snapshot", flag.ExitOnError)
+	var opts = optionsFlags(flags)
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: pgrel snapshot [options] <uri> <file>")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return 2
+	}
+	args = flags.Args()
+
+	infos, err := pg.NewInfos(args[0], *opts)
+	if err != nil {
+		fmt.Println("Failed to create db:", err)
+		printStackTrace(err)
+		return 1
+	}
+	defer closeCatalog(infos)
+
+	if err := infos.Save(args[1]); err != nil {
+		fmt.Println("Failed to save snapshot:", err)
+		printStackTrace(err)
+		return 1
+	}
+
+	return 0
+}
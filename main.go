@@ -15,28 +15,39 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
 
-	"github.com/ceymard/pgrel/pg"
 	"github.com/k0kubun/pp"
 )
 
 func main() {
-	var srv string
 	if len(os.Args) > 1 {
-		srv = os.Args[1]
+		switch os.Args[1] {
+		case "diff":
+			os.Exit(runDiff(os.Args[2:]))
+		case "snapshot":
+			os.Exit(runSnapshot(os.Args[2:]))
+		}
+	}
+
+	var flags = flag.NewFlagSet("pgrel", flag.ExitOnError)
+	var opts = optionsFlags(flags)
+	flags.Parse(os.Args[1:])
+
+	// Only the relations of the api schema are printed, unless told otherwise
+	if len(opts.ExposedSchemas) == 0 {
+		opts.ExposedSchemas = []string{"api"}
 	}
 
-	if infos, err := pg.NewInfos(srv); err != nil {
+	if infos, err := loadCatalog(flags.Arg(0), *opts); err != nil {
 		fmt.Println("Failed to create db:", err)
 		printStackTrace(err)
 		return
 	} else {
-		for _, r := range infos.Relations {
-			if r.Identifier.Schema != "api" {
-				continue
-			}
+		defer closeCatalog(infos)
+		for _, r := range infos.ExposedRelations() {
 			pp.Println(r)
 		}
 	}
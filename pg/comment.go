@@ -0,0 +1,96 @@
+// Copyright 2025 Christophe Eymard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+)
+
+// Split a COMMENT ON text into its description and its annotations.
+//
+// Annotations are given in a front-matter block at the very beginning of the comment, between two "---" lines,
+// either as flat YAML-like "key: value" lines or as a JSON object.
+//
+//	---
+//	hidden: true
+//	label: Customer orders
+//	---
+//	The orders placed by customers.
+//
+// Comments without front-matter are left untouched, even when they start with a brace.
+func parseComment(comment string) (string, map[string]string) {
+	var trimmed = strings.TrimLeft(comment, " \t\r\n")
+
+	lines := strings.Split(trimmed, "\n")
+	if strings.TrimSpace(lines[0]) != "---" {
+		return comment, nil
+	}
+
+	for i, line := range lines[1:] {
+		if strings.TrimSpace(line) == "---" {
+			var annotations, ok = parseFrontMatter(strings.TrimSpace(strings.Join(lines[1:i+1], "\n")))
+			if !ok {
+				return comment, nil
+			}
+			return strings.TrimSpace(strings.Join(lines[i+2:], "\n")), annotations
+		}
+	}
+
+	// The block was never closed, so this is not front-matter
+	return comment, nil
+}
+
+// The annotations of a front-matter block, which is either a JSON object or "key: value" lines.
+func parseFrontMatter(block string) (map[string]string, bool) {
+	if strings.HasPrefix(block, "{") {
+		var values map[string]json.RawMessage
+		if err := json.Unmarshal([]byte(block), &values); err != nil {
+			return nil, false
+		}
+
+		var annotations = make(map[string]string, len(values))
+		for k, v := range values {
+			var str string
+			if err := json.Unmarshal(v, &str); err == nil {
+				annotations[k] = str
+			} else {
+				annotations[k] = string(bytes.TrimSpace(v))
+			}
+		}
+		return annotations, true
+	}
+
+	var annotations = make(map[string]string)
+	for _, line := range strings.Split(block, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, false
+		}
+
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		annotations[strings.TrimSpace(key)] = value
+	}
+	return annotations, true
+}
@@ -0,0 +1,59 @@
+// Copyright 2025 Christophe Eymard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+
+package pg
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseComment(t *testing.T) {
+	var tests = []struct {
+		comment     string
+		description string
+		annotations map[string]string
+	}{
+		// Plain comments
+		{"The orders placed by customers.", "The orders placed by customers.", nil},
+		{"", "", nil},
+		{`{"hidden": true} is not front-matter`, `{"hidden": true} is not front-matter`, nil},
+		{"--- not a block", "--- not a block", nil},
+
+		// YAML-like front-matter
+		{"---\nhidden: true\nlabel: Customer orders\n---\nThe orders.", "The orders.", map[string]string{"hidden": "true", "label": "Customer orders"}},
+		{"\n  ---\n# a comment\n\nlabel: 'a: b'\n---\n", "", map[string]string{"label": "a: b"}},
+		{"---\nnot an annotation\n---\nThe orders.", "---\nnot an annotation\n---\nThe orders.", nil},
+
+		// JSON front-matter
+		{"---\n{\"hidden\": true, \"label\": \"Customer orders\", \"order\": [1, 2]}\n---\nThe orders.", "The orders.", map[string]string{"hidden": "true", "label": "Customer orders", "order": "[1, 2]"}},
+		{"---\n{\n  \"label\": \"x\"\n}\n---", "", map[string]string{"label": "x"}},
+		{"---\n{\"hidden\": }\n---\nThe orders.", "---\n{\"hidden\": }\n---\nThe orders.", nil},
+
+		// Unclosed front-matter
+		{"---\nhidden: true\nThe orders.", "---\nhidden: true\nThe orders.", nil},
+		{"---\n{\"hidden\": true}\nThe orders.", "---\n{\"hidden\": true}\nThe orders.", nil},
+	}
+
+	for _, test := range tests {
+		var description, annotations = parseComment(test.comment)
+		if description != test.description {
+			t.Errorf("%q: got description %q, expected %q", test.comment, description, test.description)
+		}
+		if !reflect.DeepEqual(annotations, test.annotations) {
+			t.Errorf("%q: got annotations %v, expected %v", test.comment, annotations, test.annotations)
+		}
+	}
+}
@@ -0,0 +1,415 @@
+// Copyright 2025 Christophe Eymard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+const (
+	CHANGE_ADDED   = "added"
+	CHANGE_REMOVED = "removed"
+	CHANGE_CHANGED = "changed"
+)
+
+const (
+	OBJECT_RELATION    = "relation"
+	OBJECT_COLUMN      = "column"
+	OBJECT_FOREIGN_KEY = "foreign key"
+	OBJECT_FUNCTION    = "function"
+	OBJECT_TYPE        = "type"
+)
+
+// A single difference between two catalogs
+type Change struct {
+	Kind       string // One of the CHANGE_* constants
+	ObjectType string // One of the OBJECT_* constants
+	Object     string // The name of the object, qualified by its relation for columns and foreign keys
+	Detail     string `json:",omitempty"` // What changed, for CHANGE_CHANGED
+	Before     string `json:",omitempty"`
+	After      string `json:",omitempty"`
+	IsBreaking bool   // Clients of the old schema may fail with the new one
+}
+
+func (c Change) String() string {
+	var sb strings.Builder
+	if c.IsBreaking {
+		sb.WriteString("[breaking] ")
+	} else {
+		sb.WriteString("[additive] ")
+	}
+	fmt.Fprintf(&sb, "%s %s %s", c.ObjectType, c.Object, c.Kind)
+	if c.Detail != "" {
+		fmt.Fprintf(&sb, ": %s", c.Detail)
+	}
+	switch {
+	case c.Before != "" && c.After != "":
+		fmt.Fprintf(&sb, " (%s -> %s)", c.Before, c.After)
+	case c.Before != "":
+		fmt.Fprintf(&sb, " (%s)", c.Before)
+	case c.After != "":
+		fmt.Fprintf(&sb, " (%s)", c.After)
+	}
+	return sb.String()
+}
+
+type SchemaDiff struct {
+	Changes []Change
+}
+
+func (d *SchemaDiff) HasBreakingChanges() bool {
+	for _, c := range d.Changes {
+		if c.IsBreaking {
+			return true
+		}
+	}
+	return false
+}
+
+// A human readable report, breaking changes first.
+func (d *SchemaDiff) Summary() string {
+	if len(d.Changes) == 0 {
+		return "no changes\n"
+	}
+
+	var sb strings.Builder
+	var breaking = 0
+	for _, pass := range []bool{true, false} {
+		for _, c := range d.Changes {
+			if c.IsBreaking == pass {
+				sb.WriteString(c.String())
+				sb.WriteByte('\n')
+				if pass {
+					breaking++
+				}
+			}
+		}
+	}
+	fmt.Fprintf(&sb, "%d changes, %d breaking\n", len(d.Changes), breaking)
+	return sb.String()
+}
+
+// Compare two catalogs and list what was added, removed or changed in the relations, their columns and foreign keys,
+// the functions and the user defined types.
+func Diff(old, new *DbInfos) *SchemaDiff {
+	var d = &SchemaDiff{}
+
+	diffRelations(d, old, new)
+	diffFunctions(d, old, new)
+	diffTypes(d, old, new)
+
+	sort.SliceStable(d.Changes, func(i, j int) bool {
+		return d.Changes[i].Object < d.Changes[j].Object
+	})
+
+	return d
+}
+
+func (d *SchemaDiff) add(c Change) {
+	d.Changes = append(d.Changes, c)
+}
+
+func typeName(t *Type) string {
+	if t == nil {
+		return "?"
+	}
+	if t.IsArray() {
+		return typeName(t.ElementType) + "[]"
+	}
+	return t.PgIdentifier.String()
+}
+
+func diffRelations(d *SchemaDiff, old, new *DbInfos) {
+	var old_rels = make(map[string]*Relation, len(old.Relations))
+	for _, r := range old.Relations {
+		old_rels[r.Identifier.String()] = r
+	}
+
+	var new_rels = make(map[string]*Relation, len(new.Relations))
+	for _, r := range new.Relations {
+		new_rels[r.Identifier.String()] = r
+	}
+
+	for name := range old_rels {
+		if _, ok := new_rels[name]; !ok {
+			d.add(Change{Kind: CHANGE_REMOVED, ObjectType: OBJECT_RELATION, Object: name, IsBreaking: true})
+		}
+	}
+
+	for name, nr := range new_rels {
+		or, ok := old_rels[name]
+		if !ok {
+			d.add(Change{Kind: CHANGE_ADDED, ObjectType: OBJECT_RELATION, Object: name})
+			continue
+		}
+
+		if or.Kind != nr.Kind {
+			d.add(Change{Kind: CHANGE_CHANGED, ObjectType: OBJECT_RELATION, Object: name, Detail: "kind", Before: or.Kind, After: nr.Kind, IsBreaking: true})
+		}
+
+		diffColumns(d, name, or, nr)
+		diffForeignKeys(d, name, or, nr)
+	}
+}
+
+func diffColumns(d *SchemaDiff, rel_name string, or, nr *Relation) {
+	for _, oc := range or.Columns {
+		if _, ok := nr.ColumnsMap[oc.Name]; !ok {
+			d.add(Change{Kind: CHANGE_REMOVED, ObjectType: OBJECT_COLUMN, Object: rel_name + "." + oc.Name, IsBreaking: true})
+		}
+	}
+
+	for _, nc := range nr.Columns {
+		var name = rel_name + "." + nc.Name
+
+		oc, ok := or.ColumnsMap[nc.Name]
+		if !ok {
+			// A mandatory column breaks the inserts that do not know about it
+			var mandatory = nr.IsTable() && nc.IsNotNull && nc.DefaultExpression == "" && !nc.IsIdentity && !nc.IsGenerated
+			d.add(Change{Kind: CHANGE_ADDED, ObjectType: OBJECT_COLUMN, Object: name, IsBreaking: mandatory})
+			continue
+		}
+
+		if typeName(oc.Type) != typeName(nc.Type) {
+			d.add(Change{Kind: CHANGE_CHANGED, ObjectType: OBJECT_COLUMN, Object: name, Detail: "type", Before: typeName(oc.Type), After: typeName(nc.Type), IsBreaking: true})
+		}
+
+		if oc.IsNotNull != nc.IsNotNull {
+			// Either inserts may start failing, or readers may start receiving nulls
+			var before, after = nullability(oc), nullability(nc)
+			d.add(Change{Kind: CHANGE_CHANGED, ObjectType: OBJECT_COLUMN, Object: name, Detail: "nullability", Before: before, After: after, IsBreaking: true})
+		}
+
+		if oc.DefaultExpression != nc.DefaultExpression {
+			d.add(Change{Kind: CHANGE_CHANGED, ObjectType: OBJECT_COLUMN, Object: name, Detail: "default", Before: oc.DefaultExpression, After: nc.DefaultExpression, IsBreaking: nc.DefaultExpression == "" && nc.IsNotNull})
+		}
+	}
+}
+
+func nullability(c *Column) string {
+	if c.IsNotNull {
+		return "NOT NULL"
+	}
+	return "NULL"
+}
+
+func diffForeignKeys(d *SchemaDiff, rel_name string, or, nr *Relation) {
+	var describe = func(fk *OutgoingForeignKey) string {
+		return fmt.Sprintf("(%s) -> %s(%s)", strings.Join(fk.SelfColumnNames, ", "), fk.OtherRelation.Identifier.String(), strings.Join(fk.OtherColumnNames, ", "))
+	}
+
+	var find = func(fks []*OutgoingForeignKey, fk *OutgoingForeignKey) *OutgoingForeignKey {
+		for _, other := range fks {
+			if other.Identifier.Name == fk.Identifier.Name && other.OtherRelation.Identifier.String() == fk.OtherRelation.Identifier.String() {
+				return other
+			}
+		}
+		return nil
+	}
+
+	for _, ofk := range or.OutgoingForeignKeys {
+		if find(nr.OutgoingForeignKeys, ofk) == nil {
+			d.add(Change{Kind: CHANGE_REMOVED, ObjectType: OBJECT_FOREIGN_KEY, Object: rel_name + "." + ofk.Identifier.Name, Before: describe(ofk), IsBreaking: true})
+		}
+	}
+
+	for _, nfk := range nr.OutgoingForeignKeys {
+		var name = rel_name + "." + nfk.Identifier.Name
+		ofk := find(or.OutgoingForeignKeys, nfk)
+		if ofk == nil {
+			d.add(Change{Kind: CHANGE_ADDED, ObjectType: OBJECT_FOREIGN_KEY, Object: name, After: describe(nfk)})
+			continue
+		}
+
+		if !slices.Equal(ofk.SelfColumnNames, nfk.SelfColumnNames) || !slices.Equal(ofk.OtherColumnNames, nfk.OtherColumnNames) {
+			d.add(Change{Kind: CHANGE_CHANGED, ObjectType: OBJECT_FOREIGN_KEY, Object: name, Detail: "columns", Before: describe(ofk), After: describe(nfk), IsBreaking: true})
+		}
+
+		if ofk.SelfIsUnique != nfk.SelfIsUnique {
+			// Embedding goes from one row to many or the other way around
+			d.add(Change{Kind: CHANGE_CHANGED, ObjectType: OBJECT_FOREIGN_KEY, Object: name, Detail: "uniqueness", Before: fmt.Sprint(ofk.SelfIsUnique), After: fmt.Sprint(nfk.SelfIsUnique), IsBreaking: true})
+		}
+	}
+}
+
+func volatility(f *Function) string {
+	switch {
+	case f.IsImmutable:
+		return "IMMUTABLE"
+	case f.IsStable:
+		return "STABLE"
+	default:
+		return "VOLATILE"
+	}
+}
+
+func diffFunctions(d *SchemaDiff, old, new *DbInfos) {
+	var key = func(f *Function) string {
+		return f.Identifier.String() + "(" + f.Signature + ")"
+	}
+
+	var old_fns = make(map[string]*Function, len(old.Functions))
+	for _, f := range old.Functions {
+		old_fns[key(f)] = f
+	}
+
+	var new_fns = make(map[string]*Function, len(new.Functions))
+	for _, f := range new.Functions {
+		new_fns[key(f)] = f
+	}
+
+	for name := range old_fns {
+		if _, ok := new_fns[name]; !ok {
+			d.add(Change{Kind: CHANGE_REMOVED, ObjectType: OBJECT_FUNCTION, Object: name, IsBreaking: true})
+		}
+	}
+
+	for name, nf := range new_fns {
+		of, ok := old_fns[name]
+		if !ok {
+			d.add(Change{Kind: CHANGE_ADDED, ObjectType: OBJECT_FUNCTION, Object: name})
+			continue
+		}
+
+		if typeName(of.ReturnType) != typeName(nf.ReturnType) || of.ReturnsSet != nf.ReturnsSet {
+			var ret = func(f *Function) string {
+				if f.ReturnsSet {
+					return "SETOF " + typeName(f.ReturnType)
+				}
+				return typeName(f.ReturnType)
+			}
+			d.add(Change{Kind: CHANGE_CHANGED, ObjectType: OBJECT_FUNCTION, Object: name, Detail: "return type", Before: ret(of), After: ret(nf), IsBreaking: true})
+		}
+
+		if volatility(of) != volatility(nf) {
+			// A function that becomes volatile can no longer be called from read only transactions
+			d.add(Change{Kind: CHANGE_CHANGED, ObjectType: OBJECT_FUNCTION, Object: name, Detail: "volatility", Before: volatility(of), After: volatility(nf), IsBreaking: nf.IsVolatile})
+		}
+
+		var of_out, nf_out = argumentsSignature(of.OutputArguments()), argumentsSignature(nf.OutputArguments())
+		if of_out != nf_out {
+			d.add(Change{Kind: CHANGE_CHANGED, ObjectType: OBJECT_FUNCTION, Object: name, Detail: "output columns", Before: of_out, After: nf_out, IsBreaking: true})
+		}
+
+		var of_in, nf_in = argumentsSignature(of.InputArguments()), argumentsSignature(nf.InputArguments())
+		if of_in != nf_in {
+			// Same types but different names or defaults. Renames matter for calls with named arguments and a removed
+			// default for calls that left the argument out, a new default does not bother anyone.
+			d.add(Change{Kind: CHANGE_CHANGED, ObjectType: OBJECT_FUNCTION, Object: name, Detail: "arguments", Before: of_in, After: nf_in, IsBreaking: !onlyAddsDefaults(of.InputArguments(), nf.InputArguments())})
+		}
+	}
+}
+
+func argumentsSignature(args []*FunctionArgument) string {
+	var parts = make([]string, len(args))
+	for i, a := range args {
+		parts[i] = a.Name + " " + typeName(a.Type)
+		if a.HasDefault {
+			parts[i] += " DEFAULT"
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Whether the arguments only differ by defaults that were added
+func onlyAddsDefaults(old, new []*FunctionArgument) bool {
+	if len(old) != len(new) {
+		return false
+	}
+	for i := range old {
+		if old[i].Name != new[i].Name || typeName(old[i].Type) != typeName(new[i].Type) || old[i].HasDefault && !new[i].HasDefault {
+			return false
+		}
+	}
+	return true
+}
+
+// Only user visible types are compared, not the row types of relations or array types that come and go with them.
+func diffTypes(d *SchemaDiff, old, new *DbInfos) {
+	var collect = func(db *DbInfos) map[string]*Type {
+		var res = make(map[string]*Type)
+		for _, t := range db.Types {
+			if t.IsArray() || t.Relation != nil {
+				continue
+			}
+			res[t.PgIdentifier.String()] = t
+		}
+		return res
+	}
+
+	var old_types, new_types = collect(old), collect(new)
+
+	for name := range old_types {
+		if _, ok := new_types[name]; !ok {
+			d.add(Change{Kind: CHANGE_REMOVED, ObjectType: OBJECT_TYPE, Object: name, IsBreaking: true})
+		}
+	}
+
+	for name, nt := range new_types {
+		ot, ok := old_types[name]
+		if !ok {
+			d.add(Change{Kind: CHANGE_ADDED, ObjectType: OBJECT_TYPE, Object: name})
+			continue
+		}
+
+		if ot.PgKind != nt.PgKind {
+			d.add(Change{Kind: CHANGE_CHANGED, ObjectType: OBJECT_TYPE, Object: name, Detail: "kind", Before: ot.PgKind, After: nt.PgKind, IsBreaking: true})
+		} else if typeName(ot.BaseType) != typeName(nt.BaseType) {
+			d.add(Change{Kind: CHANGE_CHANGED, ObjectType: OBJECT_TYPE, Object: name, Detail: "base type", Before: typeName(ot.BaseType), After: typeName(nt.BaseType), IsBreaking: true})
+		}
+
+		diffTypeAttributes(d, name, ot, nt)
+
+		for _, label := range ot.EnumLabels {
+			if !slices.Contains(nt.EnumLabels, label) {
+				d.add(Change{Kind: CHANGE_CHANGED, ObjectType: OBJECT_TYPE, Object: name, Detail: "enum label removed", Before: label, IsBreaking: true})
+			}
+		}
+		for _, label := range nt.EnumLabels {
+			if !slices.Contains(ot.EnumLabels, label) {
+				d.add(Change{Kind: CHANGE_CHANGED, ObjectType: OBJECT_TYPE, Object: name, Detail: "enum label added", After: label})
+			}
+		}
+	}
+}
+
+func diffTypeAttributes(d *SchemaDiff, type_name string, ot, nt *Type) {
+	var find = func(attrs []*TypeAttribute, name string) *TypeAttribute {
+		for _, a := range attrs {
+			if a.Name == name {
+				return a
+			}
+		}
+		return nil
+	}
+
+	for _, oa := range ot.Attributes {
+		if find(nt.Attributes, oa.Name) == nil {
+			d.add(Change{Kind: CHANGE_CHANGED, ObjectType: OBJECT_TYPE, Object: type_name, Detail: "attribute removed", Before: oa.Name, IsBreaking: true})
+		}
+	}
+
+	for _, na := range nt.Attributes {
+		oa := find(ot.Attributes, na.Name)
+		if oa == nil {
+			d.add(Change{Kind: CHANGE_CHANGED, ObjectType: OBJECT_TYPE, Object: type_name, Detail: "attribute added", After: na.Name})
+		} else if typeName(oa.Type) != typeName(na.Type) {
+			d.add(Change{Kind: CHANGE_CHANGED, ObjectType: OBJECT_TYPE, Object: type_name, Detail: "type of attribute " + na.Name, Before: typeName(oa.Type), After: typeName(na.Type), IsBreaking: true})
+		}
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright 2025 Christophe Eymard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	var d = Diff(loadFixture(t, "diff_old.json"), loadFixture(t, "diff_new.json"))
+
+	var expected = []string{
+		`[breaking] type "api"."amount" changed: base type ("pg_catalog"."int4" -> "pg_catalog"."int8")`,
+		`[breaking] type "api"."code" changed: kind (d -> e)`,
+		`[additive] type "api"."code" changed: enum label added (x)`,
+		`[breaking] column "api"."customers".code added`,
+		`[additive] column "api"."customers".created_at added`,
+		`[additive] column "api"."customers".email added`,
+		`[breaking] column "api"."customers".id changed: type ("pg_catalog"."int4" -> "pg_catalog"."int8")`,
+		`[breaking] column "api"."customers".name changed: nullability (NULL -> NOT NULL)`,
+		`[breaking] column "api"."customers".nickname removed`,
+		`[breaking] column "api"."customers".rank changed: default (0)`,
+		`[additive] column "api"."customers".serial added`,
+		`[additive] column "api"."customers".status changed: default ('draft'::api.status -> 'sent'::api.status)`,
+		`[breaking] column "api"."customers".tags changed: type ("pg_catalog"."text"[] -> "pg_catalog"."int4"[])`,
+		`[breaking] function "api"."label"(integer) changed: volatility (IMMUTABLE -> VOLATILE)`,
+		`[breaking] relation "api"."legacy" removed`,
+		`[breaking] type "api"."legacy_kind" removed`,
+		`[breaking] function "api"."order_count"(customer_id integer) changed: return type ("pg_catalog"."int4" -> "pg_catalog"."int8")`,
+		`[additive] column "api"."orders".buyer_id added`,
+		`[additive] foreign key "api"."orders".orders_buyer_id_fkey added ((buyer_id) -> "api"."customers"(id))`,
+		`[breaking] foreign key "api"."orders".orders_customer_id_fkey changed: uniqueness (false -> true)`,
+		`[breaking] foreign key "api"."orders".orders_seller_id_fkey changed: columns ((seller_id) -> "api"."customers"(id) -> (buyer_id) -> "api"."customers"(id))`,
+		`[additive] type "api"."priority" added`,
+		`[breaking] function "api"."refresh"() removed`,
+		`[additive] function "api"."refresh"(force boolean) added`,
+		`[breaking] relation "api"."report" changed: kind (v -> m)`,
+		`[additive] column "api"."report".count added`,
+		`[additive] relation "api"."reviews" added`,
+		`[additive] function "api"."search"(q text) changed: arguments (q "pg_catalog"."text" -> q "pg_catalog"."text" DEFAULT)`,
+		`[breaking] type "api"."status" changed: enum label removed (cancelled)`,
+		`[additive] type "api"."status" changed: enum label added (paid)`,
+		`[additive] function "api"."touch"() changed: volatility (VOLATILE -> STABLE)`,
+	}
+
+	var got []string
+	for _, c := range d.Changes {
+		got = append(got, c.String())
+	}
+
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("got\n%s\nexpected\n%s", strings.Join(got, "\n"), strings.Join(expected, "\n"))
+	}
+	if !d.HasBreakingChanges() {
+		t.Error("expected breaking changes")
+	}
+}
+
+func TestDiffIdentical(t *testing.T) {
+	var db = loadFixture(t, "diff_old.json")
+	if d := Diff(db, db); len(d.Changes) != 0 || d.HasBreakingChanges() || d.Summary() != "no changes\n" {
+		t.Errorf("a catalog compared to itself has changes: %s", d.Summary())
+	}
+}
+
+func TestDiffSummary(t *testing.T) {
+	var d = &SchemaDiff{Changes: []Change{
+		{Kind: CHANGE_ADDED, ObjectType: OBJECT_RELATION, Object: `"api"."a"`},
+		{Kind: CHANGE_REMOVED, ObjectType: OBJECT_COLUMN, Object: `"api"."b".x`, IsBreaking: true},
+		{Kind: CHANGE_CHANGED, ObjectType: OBJECT_COLUMN, Object: `"api"."c".y`, Detail: "default", Before: "1", After: "2"},
+	}}
+
+	var expected = `[breaking] column "api"."b".x removed
+[additive] relation "api"."a" added
+[additive] column "api"."c".y changed: default (1 -> 2)
+3 changes, 1 breaking
+`
+	if got := d.Summary(); got != expected {
+		t.Errorf("got\n%s\nexpected\n%s", got, expected)
+	}
+}
+
+func TestOnlyAddsDefaults(t *testing.T) {
+	var arg = func(name string, has_default bool) *FunctionArgument {
+		return &FunctionArgument{Name: name, HasDefault: has_default}
+	}
+
+	for _, c := range []struct {
+		old, new []*FunctionArgument
+		expected bool
+	}{
+		{[]*FunctionArgument{arg("q", false)}, []*FunctionArgument{arg("q", true)}, true},
+		{[]*FunctionArgument{arg("q", true)}, []*FunctionArgument{arg("q", false)}, false},
+		{[]*FunctionArgument{arg("q", false)}, []*FunctionArgument{arg("query", true)}, false},
+		{[]*FunctionArgument{arg("q", true)}, []*FunctionArgument{arg("q", true), arg("limit", true)}, false},
+	} {
+		if got := onlyAddsDefaults(c.old, c.new); got != c.expected {
+			t.Errorf("%v -> %v: got %v, expected %v", argumentsSignature(c.old), argumentsSignature(c.new), got, c.expected)
+		}
+	}
+}
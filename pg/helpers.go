@@ -28,14 +28,14 @@ func escapeQuotes(s string) string {
 }
 
 // Scan the result of a json_agg query into a target, because the json deserialization is actually easier to use that defining custom types with pgx, and since we only do it once to refresh the schema information, we don't bother.
-func scanIntoThroughJsonAgg(conn *pgx.Conn, query string, target any) error {
-	rows, err := conn.Query(context.Background(), query)
+func scanIntoThroughJsonAgg(ctx context.Context, conn *pgx.Conn, query string, target any, args ...any) error {
+	rows, err := conn.Query(ctx, query, args...)
 	if err != nil {
 		return errors.Errorf("failed to query: %w", err)
 	}
 	defer rows.Close()
 
-	var jsonstr string
+	var jsonstr *string
 
 	if !rows.Next() {
 		return errors.Errorf("no rows found")
@@ -46,7 +46,12 @@ func scanIntoThroughJsonAgg(conn *pgx.Conn, query string, target any) error {
 		return errors.Errorf("failed to scan json: %w", err)
 	}
 
-	err = json.Unmarshal([]byte(jsonstr), &target)
+	// json_agg yields NULL when there are no rows
+	if jsonstr == nil {
+		return nil
+	}
+
+	err = json.Unmarshal([]byte(*jsonstr), &target)
 	if err != nil {
 		return errors.Errorf("failed to unmarshal: %w", err)
 	}
@@ -16,23 +16,46 @@ package pg
 
 import (
 	"context"
+	"strings"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"gitlab.com/tozd/go/errors"
+	"golang.org/x/sync/errgroup"
 )
 
 // Introspection of the database.
 type DbInfos struct {
-	Pool *pgxpool.Pool
-
-	Types []Type
-
-	Functions []*Function
-	Relations []*Relation
+	Pool *pgxpool.Pool `json:"-"`
+
+	Options Options // What was loaded
+
+	Types []*Type
+
+	Functions   []*Function
+	Relations   []*Relation
+	ForeignKeys []*ForeignKey
+	Roles       []*Role
+	Sequences   []*Sequence
+	Extensions  []*Extension
+	Operators   []*Operator
+	Casts       []*Cast
+
+	TypeMapByOid       map[int]*Type          `json:"-"`
+	RelationMapByRelid map[int]*Relation      `json:"-"`
+	RelationMapByName  map[string]*Relation   `json:"-"` // By escaped identifier
+	FunctionMapByName  map[string][]*Function `json:"-"` // Overloads grouped by their escaped identifier
+	FunctionMapByOid   map[int]*Function      `json:"-"`
+	RoleMapByName      map[string]*Role       `json:"-"`
+	OperatorMapByOid   map[int]*Operator      `json:"-"`
+	OperatorMapByName  map[string][]*Operator `json:"-"` // By the operator alone, whatever its schema
+
+	castMap map[[2]int]*Cast // By source and target oids
+}
 
-	TypeMapByOid       map[int]*Type
-	RelationMapByRelid map[int]*Relation
+// A DbInfos is its own catalog, one that never changes. See Watcher for one that follows schema changes.
+func (db *DbInfos) Infos() *DbInfos {
+	return db
 }
 
 func (db *DbInfos) GetType(oid int) *Type {
@@ -42,6 +65,13 @@ func (db *DbInfos) GetType(oid int) *Type {
 	return nil
 }
 
+func (db *DbInfos) GetFunction(oid int) *Function {
+	if f, ok := db.FunctionMapByOid[oid]; ok {
+		return f
+	}
+	return nil
+}
+
 func (d *DbInfos) GetRelation(relid int) *Relation {
 	if r, ok := d.RelationMapByRelid[relid]; ok {
 		return r
@@ -59,57 +89,122 @@ func (d *DbInfos) GetRelationByType(typeOid int) *Relation {
 // ------------------------------------------------------------
 
 // Create a database connection and fill the informations
-func NewInfos(uri string) (*DbInfos, error) {
-	pool, err := pgxpool.New(context.Background(), uri)
+func NewInfos(uri string, opts Options) (*DbInfos, error) {
+	return NewInfosContext(context.Background(), uri, opts)
+}
+
+// Create a database connection and fill the informations, giving up when the context is done.
+func NewInfosContext(ctx context.Context, uri string, opts Options) (*DbInfos, error) {
+	pool, err := pgxpool.New(ctx, uri)
 	if err != nil {
 		return nil, errors.Errorf("failed to create pool: %w", err)
 	}
 
 	var db = &DbInfos{
-		Pool:               pool,
-		TypeMapByOid:       make(map[int]*Type),
-		RelationMapByRelid: make(map[int]*Relation),
+		Pool:    pool,
+		Options: opts,
 	}
 
-	conn, err := pool.Acquire(context.Background())
-	if err != nil {
-		panic(err)
-	}
-	defer conn.Release()
-
-	if err := db.Fill(conn.Conn()); err != nil {
+	if err := db.FillConcurrently(ctx); err != nil {
+		pool.Close()
 		return nil, err
 	}
 
 	return db, nil
 }
 
-// Fill informations from the database
-func (db *DbInfos) Fill(conn *pgx.Conn) error {
+// The queries that fill the informations, grouped by the ones that have to run one after the other.
+var fillGroups = [][]func(ctx context.Context, infos *DbInfos, conn *pgx.Conn) error{
+	{FillRelationInformations, FillViewInformations},
+	{FillFunctionInformations},
+	{FillTypeInformations},
+	{FillRoleInformations, FillForeignKeyInformations},
+	{FillSequenceInformations, FillExtensionInformations},
+	{FillOperatorInformations, FillCastInformations},
+}
+
+// Fill informations from the database on a single connection, and resolve them.
+// The queries all run in the same REPEATABLE READ transaction so that they see the same catalog.
+func (db *DbInfos) Fill(ctx context.Context, conn *pgx.Conn) error {
+	tx, err := conn.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return errors.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	for _, group := range fillGroups {
+		for _, fill := range group {
+			if err := fill(ctx, db, conn); err != nil {
+				return err
+			}
+		}
+	}
 
-	// for _, t := range db.Types {
-	// 	db.TypeMap[t.oid] = &t
-	// }
+	return db.Resolve()
+}
 
-	if err := FillFunctionInformations(db, conn); err != nil {
-		return err
+// Fill informations from the database using several connections of the pool at once, and resolve them.
+// The first connection exports its snapshot and the others import it, so that they all see the same catalog
+// even though they run in different transactions.
+func (db *DbInfos) FillConcurrently(ctx context.Context) error {
+	var conns = min(len(fillGroups), int(db.Pool.Config().MaxConns))
+
+	first, err := db.Pool.Acquire(ctx)
+	if err != nil {
+		return errors.Errorf("failed to acquire a connection: %w", err)
 	}
+	defer first.Release()
 
-	if err := FillRelationInformations(db, conn); err != nil {
-		return err
+	tx, err := first.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return errors.Errorf("failed to begin transaction: %w", err)
 	}
+	defer tx.Rollback(ctx)
 
-	if err := FillTypeInformations(db, conn); err != nil {
-		return err
+	var snapshot string
+	if err := tx.QueryRow(ctx, "SELECT pg_export_snapshot()").Scan(&snapshot); err != nil {
+		return errors.Errorf("failed to export snapshot: %w", err)
 	}
 
-	// for _, f := range db.Functions {
-	// 	db.FunctionMap[f.Identifier.String()] = &f
-	// }
+	// The transactions are rolled back with ctx, which stays valid when the group gives up
+	group, group_ctx := errgroup.WithContext(ctx)
+
+	for i := 0; i < conns; i++ {
+		group.Go(func() error {
+			var conn = first
+			if i > 0 {
+				var err error
+				if conn, err = db.Pool.Acquire(group_ctx); err != nil {
+					return errors.Errorf("failed to acquire a connection: %w", err)
+				}
+				defer conn.Release()
+
+				tx, err := conn.BeginTx(group_ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+				if err != nil {
+					return errors.Errorf("failed to begin transaction: %w", err)
+				}
+				defer tx.Rollback(ctx)
+
+				if _, err := tx.Exec(group_ctx, "SET TRANSACTION SNAPSHOT '"+strings.ReplaceAll(snapshot, "'", "''")+"'"); err != nil {
+					return errors.Errorf("failed to import snapshot: %w", err)
+				}
+			}
+
+			// Groups are spread over the connections we have
+			for g := i; g < len(fillGroups); g += conns {
+				for _, fill := range fillGroups[g] {
+					if err := fill(group_ctx, db, conn.Conn()); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		})
+	}
 
-	// if err := FillRelationInformations(infos, conn); err != nil {
-	// 	return err
-	// }
+	if err := group.Wait(); err != nil {
+		return err
+	}
 
-	return nil
+	return db.Resolve()
 }
@@ -0,0 +1,76 @@
+// Copyright 2025 Christophe Eymard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// When a cast is applied without being asked for
+const (
+	CAST_IMPLICIT   = "i" // In any expression
+	CAST_ASSIGNMENT = "a" // When assigning to a column
+	CAST_EXPLICIT   = "e" // Only with CAST or ::
+)
+
+const (
+	CAST_METHOD_FUNCTION = "f"
+	CAST_METHOD_BINARY   = "b" // The types are binary coercible, no function is called
+	CAST_METHOD_INOUT    = "i" // Through the output and input functions of the types
+)
+
+type Cast struct {
+	Source *Type `json:"-"`
+	Target *Type `json:"-"`
+
+	Context      string // One of the CAST_* constants
+	Method       string // One of the CAST_METHOD_* constants
+	FunctionName string // The function that does the conversion when Method is CAST_METHOD_FUNCTION
+
+	PgOid       int
+	PgSourceOid int
+	PgTargetOid int
+}
+
+func (c *Cast) IsImplicit() bool {
+	return c.Context == CAST_IMPLICIT
+}
+
+// Find the cast between two types, nil if there is none in pg_cast.
+func (db *DbInfos) GetCast(source *Type, target *Type) *Cast {
+	if source == nil || target == nil {
+		return nil
+	}
+	return db.castMap[[2]int{source.PgOid, target.PgOid}]
+}
+
+// Query the database and fill the infos
+func FillCastInformations(ctx context.Context, infos *DbInfos, conn *pgx.Conn) error {
+	return scanIntoThroughJsonAgg(ctx, conn, INFO_QUERY_CASTS, &infos.Casts)
+}
+
+var INFO_QUERY_CASTS = /* sql */ `
+SELECT json_agg(C) FROM (SELECT
+	c.oid::integer AS "PgOid",
+	c.castsource::integer AS "PgSourceOid",
+	c.casttarget::integer AS "PgTargetOid",
+	c.castcontext AS "Context",
+	c.castmethod AS "Method",
+	CASE WHEN c.castfunc <> 0 THEN c.castfunc::regproc::text ELSE '' END AS "FunctionName"
+FROM pg_cast c
+ORDER BY c.oid
+) C;`
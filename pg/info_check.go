@@ -0,0 +1,54 @@
+// Copyright 2025 Christophe Eymard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg
+
+// A CHECK constraint of a table or a domain
+type CheckConstraint struct {
+	Name        string
+	Definition  string   // As given by pg_get_constraintdef, CHECK (...)
+	ColumnNames []string // The columns the constraint of a table references
+	IsValidated bool     // False when the constraint was added as NOT VALID, existing rows may not satisfy it
+
+	// What could be understood of the expression. Parts of it that are too complex are left out, so these
+	// rules may accept values that the database will not.
+	Rules []*ValidationRule `json:"-"`
+}
+
+// Check constraints of a relation, to be included in a query on pg_class.
+var INFO_QUERY_RELATION_CHECKS = /* sql */ `(
+	SELECT json_agg(json_build_object(
+		'Name', con.conname,
+		'Definition', pg_get_constraintdef(con.oid),
+		'IsValidated', con.convalidated,
+		'ColumnNames', (
+			SELECT json_agg(a.attname ORDER BY a.attnum)
+			FROM pg_attribute a
+			WHERE a.attrelid = con.conrelid AND a.attnum = ANY(con.conkey)
+		)
+	) ORDER BY con.conname)
+	FROM pg_constraint con
+	WHERE con.conrelid = pg_class.oid AND con.contype = 'c'
+)`
+
+// Check constraints of a domain, to be included in a query on pg_type.
+var INFO_QUERY_DOMAIN_CHECKS = /* sql */ `(
+	SELECT json_agg(json_build_object(
+		'Name', con.conname,
+		'Definition', pg_get_constraintdef(con.oid),
+		'IsValidated', con.convalidated
+	) ORDER BY con.conname)
+	FROM pg_constraint con
+	WHERE con.contypid = t.oid AND con.contype = 'c'
+)`
@@ -0,0 +1,61 @@
+// Copyright 2025 Christophe Eymard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// An installed extension
+type Extension struct {
+	Name          string
+	Schema        string // Where its objects were created
+	Version       string
+	Description   string
+	IsRelocatable bool
+}
+
+func (db *DbInfos) GetExtension(name string) *Extension {
+	for _, e := range db.Extensions {
+		if e.Name == name {
+			return e
+		}
+	}
+	return nil
+}
+
+func (db *DbInfos) HasExtension(name string) bool {
+	return db.GetExtension(name) != nil
+}
+
+// Query the database and fill the infos.
+// Extensions are loaded whatever the schemas that were asked for, since their types may be used anywhere.
+func FillExtensionInformations(ctx context.Context, infos *DbInfos, conn *pgx.Conn) error {
+	return scanIntoThroughJsonAgg(ctx, conn, INFO_QUERY_EXTENSIONS, &infos.Extensions)
+}
+
+var INFO_QUERY_EXTENSIONS = /* sql */ `
+SELECT json_agg(E) FROM (SELECT
+	e.extname AS "Name",
+	n.nspname AS "Schema",
+	e.extversion AS "Version",
+	coalesce(obj_description(e.oid, 'pg_extension'), '') AS "Description",
+	e.extrelocatable AS "IsRelocatable"
+FROM pg_extension e
+INNER JOIN pg_namespace n ON n.oid = e.extnamespace
+ORDER BY e.extname
+) E;`
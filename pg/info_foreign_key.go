@@ -14,8 +14,29 @@
 
 package pg
 
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"gitlab.com/tozd/go/errors"
+)
+
+// A foreign key constraint as found in pg_constraint. Once loaded, it is split into an OutgoingForeignKey
+// on the referencing relation and an IncomingForeignKey on the referenced one.
+type ForeignKey struct {
+	Identifier       SqlIdentifier
+	ColumnNames      []string
+	OtherColumnNames []string
+	IsUnique         bool // The referencing columns are covered by a unique index
+
+	PgOid        int
+	PgRelId      int
+	PgOtherRelId int
+}
+
 type IncomingForeignKey struct {
 	Identifier       SqlIdentifier
+	ForeignKey       *ForeignKey // The constraint this comes from, which may be between other relations if this one is a view
 	OtherRelation    *Relation
 	OtherColumns     []*Column
 	OtherColumnNames []string
@@ -28,6 +49,7 @@ type IncomingForeignKey struct {
 
 type OutgoingForeignKey struct {
 	Identifier       SqlIdentifier
+	ForeignKey       *ForeignKey // The constraint this comes from, which may be between other relations if this one is a view
 	OtherRelation    *Relation
 	OtherColumns     []*Column
 	OtherColumnNames []string
@@ -36,3 +58,109 @@ type OutgoingForeignKey struct {
 	SelfColumnNames []string
 	SelfColumns     []*Column
 }
+
+// Query the database and fill the infos
+// Foreign keys are loaded when both their relations are, see relationFilter.
+func FillForeignKeyInformations(ctx context.Context, infos *DbInfos, conn *pgx.Conn) error {
+	args, err := infos.Options.relationFilterArgs()
+	if err != nil {
+		return err
+	}
+	return scanIntoThroughJsonAgg(ctx, conn, INFO_QUERY_FOREIGN_KEYS, &infos.ForeignKeys, args...)
+}
+
+// Add the outgoing and incoming sides of a foreign key to the relations it links.
+// Constraint names are only unique per table, so incoming foreign keys are looked up by the referencing relation
+// as well. When a view inherits two foreign keys with the same name, only the first one can be looked up by name.
+func linkForeignKey(fk *ForeignKey, self *Relation, self_columns []*Column, other *Relation, other_columns []*Column) {
+	var outgoing = &OutgoingForeignKey{
+		Identifier:       fk.Identifier,
+		ForeignKey:       fk,
+		OtherRelation:    other,
+		OtherColumns:     other_columns,
+		OtherColumnNames: columnNames(other_columns),
+		SelfIsUnique:     fk.IsUnique,
+		SelfColumnNames:  columnNames(self_columns),
+		SelfColumns:      self_columns,
+	}
+	self.OutgoingForeignKeys = append(self.OutgoingForeignKeys, outgoing)
+	if _, exists := self.outgoingForeignKeysMap[fk.Identifier.Name]; !exists {
+		self.outgoingForeignKeysMap[fk.Identifier.Name] = outgoing
+	}
+
+	var incoming = &IncomingForeignKey{
+		Identifier:       fk.Identifier,
+		ForeignKey:       fk,
+		OtherRelation:    self,
+		OtherColumns:     self_columns,
+		OtherColumnNames: columnNames(self_columns),
+		OtherIsUnique:    fk.IsUnique,
+		SelfColumnNames:  columnNames(other_columns),
+		SelfColumns:      other_columns,
+	}
+	other.IncomingForeignKeys = append(other.IncomingForeignKeys, incoming)
+	var key = incomingForeignKeyKey{self.Identifier.String(), fk.Identifier.Name}
+	if _, exists := other.incomingForeignKeysMap[key]; !exists {
+		other.incomingForeignKeysMap[key] = incoming
+	}
+}
+
+// The escaped identifier of the referencing relation and the name of the constraint
+type incomingForeignKeyKey [2]string
+
+func columnNames(columns []*Column) []string {
+	var names = make([]string, len(columns))
+	for i, c := range columns {
+		names[i] = c.Name
+	}
+	return names
+}
+
+func getColumnsByName(rel *Relation, names []string) ([]*Column, error) {
+	var columns = make([]*Column, 0, len(names))
+	for _, name := range names {
+		col, ok := rel.ColumnsMap[name]
+		if !ok {
+			return nil, errors.Errorf("column %s not found in %s", name, rel.Identifier.String())
+		}
+		columns = append(columns, col)
+	}
+	return columns, nil
+}
+
+// Column names are aggregated in the order of the constraint's keys, so that
+// ColumnNames[i] references OtherColumnNames[i].
+var INFO_QUERY_FOREIGN_KEYS = /* sql */ `
+SELECT json_agg(F) FROM (SELECT
+	c.oid::integer AS "PgOid",
+	c.conrelid::integer AS "PgRelId",
+	c.confrelid::integer AS "PgOtherRelId",
+	json_build_object(
+		'Schema', n.nspname,
+		'Name', c.conname
+	) AS "Identifier",
+	(
+		SELECT json_agg(a.attname ORDER BY k.ord)
+		FROM unnest(c.conkey) WITH ORDINALITY k(attnum, ord)
+		INNER JOIN pg_attribute a ON a.attrelid = c.conrelid AND a.attnum = k.attnum
+	) AS "ColumnNames",
+	(
+		SELECT json_agg(a.attname ORDER BY k.ord)
+		FROM unnest(c.confkey) WITH ORDINALITY k(attnum, ord)
+		INNER JOIN pg_attribute a ON a.attrelid = c.confrelid AND a.attnum = k.attnum
+	) AS "OtherColumnNames",
+	EXISTS (
+		SELECT 1 FROM pg_index i
+		WHERE i.indrelid = c.conrelid
+			AND i.indisunique
+			AND i.indpred IS NULL
+			AND i.indexprs IS NULL
+			AND i.indkey::int2[] <@ c.conkey
+	) AS "IsUnique"
+FROM pg_constraint c
+INNER JOIN pg_namespace n ON n.oid = c.connamespace
+WHERE c.contype = 'f'
+	AND ` + relationFilter("c.conrelid") + `
+	AND ` + relationFilter("c.confrelid") + `
+ORDER BY n.nspname, c.conname
+) F;`
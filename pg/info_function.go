@@ -15,6 +15,8 @@
 package pg
 
 import (
+	"context"
+
 	"fmt"
 
 	"github.com/jackc/pgx/v5"
@@ -25,12 +27,23 @@ const (
 	MODE_OUT      = "o"
 	MODE_INOUT    = "b"
 	MODE_VARIADIC = "v"
+	MODE_TABLE    = "t" // A column of RETURNS TABLE
+)
+
+const (
+	PROKIND_FUNCTION  = "f"
+	PROKIND_PROCEDURE = "p"
+	PROKIND_AGGREGATE = "a"
+	PROKIND_WINDOW    = "w"
 )
 
 type FunctionArgument struct {
-	Index int
+	Index int // 1-based position amongst all the arguments, including OUT ones
 	Name  string
-	Type  *Type
+	Type  *Type `json:"-"`
+
+	HasDefault        bool
+	DefaultExpression string
 
 	PgMode    string
 	PgTypeOid int
@@ -52,21 +65,46 @@ func (f *FunctionArgument) IsVariadic() bool {
 	return f.PgMode == MODE_VARIADIC
 }
 
+func (f *FunctionArgument) IsTable() bool {
+	return f.PgMode == MODE_TABLE
+}
+
+// Tell if the argument has to be given when calling the function
+func (f *FunctionArgument) IsInput() bool {
+	return f.IsIn() || f.IsInOut() || f.IsVariadic()
+}
+
+// Tell if the argument is part of the result of the function
+func (f *FunctionArgument) IsOutput() bool {
+	return f.IsOut() || f.IsInOut() || f.IsTable()
+}
+
 //----------------------------------------------------------------------------------
 //---------------------------- Function --------------------------------------------
 
 type Function struct {
 	Identifier SqlIdentifier
+	Signature  string // The argument types that tell overloads apart, as given by pg_get_function_identity_arguments
+	Language   string
+
+	Arguments []*FunctionArgument
 
-	Arguments []FunctionArgument
+	Description string            // From COMMENT ON FUNCTION
+	Annotations map[string]string // Parsed from the front-matter of the comment
 
-	ReturnsSet      bool // Whether this function returns a table() or a setof ReturnType
-	ReturnType      *Type
+	Owner      string
+	Privileges []Privilege
+
+	ReturnsSet      bool  // Whether this function returns a table() or a setof ReturnType
+	ReturnType      *Type `json:"-"`
 	PgReturnTypeOid int
 
+	PgOid  int
+	PgKind string // One of the PROKIND_* constants
+
 	// Other function attributes that are not relevant as of now
 	IsStrict            bool // proisstrict
-	IsSetUid            bool
+	IsSetUid            bool // SECURITY DEFINER
 	IsVolatile          bool
 	IsLeakproof         bool
 	IsCalledOnNullInput bool
@@ -79,52 +117,147 @@ func (f *Function) ReturnsSingleRow() bool {
 }
 
 func (f *Function) String() string {
-	return fmt.Sprintf("Function(%s())", f.Identifier.String())
+	return fmt.Sprintf("Function(%s(%s))", f.Identifier.String(), f.Signature)
+}
+
+func (f *Function) IsProcedure() bool {
+	return f.PgKind == PROKIND_PROCEDURE
+}
+
+func (f *Function) IsAggregate() bool {
+	return f.PgKind == PROKIND_AGGREGATE
+}
+
+func (f *Function) IsVariadic() bool {
+	for _, a := range f.Arguments {
+		if a.IsVariadic() {
+			return true
+		}
+	}
+	return false
+}
+
+// The arguments that are given when calling the function
+func (f *Function) InputArguments() []*FunctionArgument {
+	var res []*FunctionArgument
+	for _, a := range f.Arguments {
+		if a.IsInput() {
+			res = append(res, a)
+		}
+	}
+	return res
+}
+
+// The columns of the result when the function has OUT arguments or RETURNS TABLE
+func (f *Function) OutputArguments() []*FunctionArgument {
+	var res []*FunctionArgument
+	for _, a := range f.Arguments {
+		if a.IsOutput() {
+			res = append(res, a)
+		}
+	}
+	return res
+}
+
+// Tell if the function returns TABLE(...), which is the same as returning a SETOF record with OUT arguments
+func (f *Function) ReturnsTable() bool {
+	for _, a := range f.Arguments {
+		if a.IsTable() {
+			return true
+		}
+	}
+	return false
 }
 
 // IsExportable returns true if the function is exportable to the web.
-// It can only be exported if all its arguments are IN, the return type is OUT and all arguments are named.
+// It can only be exported if it is a plain function, all its input arguments are named so that they can
+// be given by name, and neither its arguments nor its return type are pseudo types that can't be sent or received.
 func (f *Function) IsExportable() bool {
-	// return f.Arguments[0].Mode == "IN" && f.Arguments[len(f.Arguments)-1].Mode == "OUT"
-	return false
+	if f.PgKind != PROKIND_FUNCTION {
+		return false
+	}
+
+	for _, a := range f.Arguments {
+		if a.IsInput() && a.Name == "" {
+			return false
+		}
+		if a.Type.IsPseudo() {
+			return false
+		}
+	}
+
+	if f.ReturnType.IsPseudo() {
+		switch f.ReturnType.PgIdentifier.Name {
+		case "void", "record":
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// All the functions that share the same name, which are told apart by their Signature.
+func (db *DbInfos) GetFunctionOverloads(id SqlIdentifier) []*Function {
+	return db.FunctionMapByName[id.String()]
 }
 
 // Query the database and fill the infos
-func FillFunctionInformations(infos *DbInfos, conn *pgx.Conn) error {
-	if err := scanIntoThroughJsonAgg(conn, INFO_QUERY_FUNCTIONS, &infos.Functions); err != nil {
+func FillFunctionInformations(ctx context.Context, infos *DbInfos, conn *pgx.Conn) error {
+	args, err := infos.Options.functionFilterArgs()
+	if err != nil {
 		return err
 	}
 
-	// And then fill their elem/array counterparts
+	if err := scanIntoThroughJsonAgg(ctx, conn, INFO_QUERY_FUNCTIONS, &infos.Functions, args...); err != nil {
+		return err
+	}
+
+	for _, f := range infos.Functions {
+		f.Description, f.Annotations = parseComment(f.Description)
+	}
 
 	return nil
 }
 
+// Functions that only have IN arguments leave proallargtypes and proargmodes NULL, in which case proargtypes is used.
 var INFO_QUERY_FUNCTIONS = /* sql */ `
 SELECT json_agg(S) FROM	(SELECT
+  p.oid::integer AS "PgOid",
   json_build_object(
     'Schema', n.nspname,
     'Name', p.proname
   ) AS "Identifier",
-	p.prorettype::integer as "PgReturnTypeOid",
+  pg_get_function_identity_arguments(p.oid) AS "Signature",
+  p.prorettype::integer as "PgReturnTypeOid",
+  p.prokind AS "PgKind",
   l.lanname AS "Language",
+  obj_description(p.oid, 'pg_proc') AS "Description",
+  pg_get_userbyid(p.proowner) AS "Owner",
+  ` + infoQueryAcl("p.proacl", "acldefault('f', p.proowner)") + ` AS "Privileges",
   p.proretset AS "ReturnsSet",
   p.proisstrict AS "IsStrict",
-  p.prosecdef AS "IsSetuid",
+  NOT p.proisstrict AS "IsCalledOnNullInput",
+  p.proleakproof AS "IsLeakproof",
+  p.prosecdef AS "IsSetUid",
   p.provolatile = 'i' AS "IsImmutable",
   p.provolatile = 's' AS "IsStable",
   p.provolatile = 'v' AS "IsVolatile",
   (
-    SELECT json_agg(S) FROM (
-			SELECT
-				argnb AS "Index",
-				p.proargnames[argnb] AS "Name",
-				p.proargmodes[argnb] AS "PgMode",
-				p.proallargtypes[argnb]::integer AS "PgTypeOid"
-			FROM generate_series(1, array_length(proallargtypes, 1)) argnb
-		) S) AS "Arguments"
+    SELECT json_agg(S ORDER BY S."Index") FROM (
+      SELECT
+        args.ord::integer AS "Index",
+        coalesce(p.proargnames[args.ord::integer], '') AS "Name",
+        coalesce(p.proargmodes[args.ord::integer], 'i') AS "PgMode",
+        args.typ::integer AS "PgTypeOid",
+        pg_get_function_arg_default(p.oid, args.ord::integer) IS NOT NULL AS "HasDefault",
+        pg_get_function_arg_default(p.oid, args.ord::integer) AS "DefaultExpression"
+      FROM unnest(coalesce(p.proallargtypes, p.proargtypes::oid[])) WITH ORDINALITY args(typ, ord)
+    ) S) AS "Arguments"
   FROM pg_proc p
   LEFT JOIN pg_namespace n ON p.pronamespace = n.oid
   LEFT JOIN pg_language l ON p.prolang = l.oid
-  ORDER BY n.nspname, p.proname) S;
+  WHERE ` + schemaFilter("n.nspname") + `
+    AND ` + nameFilter("n.nspname", "p.proname") + `
+  ORDER BY n.nspname, p.proname, p.oid) S;
 `
@@ -0,0 +1,122 @@
+// Copyright 2025 Christophe Eymard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg
+
+import "slices"
+
+// An index on a relation, as found in pg_index.
+type Index struct {
+	Name        string
+	ColumnNames []string // Only the key columns, INCLUDE columns and expressions are left out
+	Definition  string   // The CREATE INDEX statement
+	Predicate   string   // The WHERE clause of a partial index
+
+	IsUnique       bool
+	IsPrimary      bool
+	IsPartial      bool // The index has a WHERE clause, so it does not apply to all the rows
+	HasExpressions bool // Some of the keys are expressions and not plain columns
+	IsValid        bool
+}
+
+// A plain index applies to every row and only covers columns, which means it can be trusted
+// to tell if a set of columns is unique or cheap to join on.
+func (i *Index) IsPlain() bool {
+	return i.IsValid && !i.IsPartial && !i.HasExpressions
+}
+
+// Tell if the given set of columns is guaranteed to be unique, that is if it contains the primary key
+// or any set of columns that is unique together.
+func (r *Relation) IsUniqueColumnSet(names []string) bool {
+	var contains = func(set []string) bool {
+		if len(set) == 0 {
+			return false
+		}
+		for _, n := range set {
+			if !slices.Contains(names, n) {
+				return false
+			}
+		}
+		return true
+	}
+
+	if contains(r.PrimaryKey) {
+		return true
+	}
+
+	for _, u := range r.UniqueTogether {
+		if contains(u) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Compute the keys of a relation and the flags of its columns from its indexes.
+func fillRelationKeys(r *Relation) {
+	r.PrimaryKey = nil
+	r.UniqueTogether = nil
+	r.IndexedColumns = nil
+
+	for _, idx := range r.Indexes {
+		if !idx.IsPlain() {
+			continue
+		}
+
+		switch {
+		case idx.IsPrimary:
+			r.PrimaryKey = idx.ColumnNames
+		case idx.IsUnique:
+			r.UniqueTogether = append(r.UniqueTogether, idx.ColumnNames)
+		default:
+			r.IndexedColumns = append(r.IndexedColumns, idx.ColumnNames)
+		}
+	}
+
+	for _, c := range r.Columns {
+		c.IsNotNull = !c.IsNullable
+		c.IsPrimaryKey = slices.Contains(r.PrimaryKey, c.Name)
+		c.IsUnique = len(r.PrimaryKey) == 1 && c.IsPrimaryKey
+		for _, u := range r.UniqueTogether {
+			if len(u) == 1 && u[0] == c.Name {
+				c.IsUnique = true
+			}
+		}
+	}
+}
+
+// Indexes of a relation, to be included in a query on pg_class.
+// Only the key columns are kept, and expressions are left out of ColumnNames since their attnum is 0.
+var INFO_QUERY_RELATION_INDEXES = /* sql */ `(
+	SELECT json_agg(json_build_object(
+		'Name', ic.relname,
+		'Definition', pg_get_indexdef(i.indexrelid),
+		'Predicate', pg_get_expr(i.indpred, i.indrelid),
+		'IsUnique', i.indisunique,
+		'IsPrimary', i.indisprimary,
+		'IsPartial', i.indpred IS NOT NULL,
+		'HasExpressions', i.indexprs IS NOT NULL,
+		'IsValid', i.indisvalid,
+		'ColumnNames', (
+			SELECT json_agg(a.attname ORDER BY k.ord)
+			FROM unnest(i.indkey::int2[]) WITH ORDINALITY k(attnum, ord)
+			INNER JOIN pg_attribute a ON a.attrelid = i.indrelid AND a.attnum = k.attnum
+			WHERE k.ord <= i.indnkeyatts
+		)
+	) ORDER BY ic.relname)
+	FROM pg_index i
+	INNER JOIN pg_class ic ON ic.oid = i.indexrelid
+	WHERE i.indrelid = pg_class.oid
+)`
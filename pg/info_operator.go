@@ -0,0 +1,95 @@
+// Copyright 2025 Christophe Eymard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	OPRKIND_INFIX  = "b"
+	OPRKIND_PREFIX = "l"
+)
+
+type Operator struct {
+	Identifier SqlIdentifier // The name is the operator itself, like + or @>
+	Kind       string        // One of the OPRKIND_* constants
+
+	LeftType   *Type `json:"-"` // nil for prefix operators
+	RightType  *Type `json:"-"`
+	ResultType *Type `json:"-"`
+
+	Commutator *Operator `json:"-"` // The operator that gives the same result with its operands swapped
+	Negator    *Operator `json:"-"` // The operator that gives the opposite boolean result
+
+	FunctionName string // The function that implements the operator, as a regproc
+	Description  string
+
+	PgOid           int
+	PgLeftTypeOid   int
+	PgRightTypeOid  int
+	PgResultTypeOid int
+	PgCommutatorOid int
+	PgNegatorOid    int
+}
+
+func (o *Operator) String() string {
+	if o.Kind == OPRKIND_PREFIX {
+		return fmt.Sprintf("Operator(%s %s)", o.Identifier.Name, typeName(o.RightType))
+	}
+	return fmt.Sprintf("Operator(%s %s %s)", typeName(o.LeftType), o.Identifier.Name, typeName(o.RightType))
+}
+
+func (o *Operator) IsPrefix() bool {
+	return o.Kind == OPRKIND_PREFIX
+}
+
+func (db *DbInfos) GetOperator(oid int) *Operator {
+	if o, ok := db.OperatorMapByOid[oid]; ok {
+		return o
+	}
+	return nil
+}
+
+// Query the database and fill the infos.
+// Like types, operators are all loaded since most of them live in pg_catalog.
+func FillOperatorInformations(ctx context.Context, infos *DbInfos, conn *pgx.Conn) error {
+	return scanIntoThroughJsonAgg(ctx, conn, INFO_QUERY_OPERATORS, &infos.Operators)
+}
+
+// Postfix operators, that existed until postgres 14, are left out.
+var INFO_QUERY_OPERATORS = /* sql */ `
+SELECT json_agg(O) FROM (SELECT
+	o.oid::integer AS "PgOid",
+	json_build_object(
+		'Schema', n.nspname,
+		'Name', o.oprname
+	) AS "Identifier",
+	o.oprkind AS "Kind",
+	o.oprleft::integer AS "PgLeftTypeOid",
+	o.oprright::integer AS "PgRightTypeOid",
+	o.oprresult::integer AS "PgResultTypeOid",
+	o.oprcom::integer AS "PgCommutatorOid",
+	o.oprnegate::integer AS "PgNegatorOid",
+	o.oprcode::text AS "FunctionName",
+	coalesce(obj_description(o.oid, 'pg_operator'), '') AS "Description"
+FROM pg_operator o
+INNER JOIN pg_namespace n ON n.oid = o.oprnamespace
+WHERE o.oprkind IN ('b', 'l')
+ORDER BY n.nspname, o.oprname, o.oid
+) O;`
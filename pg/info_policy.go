@@ -0,0 +1,64 @@
+// Copyright 2025 Christophe Eymard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg
+
+import "slices"
+
+// A row level security policy, from pg_policy
+type Policy struct {
+	Name                string
+	Command             string   // SELECT, INSERT, UPDATE, DELETE or ALL
+	Roles               []string // The roles the policy applies to, which may be ROLE_PUBLIC
+	IsPermissive        bool     // Permissive policies are OR'ed together, restrictive ones are AND'ed
+	UsingExpression     string   // Filters the existing rows
+	WithCheckExpression string   // Checks the new rows
+}
+
+// Tell if the policy is used when the role executes the command.
+func (p *Policy) AppliesTo(db *DbInfos, role string, command string) bool {
+	if p.Command != "ALL" && p.Command != command {
+		return false
+	}
+
+	for _, r := range db.EffectiveRoles(role) {
+		if slices.Contains(p.Roles, r) {
+			return true
+		}
+	}
+	return false
+}
+
+// Policies of a relation, to be included in a query on pg_class.
+var INFO_QUERY_RELATION_POLICIES = /* sql */ `(
+	SELECT json_agg(json_build_object(
+		'Name', pol.polname,
+		'Command', CASE pol.polcmd
+			WHEN 'r' THEN 'SELECT'
+			WHEN 'a' THEN 'INSERT'
+			WHEN 'w' THEN 'UPDATE'
+			WHEN 'd' THEN 'DELETE'
+			ELSE 'ALL'
+		END,
+		'Roles', (
+			SELECT json_agg(CASE WHEN r = 0 THEN 'PUBLIC' ELSE pg_get_userbyid(r) END)
+			FROM unnest(pol.polroles) r
+		),
+		'IsPermissive', pol.polpermissive,
+		'UsingExpression', pg_get_expr(pol.polqual, pol.polrelid),
+		'WithCheckExpression', pg_get_expr(pol.polwithcheck, pol.polrelid)
+	) ORDER BY pol.polname)
+	FROM pg_policy pol
+	WHERE pol.polrelid = pg_class.oid
+)`
@@ -14,15 +14,36 @@
 
 package pg
 
-import "github.com/jackc/pgx/v5"
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+)
 
 type Column struct {
 	Name      string
+	Index     int // attnum
 	PgTypeOid int
 
-	Type *Type
+	Type *Type `json:"-"`
+
+	Description string            // From COMMENT ON COLUMN
+	Annotations map[string]string // Parsed from the front-matter of the comment
+
+	// When the relation is a view, the column of the relation it was taken from, if any.
+	// It may itself be the column of another view.
+	BaseColumn        *Column `json:"-"`
+	PgBaseRelId       int
+	PgBaseColumnIndex int
 
 	DefaultExpression string
+	Sequence          *Sequence `json:"-"` // The sequence that gives the column its values, if it is an identity or a serial
+
+	Privileges []Privilege // Column level grants, which come on top of the ones of the relation
+
+	// What a value has to comply with, from NOT NULL, the column's domain and the CHECK constraints of the relation
+	// that only involve this column.
+	Rules []*ValidationRule `json:"-"`
 
 	IsPrimaryKey bool
 	IsIdentity   bool
@@ -32,77 +53,197 @@ type Column struct {
 	IsNullable   bool
 }
 
+const (
+	RELKIND_TABLE             = "r"
+	RELKIND_VIEW              = "v"
+	RELKIND_MATERIALIZED_VIEW = "m"
+	RELKIND_FOREIGN_TABLE     = "f"
+	RELKIND_PARTITIONED_TABLE = "p"
+)
+
 // A table or view
 type Relation struct {
 	Identifier SqlIdentifier
 
+	Description string            // From COMMENT ON
+	Annotations map[string]string // Parsed from the front-matter of the comment
+
+	Kind               string // pg_class.relkind, one of the RELKIND_* constants
 	IsView             bool
 	IsMaterializedView bool
 
+	Owner            string
+	Privileges       []Privilege
+	RowSecurity      bool // Row level security is enabled
+	ForceRowSecurity bool // Row level security also applies to the owner
+	Policies         []*Policy
+
+	IsInsertable    bool // INSERT works on this relation, either natively or through INSTEAD OF triggers, see HasInsteadOfTrigger
+	IsUpdatable     bool
+	IsDeletable     bool
+	IsAutoUpdatable bool // The view is simple enough for postgres to write to its base table without triggers
+
+	IsPartition     bool
+	PartitionKey    string      // PARTITION BY clause if this is a partitioned table
+	PartitionBound  string      // FOR VALUES clause if this is a partition
+	PartitionParent *Relation   `json:"-"`
+	Partitions      []*Relation `json:"-"`
+	InheritsFrom    []*Relation `json:"-"` // Parents of a table that uses regular inheritance, not partitioning
+
 	Columns    []*Column
-	ColumnsMap map[string]*Column
+	ColumnsMap map[string]*Column `json:"-"`
+
+	Indexes []*Index
+	Checks  []*CheckConstraint
+
+	Triggers []*Trigger
 
 	PrimaryKey     []string
 	UniqueTogether [][]string
 	IndexedColumns [][]string // On top of PrimaryKey and UniqueTogether, columns that are susceptible to be used for joining on this table as an incoming multiple-row foreign key.
 
-	Type *Type // The related type
+	Type *Type `json:"-"` // The related type
 
 	// Unique list of foreign keys for this relation
-	OutgoingForeignKeys []*OutgoingForeignKey
-	IncomingForeignKeys []*IncomingForeignKey
+	OutgoingForeignKeys []*OutgoingForeignKey `json:"-"`
+	IncomingForeignKeys []*IncomingForeignKey `json:"-"`
 
 	outgoingForeignKeysMap map[string]*OutgoingForeignKey
-	incomingForeignKeysMap map[string]*IncomingForeignKey
+	incomingForeignKeysMap map[incomingForeignKeyKey]*IncomingForeignKey
 
-	PgRelId   int
-	PgTypeOid int
+	PgRelId                int
+	PgTypeOid              int
+	PgPartitionParentRelId int
+	PgInheritsRelIds       []int
+}
+
+func (r *Relation) IsTable() bool {
+	return r.Kind == RELKIND_TABLE || r.Kind == RELKIND_PARTITIONED_TABLE
+}
+
+func (r *Relation) IsForeignTable() bool {
+	return r.Kind == RELKIND_FOREIGN_TABLE
+}
+
+func (r *Relation) IsPartitioned() bool {
+	return r.Kind == RELKIND_PARTITIONED_TABLE
+}
+
+func (r *Relation) GetColumnByIndex(index int) *Column {
+	for _, c := range r.Columns {
+		if c.Index == index {
+			return c
+		}
+	}
+	return nil
+}
+
+// Follow the view columns down to the table column that holds the data, or nil if it is computed.
+func (c *Column) SourceColumn() *Column {
+	var cur = c
+	for cur.BaseColumn != nil {
+		cur = cur.BaseColumn
+	}
+	if cur == c {
+		return nil
+	}
+	return cur
 }
 
 func (r *Relation) GetOutgoingFkByName(name string) *OutgoingForeignKey {
 	return r.outgoingForeignKeysMap[name]
 }
-func (r *Relation) GetIncomingFkByName(name string) *IncomingForeignKey {
-	return r.incomingForeignKeysMap[name]
+
+// Find an incoming foreign key by the relation it comes from and its name, which is only unique on that relation.
+func (r *Relation) GetIncomingFkByName(from SqlIdentifier, name string) *IncomingForeignKey {
+	return r.incomingForeignKeysMap[incomingForeignKeyKey{from.String(), name}]
 }
 
-func FillRelationInformations(infos *DbInfos, conn *pgx.Conn) error {
-	if err := scanIntoThroughJsonAgg(conn, INFO_QUERY_RELATIONS, &infos.Relations); err != nil {
+func FillRelationInformations(ctx context.Context, infos *DbInfos, conn *pgx.Conn) error {
+	args, err := infos.Options.relationFilterArgs()
+	if err != nil {
+		return err
+	}
+
+	if err := scanIntoThroughJsonAgg(ctx, conn, INFO_QUERY_RELATIONS, &infos.Relations, args...); err != nil {
 		return err
 	}
 
+	for _, r := range infos.Relations {
+		r.Description, r.Annotations = parseComment(r.Description)
+		for _, c := range r.Columns {
+			c.Description, c.Annotations = parseComment(c.Description)
+		}
+	}
+
 	return nil
 }
 
+// Relations are read from pg_class and pg_attribute rather than information_schema, which hides materialized views
+// and the columns the current user has no privilege on.
 var INFO_QUERY_RELATIONS = /* sql */ `
 SELECT json_agg(R) FROM (SELECT
 
 	pg_class.oid::integer AS "PgRelId",
+	pg_class.reltype::integer AS "PgTypeOid",
+	pg_class.relkind AS "Kind",
 
 	json_build_object(
-		'Schema', pg_class.relnamespace::regnamespace,
+		'Schema', n.nspname,
 		'Name', pg_class.relname
 	) AS "Identifier",
 
-	json_agg(json_build_object(
-		'Name', column_name,
-		'Index', ordinal_position,
-		'DefaultExpression', CASE WHEN is_identity = 'YES' AND pg_get_serial_sequence(col.table_schema || '.' || col.table_name, col.column_name) IS NOT NULL
-			THEN 'nextval(''' || pg_get_serial_sequence(col.table_schema || '.' || col.table_name, col.column_name) || ''')'
-			ELSE column_default
-		END,
-		'IsNullable', is_nullable = 'YES',
-		'IsSelfReferencing', is_self_referencing = 'YES',
-		'IsIdentity', is_identity = 'YES',
-		'PgTypeOid', (SELECT t.oid::INT FROM pg_type t WHERE t.typname = udt_name AND t.typnamespace = udt_schema::regnamespace),
-		'DomainIdentifier', CASE WHEN domain_schema IS NULL THEN NULL ELSE json_build_object(
-			'Schema', domain_schema,
-			'Name', domain_name
-		) END
-	) ORDER BY ordinal_position
+	` + INFO_QUERY_RELATION_INDEXES + ` AS "Indexes",
+	` + INFO_QUERY_RELATION_CHECKS + ` AS "Checks",
+	` + INFO_QUERY_RELATION_TRIGGERS + ` AS "Triggers",
+
+	obj_description(pg_class.oid, 'pg_class') AS "Description",
+	pg_get_userbyid(pg_class.relowner) AS "Owner",
+	` + infoQueryAcl("pg_class.relacl", "acldefault('r', pg_class.relowner)") + ` AS "Privileges",
+	pg_class.relrowsecurity AS "RowSecurity",
+	pg_class.relforcerowsecurity AS "ForceRowSecurity",
+	` + INFO_QUERY_RELATION_POLICIES + ` AS "Policies",
+
+	pg_relation_is_updatable(pg_class.oid, true) & 8 = 8 AS "IsInsertable",
+	pg_relation_is_updatable(pg_class.oid, true) & 4 = 4 AS "IsUpdatable",
+	pg_relation_is_updatable(pg_class.oid, true) & 16 = 16 AS "IsDeletable",
+	pg_class.relkind = 'v' AND pg_relation_is_updatable(pg_class.oid, false) <> 0 AS "IsAutoUpdatable",
+
+	pg_class.relispartition AS "IsPartition",
+	pg_get_partkeydef(pg_class.oid) AS "PartitionKey",
+	pg_get_expr(pg_class.relpartbound, pg_class.oid) AS "PartitionBound",
+	(
+		SELECT i.inhparent::integer FROM pg_inherits i
+		WHERE i.inhrelid = pg_class.oid AND pg_class.relispartition
+	) AS "PgPartitionParentRelId",
+	(
+		SELECT json_agg(i.inhparent::integer ORDER BY i.inhseqno) FROM pg_inherits i
+		WHERE i.inhrelid = pg_class.oid AND NOT pg_class.relispartition
+	) AS "PgInheritsRelIds",
+
+	(
+		SELECT json_agg(json_build_object(
+			'Name', a.attname,
+			'Index', a.attnum,
+			'PgTypeOid', a.atttypid::integer,
+			'Description', col_description(a.attrelid, a.attnum),
+			'DefaultExpression', CASE
+				WHEN a.attidentity <> '' AND pg_get_serial_sequence(pg_class.oid::regclass::text, a.attname) IS NOT NULL
+					THEN 'nextval(''' || pg_get_serial_sequence(pg_class.oid::regclass::text, a.attname) || ''')'
+				WHEN a.attgenerated = '' THEN pg_get_expr(d.adbin, d.adrelid)
+			END,
+			'IsNullable', NOT a.attnotnull,
+			'IsIdentity', a.attidentity <> '',
+			'IsGenerated', a.attgenerated <> '',
+			'Privileges', ` + infoQueryAcl("a.attacl", "NULL") + `
+		) ORDER BY a.attnum)
+		FROM pg_attribute a
+		LEFT JOIN pg_attrdef d ON d.adrelid = a.attrelid AND d.adnum = a.attnum
+		WHERE a.attrelid = pg_class.oid AND a.attnum > 0 AND NOT a.attisdropped
 	) AS "Columns"
-FROM information_schema.columns col
-INNER JOIN pg_class ON pg_class.relname = col.table_name AND pg_class.relnamespace = col.table_schema::regnamespace
-GROUP BY
-pg_class.oid, pg_class.relnamespace, pg_class.relname
+
+FROM pg_class
+INNER JOIN pg_namespace n ON n.oid = pg_class.relnamespace
+WHERE ` + relationFilter("pg_class.oid") + `
+ORDER BY n.nspname, pg_class.relname
 ) R;`
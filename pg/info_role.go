@@ -0,0 +1,186 @@
+// Copyright 2025 Christophe Eymard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg
+
+import (
+	"context"
+
+	"slices"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// The pseudo role every role is a member of
+const ROLE_PUBLIC = "PUBLIC"
+
+const (
+	PRIVILEGE_SELECT     = "SELECT"
+	PRIVILEGE_INSERT     = "INSERT"
+	PRIVILEGE_UPDATE     = "UPDATE"
+	PRIVILEGE_DELETE     = "DELETE"
+	PRIVILEGE_TRUNCATE   = "TRUNCATE"
+	PRIVILEGE_REFERENCES = "REFERENCES"
+	PRIVILEGE_TRIGGER    = "TRIGGER"
+	PRIVILEGE_EXECUTE    = "EXECUTE"
+)
+
+type Role struct {
+	Name              string
+	MemberOf          []string // Roles this role was directly granted
+	InheritedRoles    []string // The roles of MemberOf whose privileges are available without SET ROLE
+	IsSuperuser       bool
+	CanLogin          bool
+	Inherit           bool // The default for the grants of the role, which decides alone before postgres 16
+	BypassRowSecurity bool
+
+	PgOid int
+}
+
+// A single entry of an ACL, as decoded by aclexplode()
+type Privilege struct {
+	Grantee     string // A role name or ROLE_PUBLIC
+	Privilege   string // One of the PRIVILEGE_* constants
+	IsGrantable bool
+}
+
+func (db *DbInfos) GetRole(name string) *Role {
+	if r, ok := db.RoleMapByName[name]; ok {
+		return r
+	}
+	return nil
+}
+
+// The roles whose privileges are available to the given role, including itself and PUBLIC.
+func (db *DbInfos) EffectiveRoles(name string) []string {
+	var res = []string{name}
+
+	for i := 0; i < len(res); i++ {
+		role := db.GetRole(res[i])
+		if role == nil {
+			continue
+		}
+		for _, parent := range role.InheritedRoles {
+			if !slices.Contains(res, parent) {
+				res = append(res, parent)
+			}
+		}
+	}
+
+	return append(res, ROLE_PUBLIC)
+}
+
+func (db *DbInfos) isSuperuser(role string) bool {
+	r := db.GetRole(role)
+	return r != nil && r.IsSuperuser
+}
+
+// Tell if the role was granted the privilege in the acl, directly or through the roles it inherits from.
+func (db *DbInfos) RoleHasPrivilege(role string, acl []Privilege, privilege string) bool {
+	if db.isSuperuser(role) {
+		return true
+	}
+
+	var roles = db.EffectiveRoles(role)
+	for _, p := range acl {
+		if p.Privilege == privilege && slices.Contains(roles, p.Grantee) {
+			return true
+		}
+	}
+	return false
+}
+
+// Tell if the role may use the privilege on the whole relation.
+func (db *DbInfos) CanAccessRelation(role string, rel *Relation, privilege string) bool {
+	return db.RoleHasPrivilege(role, rel.Privileges, privilege)
+}
+
+// Tell if the role may use the privilege on a column, either because it has it on the relation or on the column itself.
+func (db *DbInfos) CanAccessColumn(role string, rel *Relation, col *Column, privilege string) bool {
+	return db.RoleHasPrivilege(role, rel.Privileges, privilege) || db.RoleHasPrivilege(role, col.Privileges, privilege)
+}
+
+func (db *DbInfos) CanExecute(role string, f *Function) bool {
+	return db.RoleHasPrivilege(role, f.Privileges, PRIVILEGE_EXECUTE)
+}
+
+// The columns of the relation the role may use with the privilege.
+func (db *DbInfos) AccessibleColumns(role string, rel *Relation, privilege string) []*Column {
+	var res []*Column
+	for _, c := range rel.Columns {
+		if db.CanAccessColumn(role, rel, c, privilege) {
+			res = append(res, c)
+		}
+	}
+	return res
+}
+
+// Tell if the row level security policies are enforced when the role queries the relation.
+func (db *DbInfos) RowSecurityApplies(role string, rel *Relation) bool {
+	if !rel.RowSecurity {
+		return false
+	}
+
+	if r := db.GetRole(role); r != nil && (r.IsSuperuser || r.BypassRowSecurity) {
+		return false
+	}
+
+	// Like in postgres, the members of the owner role that inherit its privileges are owners too
+	return rel.ForceRowSecurity || !slices.Contains(db.EffectiveRoles(role), rel.Owner)
+}
+
+// Query the database and fill the infos
+func FillRoleInformations(ctx context.Context, infos *DbInfos, conn *pgx.Conn) error {
+	return scanIntoThroughJsonAgg(ctx, conn, INFO_QUERY_ROLES, &infos.Roles)
+}
+
+// An ACL decoded with aclexplode, to be used in queries with the acl and the acldefault expression to use
+// when it is NULL.
+func infoQueryAcl(acl string, fallback string) string {
+	return /* sql */ `(
+		SELECT json_agg(json_build_object(
+			'Grantee', CASE WHEN acl.grantee = 0 THEN 'PUBLIC' ELSE pg_get_userbyid(acl.grantee) END,
+			'Privilege', acl.privilege_type,
+			'IsGrantable', acl.is_grantable
+		))
+		FROM aclexplode(coalesce(` + acl + `, ` + fallback + `)) acl
+	)`
+}
+
+var INFO_QUERY_ROLES = /* sql */ `
+SELECT json_agg(R) FROM (SELECT
+	r.oid::integer AS "PgOid",
+	r.rolname AS "Name",
+	r.rolsuper AS "IsSuperuser",
+	r.rolcanlogin AS "CanLogin",
+	r.rolinherit AS "Inherit",
+	r.rolbypassrls AS "BypassRowSecurity",
+	(
+		SELECT json_agg(DISTINCT g.rolname ORDER BY g.rolname)
+		FROM pg_auth_members m
+		INNER JOIN pg_roles g ON g.oid = m.roleid
+		WHERE m.member = r.oid
+	) AS "MemberOf",
+	-- From postgres 16, a role may be granted several times by different grantors, each grant telling
+	-- if it is inherited. inherit_option is read through jsonb so that this keeps working on older versions,
+	-- where rolinherit applies to all the memberships.
+	(
+		SELECT json_agg(DISTINCT g.rolname ORDER BY g.rolname)
+		FROM pg_auth_members m
+		INNER JOIN pg_roles g ON g.oid = m.roleid
+		WHERE m.member = r.oid AND coalesce((to_jsonb(m) ->> 'inherit_option')::boolean, r.rolinherit)
+	) AS "InheritedRoles"
+FROM pg_roles r
+ORDER BY r.rolname
+) R;`
@@ -0,0 +1,86 @@
+// Copyright 2025 Christophe Eymard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type Sequence struct {
+	Identifier SqlIdentifier
+
+	DataType  *Type `json:"-"`
+	Start     int64
+	Increment int64
+	MinValue  int64
+	MaxValue  int64
+	Cache     int64
+	Cycle     bool
+
+	// The column the sequence belongs to, either because it is an identity column, a serial, or
+	// because of ALTER SEQUENCE ... OWNED BY. Nil when that column's relation was not loaded.
+	OwnerColumn   *Column   `json:"-"`
+	OwnerRelation *Relation `json:"-"`
+
+	PgOid              int
+	PgTypeOid          int
+	PgOwnerRelId       int
+	PgOwnerColumnIndex int
+}
+
+func (db *DbInfos) GetSequence(id SqlIdentifier) *Sequence {
+	for _, s := range db.Sequences {
+		if s.Identifier == id {
+			return s
+		}
+	}
+	return nil
+}
+
+// Query the database and fill the infos
+func FillSequenceInformations(ctx context.Context, infos *DbInfos, conn *pgx.Conn) error {
+	return scanIntoThroughJsonAgg(ctx, conn, INFO_QUERY_SEQUENCES, &infos.Sequences, infos.Options.schemaFilterArgs()...)
+}
+
+// Identity columns depend on their sequence with an internal dependency, serials and OWNED BY with an auto one.
+var INFO_QUERY_SEQUENCES = /* sql */ `
+SELECT json_agg(S) FROM (SELECT
+	c.oid::integer AS "PgOid",
+	json_build_object(
+		'Schema', n.nspname,
+		'Name', c.relname
+	) AS "Identifier",
+	s.seqtypid::integer AS "PgTypeOid",
+	s.seqstart AS "Start",
+	s.seqincrement AS "Increment",
+	s.seqmin AS "MinValue",
+	s.seqmax AS "MaxValue",
+	s.seqcache AS "Cache",
+	s.seqcycle AS "Cycle",
+	coalesce(d.refobjid::integer, 0) AS "PgOwnerRelId",
+	coalesce(d.refobjsubid, 0) AS "PgOwnerColumnIndex"
+FROM pg_sequence s
+INNER JOIN pg_class c ON c.oid = s.seqrelid
+INNER JOIN pg_namespace n ON n.oid = c.relnamespace
+LEFT JOIN pg_depend d ON d.classid = 'pg_class'::regclass
+	AND d.objid = c.oid
+	AND d.refclassid = 'pg_class'::regclass
+	AND d.refobjsubid > 0
+	AND d.deptype IN ('a', 'i')
+WHERE ` + schemaFilter("n.nspname") + `
+ORDER BY n.nspname, c.relname
+) S;`
@@ -0,0 +1,92 @@
+// Copyright 2025 Christophe Eymard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg
+
+const (
+	TRIGGER_BEFORE     = "BEFORE"
+	TRIGGER_AFTER      = "AFTER"
+	TRIGGER_INSTEAD_OF = "INSTEAD OF"
+)
+
+const (
+	TRIGGER_EVENT_INSERT   = "INSERT"
+	TRIGGER_EVENT_UPDATE   = "UPDATE"
+	TRIGGER_EVENT_DELETE   = "DELETE"
+	TRIGGER_EVENT_TRUNCATE = "TRUNCATE"
+)
+
+// A user defined trigger on a relation. Triggers that postgres creates for its own constraints are not loaded.
+type Trigger struct {
+	Name          string
+	Definition    string   // As given by pg_get_triggerdef
+	Timing        string   // One of the TRIGGER_* constants
+	Events        []string // TRIGGER_EVENT_* constants
+	UpdateColumns []string // Columns given with UPDATE OF, the trigger fires on any update if empty
+	ForEachRow    bool     // FOR EACH ROW, as opposed to FOR EACH STATEMENT
+	IsEnabled     bool     // False when disabled with ALTER TABLE ... DISABLE TRIGGER
+
+	Function      *Function `json:"-"` // May be nil if the function is in a schema that was not loaded
+	PgFunctionOid int
+}
+
+func (t *Trigger) FiresOn(event string) bool {
+	for _, e := range t.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// Tell if an enabled INSTEAD OF trigger handles the event, which is how views that postgres can't update by
+// themselves become writable.
+func (r *Relation) HasInsteadOfTrigger(event string) bool {
+	for _, t := range r.Triggers {
+		if t.IsEnabled && t.Timing == TRIGGER_INSTEAD_OF && t.FiresOn(event) {
+			return true
+		}
+	}
+	return false
+}
+
+// Triggers of a relation, to be included in a query on pg_class.
+// tgtype is a bitmask : 1 for ROW, 2 for BEFORE, 4 INSERT, 8 DELETE, 16 UPDATE, 32 TRUNCATE and 64 INSTEAD OF.
+var INFO_QUERY_RELATION_TRIGGERS = /* sql */ `(
+	SELECT json_agg(json_build_object(
+		'Name', t.tgname,
+		'Definition', pg_get_triggerdef(t.oid),
+		'Timing', CASE
+			WHEN t.tgtype & 64 = 64 THEN 'INSTEAD OF'
+			WHEN t.tgtype & 2 = 2 THEN 'BEFORE'
+			ELSE 'AFTER'
+		END,
+		'Events', array_remove(ARRAY[
+			CASE WHEN t.tgtype & 4 = 4 THEN 'INSERT' END,
+			CASE WHEN t.tgtype & 16 = 16 THEN 'UPDATE' END,
+			CASE WHEN t.tgtype & 8 = 8 THEN 'DELETE' END,
+			CASE WHEN t.tgtype & 32 = 32 THEN 'TRUNCATE' END
+		], NULL),
+		'UpdateColumns', (
+			SELECT json_agg(a.attname ORDER BY a.attnum)
+			FROM pg_attribute a
+			WHERE a.attrelid = t.tgrelid AND a.attnum = ANY(t.tgattr)
+		),
+		'ForEachRow', t.tgtype & 1 = 1,
+		'IsEnabled', t.tgenabled <> 'D',
+		'PgFunctionOid', t.tgfoid::integer
+	) ORDER BY t.tgname)
+	FROM pg_trigger t
+	WHERE t.tgrelid = pg_class.oid AND NOT t.tgisinternal
+)`
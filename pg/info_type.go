@@ -15,23 +15,91 @@
 package pg
 
 import (
+	"context"
+
 	"github.com/jackc/pgx/v5"
-	"gitlab.com/tozd/go/errors"
 )
 
+const (
+	KIND_BASE       = "b"
+	KIND_COMPOSITE  = "c"
+	KIND_DOMAIN     = "d"
+	KIND_ENUM       = "e"
+	KIND_PSEUDO     = "p"
+	KIND_RANGE      = "r"
+	KIND_MULTIRANGE = "m"
+)
+
+// pg_type.typcategory, which drives implicit conversions when resolving operators and functions
+const (
+	CATEGORY_ARRAY        = "A"
+	CATEGORY_BOOLEAN      = "B"
+	CATEGORY_COMPOSITE    = "C"
+	CATEGORY_DATETIME     = "D"
+	CATEGORY_ENUM         = "E"
+	CATEGORY_GEOMETRIC    = "G"
+	CATEGORY_NETWORK      = "I"
+	CATEGORY_NUMERIC      = "N"
+	CATEGORY_PSEUDO       = "P"
+	CATEGORY_RANGE        = "R"
+	CATEGORY_STRING       = "S"
+	CATEGORY_TIMESPAN     = "T"
+	CATEGORY_USER_DEFINED = "U"
+	CATEGORY_BIT_STRING   = "V"
+	CATEGORY_UNKNOWN      = "X"
+	CATEGORY_INTERNAL     = "Z"
+)
+
+// The type of string literals until they are given one
+const UNKNOWN_TYPE_OID = 705
+
+// An attribute of a composite type that was created with CREATE TYPE ... AS (...).
+// The row types of relations have their Relation's columns instead.
+type TypeAttribute struct {
+	Name        string
+	Index       int
+	Description string
+	Type        *Type `json:"-"`
+
+	PgTypeOid int
+}
+
 type Type struct {
 	PgIdentifier SqlIdentifier
 
-	ArrayType   *Type     // The array type of this type
-	ElementType *Type     // The element type of this type, yielded by subscripting - does not implicate that this is an array
-	BaseType    *Type     // only not nil if this is a domain
-	Relation    *Relation // The relation that this type is a composite type of, nil otherwise
+	Description string            // From COMMENT ON TYPE or COMMENT ON DOMAIN
+	Annotations map[string]string // Parsed from the front-matter of the comment
+
+	EnumLabels []string         // The labels of an enum, in their sort order
+	Attributes []*TypeAttribute // The attributes of a standalone composite type
+
+	IsNotNull bool               // A domain declared NOT NULL
+	Checks    []*CheckConstraint // The CHECK constraints of a domain
+
+	Extension string // The extension that created the type, like postgis or citext
+
+	ArrayType   *Type     `json:"-"` // The array type of this type
+	ElementType *Type     `json:"-"` // The element type of this type, yielded by subscripting - does not implicate that this is an array
+	BaseType    *Type     `json:"-"` // only not nil if this is a domain
+	Relation    *Relation `json:"-"` // The relation that this type is a composite type of, nil otherwise
+
+	RangeSubtype   *Type `json:"-"` // The type of the bounds of a range
+	MultirangeType *Type `json:"-"` // The multirange of a range
+	RangeType      *Type `json:"-"` // The range a multirange is made of
 
 	PgOid        int
 	PgElemOid    int
 	PgArrayOid   int // If IsArray, the oid of the array type
 	PgRelId      int // When this type is a composite type
 	PgRealTypeId int // The oid of the real type, if this is a domain
+	PgKind       string
+
+	Category    string // One of the CATEGORY_* constants
+	IsPreferred bool   // The type implicit conversions go to in its category, like text or float8
+
+	PgRangeSubtypeOid int
+	PgMultirangeOid   int
+	PgRangeOid        int
 }
 
 // This is the only true test for array types
@@ -39,83 +107,59 @@ func (t *Type) IsArray() bool {
 	return t != nil && t.ElementType != nil && t.ElementType.ArrayType == t
 }
 
+// Both row types of relations and standalone composite types are composite
 func (t *Type) IsComposite() bool {
-	return t != nil && t.Relation != nil
+	return t != nil && (t.PgKind == KIND_COMPOSITE || t.Relation != nil)
 }
 
 func (t *Type) IsDomain() bool {
 	return t != nil && t.BaseType != nil
 }
 
-//----------------------------------------------------------------------------------
+// Follow the domains down to the type that is not a domain, or return the type itself.
+func (t *Type) UnderlyingType() *Type {
+	var cur = t
+	for cur.IsDomain() {
+		cur = cur.BaseType
+	}
+	return cur
+}
 
-// Query the database and fill the infos
-func FillTypeInformations(infos *DbInfos, conn *pgx.Conn) error {
-	var ok bool
+func (t *Type) IsBase() bool {
+	return t != nil && t.PgKind == KIND_BASE
+}
 
-	if err := scanIntoThroughJsonAgg(conn, INFO_QUERY_TYPES, &infos.Types); err != nil {
-		return err
-	}
+func (t *Type) IsEnum() bool {
+	return t != nil && t.PgKind == KIND_ENUM
+}
 
-	for _, t := range infos.Types {
-		infos.TypeMapByOid[t.PgOid] = &t
-	}
+func (t *Type) IsRange() bool {
+	return t != nil && t.PgKind == KIND_RANGE
+}
 
-	var type_by_relid map[int]*Type = make(map[int]*Type)
+func (t *Type) IsMultirange() bool {
+	return t != nil && t.PgKind == KIND_MULTIRANGE
+}
 
-	for _, t := range infos.Types {
-		if t.PgElemOid != 0 {
-			if t.ElementType, ok = infos.TypeMapByOid[t.PgElemOid]; !ok {
-				return errors.Errorf("failed to find element type %d (this should not happen)", t.PgElemOid)
-			}
-		}
-
-		if t.PgArrayOid != 0 {
-			if t.ArrayType, ok = infos.TypeMapByOid[t.PgArrayOid]; !ok {
-				return errors.Errorf("failed to find array type %d (this should not happen)", t.PgArrayOid)
-			}
-		}
-
-		if t.PgRealTypeId != 0 {
-			if t.BaseType, ok = infos.TypeMapByOid[t.PgRealTypeId]; !ok {
-				return errors.Errorf("failed to find base type %d (this should not happen)", t.PgRealTypeId)
-			}
-		}
-
-		// We'll use this when filling the relations
-		if t.PgRelId > 0 {
-			type_by_relid[t.PgRelId] = &t
-		}
-	}
+func (t *Type) IsPseudo() bool {
+	return t != nil && t.PgKind == KIND_PSEUDO
+}
 
-	// Fill the types for functions
-	for _, f := range infos.Functions {
-		// Errors should never happen here
-		var ok bool
-		if f.ReturnType, ok = infos.TypeMapByOid[f.PgReturnTypeOid]; !ok {
-			return errors.Errorf("failed to find return type %d (this should not happen)", f.PgReturnTypeOid)
-		}
-
-		for _, a := range f.Arguments {
-			if a.Type, ok = infos.TypeMapByOid[a.PgTypeOid]; !ok {
-				return errors.Errorf("failed to find argument type %d (this should not happen)", a.PgTypeOid)
-			}
-		}
-	}
+func (t *Type) IsUnknown() bool {
+	return t != nil && t.PgOid == UNKNOWN_TYPE_OID
+}
 
-	for _, r := range infos.Relations {
-		if r.Type, ok = type_by_relid[r.PgRelId]; !ok {
-			return errors.Errorf("failed to find type for relation %d (this should not happen)", r.PgRelId)
-		}
+//----------------------------------------------------------------------------------
 
-		for _, c := range r.Columns {
-			if c.Type, ok = infos.TypeMapByOid[c.PgTypeOid]; !ok {
-				return errors.Errorf("failed to find type %d for column %s (this should not happen) in table %s", c.PgTypeOid, c.Name, r.Identifier.String())
-			}
-		}
+// Query the database and fill the infos
+func FillTypeInformations(ctx context.Context, infos *DbInfos, conn *pgx.Conn) error {
+	if err := scanIntoThroughJsonAgg(ctx, conn, INFO_QUERY_TYPES, &infos.Types); err != nil {
+		return err
 	}
 
-	// Now, do the relations
+	for _, t := range infos.Types {
+		t.Description, t.Annotations = parseComment(t.Description)
+	}
 
 	return nil
 }
@@ -128,6 +172,39 @@ SELECT json_agg(T) FROM (SELECT
 	t.typarray::integer AS "PgArrayOid",
 	t.typrelid::integer AS "PgRelId",
 	t.typbasetype::integer AS "PgRealTypeId",
+	t.typtype AS "PgKind",
+	t.typcategory AS "Category",
+	t.typispreferred AS "IsPreferred",
+	obj_description(t.oid, 'pg_type') AS "Description",
+	(
+		SELECT json_agg(e.enumlabel ORDER BY e.enumsortorder)
+		FROM pg_enum e
+		WHERE e.enumtypid = t.oid
+	) AS "EnumLabels",
+	(
+		SELECT json_agg(json_build_object(
+			'Name', a.attname,
+			'Index', a.attnum,
+			'PgTypeOid', a.atttypid::integer,
+			'Description', col_description(a.attrelid, a.attnum)
+		) ORDER BY a.attnum)
+		FROM pg_attribute a
+		INNER JOIN pg_class c ON c.oid = a.attrelid
+		WHERE a.attrelid = t.typrelid AND c.relkind = 'c' AND a.attnum > 0 AND NOT a.attisdropped
+	) AS "Attributes",
+	t.typnotnull AS "IsNotNull",
+	` + INFO_QUERY_DOMAIN_CHECKS + ` AS "Checks",
+	coalesce((
+		SELECT e.extname FROM pg_depend d
+		INNER JOIN pg_extension e ON e.oid = d.refobjid
+		WHERE d.classid = 'pg_type'::regclass AND d.objid = t.oid
+			AND d.refclassid = 'pg_extension'::regclass AND d.deptype = 'e'
+	), '') AS "Extension",
+	-- rngmultitypid only exists from postgres 14, going through jsonb keeps this working on older versions.
+	-- The text is read as an oid first, since oids above 2^31 don't fit in an integer.
+	(SELECT r.rngsubtype::integer FROM pg_range r WHERE r.rngtypid = t.oid) AS "PgRangeSubtypeOid",
+	(SELECT (to_jsonb(r) ->> 'rngmultitypid')::oid::integer FROM pg_range r WHERE r.rngtypid = t.oid) AS "PgMultirangeOid",
+	(SELECT r.rngtypid::integer FROM pg_range r WHERE (to_jsonb(r) ->> 'rngmultitypid')::oid = t.oid) AS "PgRangeOid",
 	json_build_object(
 		'Schema', n.nspname,
 		'Name', t.typname
@@ -0,0 +1,192 @@
+// Copyright 2025 Christophe Eymard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"gitlab.com/tozd/go/errors"
+)
+
+type viewDefinition struct {
+	PgRelId  int
+	PgAction string // pg_rewrite.ev_action of the _RETURN rule
+}
+
+// Find out where the columns of views come from.
+// Relations must have been filled beforehand.
+func FillViewInformations(ctx context.Context, infos *DbInfos, conn *pgx.Conn) error {
+	args, err := infos.Options.relationFilterArgs()
+	if err != nil {
+		return err
+	}
+
+	var views []viewDefinition
+	if err := scanIntoThroughJsonAgg(ctx, conn, INFO_QUERY_VIEWS, &views, args...); err != nil {
+		return err
+	}
+
+	var relations = make(map[int]*Relation, len(infos.Relations))
+	for _, r := range infos.Relations {
+		relations[r.PgRelId] = r
+	}
+
+	for _, v := range views {
+		rel, ok := relations[v.PgRelId]
+		if !ok {
+			continue
+		}
+
+		if err := fillViewColumnOrigins(rel, v.PgAction); err != nil {
+			return errors.Errorf("view %s: %w", rel.Identifier.String(), err)
+		}
+	}
+
+	return nil
+}
+
+// The top level target list of the view's query tells for each column which table column
+// it was directly taken from, if any, in resorigtbl and resorigcol.
+func fillViewColumnOrigins(rel *Relation, action string) error {
+	tree, err := parsePgNodeTree(action)
+	if err != nil {
+		return err
+	}
+
+	queries, _ := tree.([]any)
+	if len(queries) == 0 {
+		return errors.Errorf("no query found in rewrite rule")
+	}
+
+	query, ok := queries[0].(*pgNode)
+	if !ok || query.Type != "QUERY" {
+		return errors.Errorf("unexpected node in rewrite rule")
+	}
+
+	for _, item := range query.List("targetList") {
+		entry, ok := item.(*pgNode)
+		if !ok || entry.Type != "TARGETENTRY" || entry.Bool("resjunk") {
+			continue
+		}
+
+		col := rel.GetColumnByIndex(entry.Int("resno"))
+		if col == nil || entry.Int("resorigtbl") == 0 {
+			continue
+		}
+
+		col.PgBaseRelId = entry.Int("resorigtbl")
+		col.PgBaseColumnIndex = entry.Int("resorigcol")
+	}
+
+	return nil
+}
+
+type foreignKeySide struct {
+	relation *Relation
+	columns  []*Column
+}
+
+// Views that expose all the columns on one side of a foreign key get it as well, just like PostgREST does.
+// Relationships are created between every pair of relations that expose both sides, except for the
+// original pair of tables which was linked already.
+func inheritViewForeignKeys(infos *DbInfos) {
+	var exposed_by = make(map[*Column][]*Column)
+
+	for _, r := range infos.Relations {
+		if !r.IsView && !r.IsMaterializedView {
+			continue
+		}
+		for _, c := range r.Columns {
+			if source := c.SourceColumn(); source != nil {
+				exposed_by[source] = append(exposed_by[source], c)
+			}
+		}
+	}
+
+	if len(exposed_by) == 0 {
+		return
+	}
+
+	var relation_of = make(map[*Column]*Relation)
+	for _, r := range infos.Relations {
+		for _, c := range r.Columns {
+			relation_of[c] = r
+		}
+	}
+
+	var sides = func(rel *Relation, columns []*Column) []foreignKeySide {
+		var res = []foreignKeySide{{relation: rel, columns: columns}}
+		var found = make(map[*Relation][]*Column)
+		var order []*Relation
+
+		for i, col := range columns {
+			for _, view_col := range exposed_by[col] {
+				view := relation_of[view_col]
+				// A view may expose the same column several times, only keep the first one.
+				if len(found[view]) != i {
+					continue
+				}
+				if i == 0 {
+					order = append(order, view)
+				}
+				found[view] = append(found[view], view_col)
+			}
+		}
+
+		for _, view := range order {
+			if len(found[view]) == len(columns) {
+				res = append(res, foreignKeySide{relation: view, columns: found[view]})
+			}
+		}
+		return res
+	}
+
+	for _, fk := range infos.ForeignKeys {
+		self := infos.GetRelation(fk.PgRelId)
+		other := infos.GetRelation(fk.PgOtherRelId)
+		if self == nil || other == nil {
+			continue
+		}
+
+		self_columns, err := getColumnsByName(self, fk.ColumnNames)
+		if err != nil {
+			continue
+		}
+		other_columns, err := getColumnsByName(other, fk.OtherColumnNames)
+		if err != nil {
+			continue
+		}
+
+		for i, s := range sides(self, self_columns) {
+			for j, o := range sides(other, other_columns) {
+				if i == 0 && j == 0 {
+					continue
+				}
+				linkForeignKey(fk, s.relation, s.columns, o.relation, o.columns)
+			}
+		}
+	}
+}
+
+var INFO_QUERY_VIEWS = /* sql */ `
+SELECT json_agg(V) FROM (SELECT
+	r.ev_class::integer AS "PgRelId",
+	r.ev_action::text AS "PgAction"
+FROM pg_rewrite r
+INNER JOIN pg_class c ON c.oid = r.ev_class
+WHERE r.rulename = '_RETURN' AND c.relkind IN ('v', 'm')
+	AND ` + relationFilter("c.oid") + `
+) V;`
@@ -0,0 +1,437 @@
+// Copyright 2025 Christophe Eymard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg
+
+import (
+	"fmt"
+	"slices"
+
+	"gitlab.com/tozd/go/errors"
+)
+
+var (
+	ErrOperatorNotFound  = errors.Base("operator does not exist")
+	ErrOperatorNotUnique = errors.Base("operator is not unique")
+)
+
+// Find the operator that postgres would call for the given operand types, following
+// https://www.postgresql.org/docs/current/typeconv-oper.html
+//
+// left is nil for a prefix operator. String literals that were not given a type should be passed as the unknown type,
+// which is what lets 'abc' || 'def' or id = '12' resolve.
+func (db *DbInfos) ResolveOperator(name string, left *Type, right *Type) (*Operator, error) {
+	var prefix = left == nil
+	var candidates = db.operatorCandidates(name, prefix)
+
+	var inputs = []*Type{left, right}
+	var signatures = make([][]*Type, len(candidates))
+	for i, o := range candidates {
+		signatures[i] = []*Type{o.LeftType, o.RightType}
+	}
+	if prefix {
+		inputs = inputs[1:]
+		for i := range signatures {
+			signatures[i] = signatures[i][1:]
+		}
+	}
+
+	if op := findExactOperator(candidates, inputs); op != nil {
+		return op, nil
+	}
+
+	var best = db.bestCandidates(inputs, signatures)
+	switch len(best) {
+	case 1:
+		return candidates[best[0]], nil
+	case 0:
+		return nil, errors.Errorf("%w: %s", ErrOperatorNotFound, operatorSignature(name, left, right))
+	default:
+		return nil, errors.Errorf("%w: %s", ErrOperatorNotUnique, operatorSignature(name, left, right))
+	}
+}
+
+func operatorSignature(name string, left *Type, right *Type) string {
+	if left == nil {
+		return fmt.Sprintf("%s %s", name, typeName(right))
+	}
+	return fmt.Sprintf("%s %s %s", typeName(left), name, typeName(right))
+}
+
+// The operators with this name that are visible from the search path, pg_catalog coming first unless it is part of it.
+// An operator hides the ones with the same operand types in the schemas that come after it.
+func (db *DbInfos) operatorCandidates(name string, prefix bool) []*Operator {
+	var path = db.Options.SearchPath()
+	if !slices.Contains(path, "pg_catalog") {
+		path = append([]string{"pg_catalog"}, path...)
+	}
+
+	var seen = make(map[[2]int]bool)
+	var res []*Operator
+	for _, schema := range path {
+		for _, o := range db.OperatorMapByName[name] {
+			if o.Identifier.Schema != schema || o.IsPrefix() != prefix {
+				continue
+			}
+			var key = [2]int{o.PgLeftTypeOid, o.PgRightTypeOid}
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			res = append(res, o)
+		}
+	}
+	return res
+}
+
+// An unknown operand is assumed to be of the same type as the other one. If that type is a domain, its base
+// type is tried as well.
+func findExactOperator(candidates []*Operator, inputs []*Type) *Operator {
+	var find = func(types []*Type) *Operator {
+		for _, o := range candidates {
+			var sig = []*Type{o.LeftType, o.RightType}[2-len(types):]
+			var match = true
+			for i, t := range types {
+				if t == nil || sig[i] == nil || t.PgOid != sig[i].PgOid {
+					match = false
+					break
+				}
+			}
+			if match {
+				return o
+			}
+		}
+		return nil
+	}
+
+	if len(inputs) == 1 {
+		return find(inputs)
+	}
+
+	var left, right = inputs[0], inputs[1]
+	var was_unknown = false
+	if left.IsUnknown() && !right.IsUnknown() {
+		left, was_unknown = right, true
+	} else if right.IsUnknown() && !left.IsUnknown() {
+		right, was_unknown = left, true
+	}
+
+	if op := find([]*Type{left, right}); op != nil {
+		return op
+	}
+
+	if was_unknown && left.IsDomain() {
+		var base = left.UnderlyingType()
+		return find([]*Type{base, base})
+	}
+	return nil
+}
+
+// Narrow down candidate signatures for the input types, returning the indexes of the ones that remain.
+// These are the steps postgres goes through for both operators and functions once it found no exact match.
+func (db *DbInfos) bestCandidates(inputs []*Type, candidates [][]*Type) []int {
+	// Discard the candidates the inputs can't be converted to
+	var keep []int
+	for i, sig := range candidates {
+		if db.acceptsInputs(inputs, sig) {
+			keep = append(keep, i)
+		}
+	}
+	if len(keep) <= 1 {
+		return keep
+	}
+
+	// Domains count as their base type from now on
+	var base = make([]*Type, len(inputs))
+	var has_unknown = false
+	for i, t := range inputs {
+		base[i] = t.UnderlyingType()
+		if t.IsUnknown() {
+			has_unknown = true
+		}
+	}
+
+	// Most exact matches
+	keep = keepBest(keep, func(idx int) int {
+		var count = 0
+		for i, t := range candidates[idx] {
+			if !base[i].IsUnknown() && t.PgOid == base[i].PgOid {
+				count++
+			}
+		}
+		return count
+	})
+	if len(keep) == 1 {
+		return keep
+	}
+
+	// Most preferred types where a conversion is needed
+	keep = keepBest(keep, func(idx int) int {
+		var count = 0
+		for i, t := range candidates[idx] {
+			if base[i].IsUnknown() {
+				continue
+			}
+			if t.PgOid == base[i].PgOid || t.Category == base[i].Category && t.IsPreferred {
+				count++
+			}
+		}
+		return count
+	})
+	if len(keep) == 1 || !has_unknown {
+		return keep
+	}
+
+	// Unknown inputs go to the string category if a candidate accepts it, or to the only category the candidates accept.
+	var categories = make([]string, len(inputs))
+	var preferred = make([]bool, len(inputs))
+	var resolved = true
+	for i, t := range base {
+		if !t.IsUnknown() {
+			continue
+		}
+
+		var conflict = false
+		for n, idx := range keep {
+			var cand = candidates[idx][i]
+			switch {
+			case n == 0:
+				categories[i], preferred[i] = cand.Category, cand.IsPreferred
+			case cand.Category == categories[i]:
+				preferred[i] = preferred[i] || cand.IsPreferred
+			case cand.Category == CATEGORY_STRING:
+				categories[i], preferred[i] = cand.Category, cand.IsPreferred
+			case categories[i] != CATEGORY_STRING:
+				conflict = true
+			}
+		}
+
+		if conflict && categories[i] != CATEGORY_STRING {
+			resolved = false
+			break
+		}
+	}
+
+	if resolved {
+		var filtered []int
+		for _, idx := range keep {
+			var ok = true
+			for i, t := range base {
+				var cand = candidates[idx][i]
+				if t.IsUnknown() && (cand.Category != categories[i] || preferred[i] && !cand.IsPreferred) {
+					ok = false
+					break
+				}
+			}
+			if ok {
+				filtered = append(filtered, idx)
+			}
+		}
+		if len(filtered) > 0 {
+			keep = filtered
+		}
+		if len(keep) == 1 {
+			return keep
+		}
+	}
+
+	// When all the known inputs have the same type, unknown ones are assumed to be of that type too
+	var known *Type
+	for _, t := range base {
+		if t.IsUnknown() {
+			continue
+		}
+		if known != nil && known.PgOid != t.PgOid {
+			return keep
+		}
+		known = t
+	}
+	if known == nil {
+		return keep
+	}
+
+	var filtered []int
+	for _, idx := range keep {
+		var ok = true
+		for i, t := range base {
+			if t.IsUnknown() && !db.CanCoerceImplicitly(known, candidates[idx][i]) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			filtered = append(filtered, idx)
+		}
+	}
+	if len(filtered) == 1 {
+		return filtered
+	}
+	return keep
+}
+
+func keepBest(keep []int, score func(idx int) int) []int {
+	var best = -1
+	var res []int
+	for _, idx := range keep {
+		var s = score(idx)
+		if s > best {
+			best, res = s, nil
+		}
+		if s == best {
+			res = append(res, idx)
+		}
+	}
+	return res
+}
+
+func (db *DbInfos) acceptsInputs(inputs []*Type, sig []*Type) bool {
+	if len(inputs) != len(sig) {
+		return false
+	}
+
+	// Arguments declared with the same polymorphic family have to agree on their element type
+	var element *Type
+	for i, t := range inputs {
+		if !db.CanCoerceImplicitly(t, sig[i]) {
+			return false
+		}
+		if elt := polymorphicElement(t, sig[i]); elt != nil {
+			if element != nil && element.PgOid != elt.PgOid {
+				return false
+			}
+			element = elt
+		}
+	}
+	return true
+}
+
+// Tell if a value of type from can be given where to is expected without an explicit cast, which is
+// what postgres does with the arguments of operators and functions.
+func (db *DbInfos) CanCoerceImplicitly(from *Type, to *Type) bool {
+	if from == nil || to == nil {
+		return from == to
+	}
+
+	if from.PgOid == to.PgOid || from.IsUnknown() {
+		return true
+	}
+
+	if to.IsPolymorphic() {
+		return acceptsPolymorphic(from.UnderlyingType(), to)
+	}
+
+	if to.IsPseudo() && to.PgIdentifier.Name == "record" && from.IsComposite() {
+		return true
+	}
+
+	if from.IsDomain() {
+		return db.CanCoerceImplicitly(from.BaseType, to)
+	}
+
+	if c := db.GetCast(from, to); c != nil && c.IsImplicit() {
+		return true
+	}
+
+	if from.IsArray() && to.IsArray() {
+		return db.CanCoerceImplicitly(from.ElementType, to.ElementType)
+	}
+
+	return false
+}
+
+// Pseudo types like anyelement or anyarray, that take the type of what is given to them.
+func (t *Type) IsPolymorphic() bool {
+	if !t.IsPseudo() || t.PgIdentifier.Schema != "pg_catalog" {
+		return false
+	}
+	switch t.PgIdentifier.Name {
+	case "any", "anyelement", "anyarray", "anynonarray", "anyenum", "anyrange", "anymultirange",
+		"anycompatible", "anycompatiblearray", "anycompatiblenonarray", "anycompatiblerange", "anycompatiblemultirange":
+		return true
+	}
+	return false
+}
+
+func acceptsPolymorphic(from *Type, to *Type) bool {
+	if from.IsUnknown() {
+		return true
+	}
+	switch to.PgIdentifier.Name {
+	case "anyarray", "anycompatiblearray":
+		return from.IsArray()
+	case "anynonarray", "anycompatiblenonarray":
+		return !from.IsArray()
+	case "anyenum":
+		return from.IsEnum()
+	case "anyrange", "anycompatiblerange":
+		return from.IsRange()
+	case "anymultirange", "anycompatiblemultirange":
+		return from.IsMultirange()
+	}
+	return true
+}
+
+// The element type a value of type from gives to an anyelement-like type, when to belongs to the
+// anyelement family. The anycompatible family only has to find a common type, so it is not checked.
+func polymorphicElement(from *Type, to *Type) *Type {
+	if !to.IsPolymorphic() || from.IsUnknown() {
+		return nil
+	}
+	from = from.UnderlyingType()
+	switch to.PgIdentifier.Name {
+	case "anyelement", "anynonarray", "anyenum":
+		return from
+	case "anyarray":
+		return from.ElementType
+	case "anyrange":
+		return from.RangeSubtype
+	case "anymultirange":
+		if from.RangeType != nil {
+			return from.RangeType.RangeSubtype
+		}
+	}
+	return nil
+}
+
+// The type of the result of the operator for these operands, which is only different from ResultType when it is polymorphic.
+func (o *Operator) ResultTypeFor(left *Type, right *Type) *Type {
+	if !o.ResultType.IsPolymorphic() {
+		return o.ResultType
+	}
+
+	var element *Type
+	for i, t := range []*Type{left, right} {
+		var sig = []*Type{o.LeftType, o.RightType}[i]
+		if t == nil || sig == nil {
+			continue
+		}
+		if elt := polymorphicElement(t, sig); elt != nil {
+			element = elt
+			break
+		}
+	}
+	if element == nil {
+		return o.ResultType
+	}
+
+	switch o.ResultType.PgIdentifier.Name {
+	case "anyelement", "anynonarray", "anyenum":
+		return element
+	case "anyarray":
+		if element.ArrayType != nil {
+			return element.ArrayType
+		}
+	}
+	return o.ResultType
+}
@@ -0,0 +1,183 @@
+// Copyright 2025 Christophe Eymard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"gitlab.com/tozd/go/errors"
+)
+
+// A type of the operators fixture, "name" being in pg_catalog and "schema.name" elsewhere
+func fixtureType(t *testing.T, db *DbInfos, name string) *Type {
+	t.Helper()
+	if name == "" {
+		return nil
+	}
+	var schema = "pg_catalog"
+	if s, n, ok := strings.Cut(name, "."); ok {
+		schema, name = s, n
+	}
+	return typeByName(t, db, schema, name)
+}
+
+func TestResolveOperator(t *testing.T) {
+	var db = loadFixture(t, "operators.json")
+
+	// The operators are identified by their oid in the fixture
+	var tests = []struct {
+		left  string
+		op    string
+		right string
+		oid   int
+		err   error
+	}{
+		// Exact matches, an unknown operand taking the type of the other one
+		{"int4", "=", "int4", 96, nil},
+		{"int4", "=", "int8", 15, nil},
+		{"int4", "=", "unknown", 96, nil},
+		{"unknown", "=", "int8", 410, nil},
+		{"text", "||", "unknown", 654, nil},
+		{"", "-", "int4", 558, nil},
+
+		// Domains are matched through their base type
+		{"api.positive_int", "=", "unknown", 96, nil},
+		{"api.positive_int", "=", "api.positive_int", 96, nil},
+		{"api.email", "=", "unknown", 98, nil},
+
+		// Most exact matches, then most preferred types
+		{"int4", "+", "numeric", 1758, nil},
+		{"int2", "+", "int2", 591, nil},
+
+		// Unknown operands prefer the string category
+		{"unknown", "||", "unknown", 654, nil},
+		{"int4", "||", "text", 2780, nil},
+
+		// anyarray operands have to agree on their element type
+		{"_int4", "@>", "_int4", 2751, nil},
+		{"_int4", "@>", "unknown", 2751, nil},
+		{"_int4", "@>", "_text", 0, ErrOperatorNotFound},
+		{"int4", "@>", "_int4", 0, ErrOperatorNotFound},
+
+		// Operators outside of the search path are not candidates, so int2 # int2 is not found in internal
+		{"int2", "#", "int2", 0, ErrOperatorNotUnique},
+		{"", "-", "unknown", 0, ErrOperatorNotUnique},
+		{"text", "+", "int4", 0, ErrOperatorNotFound},
+		{"int4", "=", "text", 0, ErrOperatorNotFound},
+		{"int4", "<=>", "int4", 0, ErrOperatorNotFound},
+	}
+
+	for _, test := range tests {
+		var left, right = fixtureType(t, db, test.left), fixtureType(t, db, test.right)
+		var desc = strings.TrimSpace(test.left + " " + test.op + " " + test.right)
+
+		op, err := db.ResolveOperator(test.op, left, right)
+		if test.err != nil {
+			if !errors.Is(err, test.err) {
+				t.Errorf("%s: expected %q, got %v %v", desc, test.err, op, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", desc, err)
+		} else if op.PgOid != test.oid {
+			t.Errorf("%s: got %s (%d), expected %d", desc, op.String(), op.PgOid, test.oid)
+		}
+	}
+}
+
+func TestResultTypeFor(t *testing.T) {
+	var db = loadFixture(t, "operators.json")
+	var int4, arr = fixtureType(t, db, "int4"), fixtureType(t, db, "_int4")
+
+	if res := db.GetOperator(2751).ResultTypeFor(arr, arr); res.PgIdentifier.Name != "bool" {
+		t.Errorf("@> gives %s, expected bool", typeName(res))
+	}
+	if res := db.GetOperator(551).ResultTypeFor(int4, int4); res != int4 {
+		t.Errorf("+ gives %s, expected int4", typeName(res))
+	}
+}
+
+func TestBestCandidates(t *testing.T) {
+	var db = loadFixture(t, "operators.json")
+	var types = func(names ...string) []*Type {
+		var res []*Type
+		for _, n := range names {
+			res = append(res, fixtureType(t, db, n))
+		}
+		return res
+	}
+
+	var tests = []struct {
+		inputs     []*Type
+		candidates [][]*Type
+		best       []int
+	}{
+		{types("unknown"), [][]*Type{types("int4"), types("text")}, []int{1}},
+		{types("unknown"), [][]*Type{types("int4"), types("int8")}, []int{0, 1}},
+		{types("unknown"), [][]*Type{types("int4"), types("float8")}, []int{1}},
+		{types("int2"), [][]*Type{types("int4"), types("text")}, []int{0}},
+		{types("int8"), [][]*Type{types("int4"), types("text")}, nil},
+		{types("int4", "unknown"), [][]*Type{types("int4", "int2"), types("int4", "int8")}, []int{1}},
+		{types("int4", "unknown"), [][]*Type{types("int8", "int8"), types("numeric", "numeric")}, []int{0, 1}},
+		{types("int4", "int4"), [][]*Type{types("int4")}, nil},
+	}
+
+	for i, test := range tests {
+		if got := db.bestCandidates(test.inputs, test.candidates); !slices.Equal(got, test.best) {
+			t.Errorf("%d: got %v, expected %v", i, got, test.best)
+		}
+	}
+}
+
+func TestCanCoerceImplicitly(t *testing.T) {
+	var db = loadFixture(t, "operators.json")
+
+	var tests = []struct {
+		from string
+		to   string
+		ok   bool
+	}{
+		{"int2", "int4", true},
+		{"int4", "int4", true},
+		{"int8", "int4", false},
+		{"float8", "numeric", false},
+		{"unknown", "int8", true},
+		{"varchar", "text", true},
+		{"int4", "text", false},
+		{"api.positive_int", "int4", true},
+		{"api.positive_int", "int8", true},
+		{"api.email", "varchar", true},
+		{"int4", "api.positive_int", false},
+		{"_int2", "_int4", true},
+		{"_int4", "_int2", false},
+		{"_int4", "anyarray", true},
+		{"int4", "anyarray", false},
+		{"int4", "anynonarray", true},
+		{"_int4", "anynonarray", false},
+		{"api.positive_int", "anynonarray", true},
+		{"", "", true},
+		{"int4", "", false},
+	}
+
+	for _, test := range tests {
+		var from, to = fixtureType(t, db, test.from), fixtureType(t, db, test.to)
+		if got := db.CanCoerceImplicitly(from, to); got != test.ok {
+			t.Errorf("%s to %s: got %v, expected %v", test.from, test.to, got, test.ok)
+		}
+	}
+}
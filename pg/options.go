@@ -0,0 +1,276 @@
+// Copyright 2025 Christophe Eymard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg
+
+import (
+	"slices"
+	"strings"
+
+	"gitlab.com/tozd/go/errors"
+)
+
+// Options restrict what is loaded from the catalog.
+//
+// Types and roles are always loaded entirely, since relations and functions refer to them.
+type Options struct {
+	// Schemas to load relations and functions from. When empty, all the schemas are loaded except
+	// information_schema and the ones starting with pg_, unless they are listed here.
+	// The relations that loaded views are built upon are loaded as well, with their foreign keys, wherever
+	// they are, so that the views can inherit them. Only the exposed schemas are visible to queries.
+	Schemas        []string
+	ExcludeSchemas []string
+
+	// Glob patterns that are matched against "schema.name", where * matches any run of characters, ? any
+	// single one, [...] one of a set and \ escapes the next character.
+	// An object is loaded if it matches one of the include patterns, or if there are none, and none of the exclude ones.
+	Relations        []string
+	ExcludeRelations []string
+	Functions        []string
+	ExcludeFunctions []string
+
+	// The schemas unqualified names are looked up in, in order, like search_path.
+	// It defaults to Schemas, or to public if Schemas is empty.
+	ExposedSchemas []string
+}
+
+// Arguments for the queries that use schemaFilter.
+func (o *Options) schemaFilterArgs() []any {
+	var include []string
+	if len(o.Schemas) > 0 {
+		include = o.Schemas
+	}
+	var exclude = o.ExcludeSchemas
+	if exclude == nil {
+		exclude = []string{}
+	}
+	return []any{include, exclude, len(o.Schemas) == 0}
+}
+
+// A condition that tells if a schema name should be loaded, to be used with the arguments given by schemaFilterArgs.
+func schemaFilter(column string) string {
+	return /* sql */ `(
+		($1::text[] IS NULL OR ` + column + ` = ANY($1::text[]))
+		AND NOT ` + column + ` = ANY($2::text[])
+		AND NOT ($3::boolean AND (` + column + ` LIKE 'pg\_%' OR ` + column + ` = 'information_schema'))
+	)`
+}
+
+// Arguments for the queries that use nameFilter, which come after the ones of schemaFilterArgs.
+func nameFilterArgs(include []string, exclude []string) ([]any, error) {
+	var include_re, exclude_re []string
+	for _, p := range include {
+		re, err := globToRegexp(p)
+		if err != nil {
+			return nil, err
+		}
+		include_re = append(include_re, re)
+	}
+
+	exclude_re = []string{}
+	for _, p := range exclude {
+		re, err := globToRegexp(p)
+		if err != nil {
+			return nil, err
+		}
+		exclude_re = append(exclude_re, re)
+	}
+
+	return []any{include_re, exclude_re}, nil
+}
+
+func (o *Options) relationFilterArgs() ([]any, error) {
+	args, err := nameFilterArgs(o.Relations, o.ExcludeRelations)
+	if err != nil {
+		return nil, errors.Errorf("invalid relation pattern: %w", err)
+	}
+	return append(o.schemaFilterArgs(), args...), nil
+}
+
+func (o *Options) functionFilterArgs() ([]any, error) {
+	args, err := nameFilterArgs(o.Functions, o.ExcludeFunctions)
+	if err != nil {
+		return nil, errors.Errorf("invalid function pattern: %w", err)
+	}
+	return append(o.schemaFilterArgs(), args...), nil
+}
+
+// A condition that tells if schema.name matches the patterns given by nameFilterArgs, as $4 and $5.
+func nameFilter(schema_column string, name_column string) string {
+	var name = `(` + schema_column + ` || '.' || ` + name_column + `)`
+	return /* sql */ `(
+		($4::text[] IS NULL OR ` + name + ` ~ ANY($4::text[]))
+		AND NOT ` + name + ` ~ ANY($5::text[])
+	)`
+}
+
+// A condition that tells if a relation should be loaded, to be used with the arguments given by relationFilterArgs.
+// The relations that match the filters are loaded along with the ones their views depend on, recursively.
+func relationFilter(oid_column string) string {
+	return oid_column + /* sql */ ` IN (
+		WITH RECURSIVE loaded(oid) AS (
+			SELECT c.oid FROM pg_class c
+			INNER JOIN pg_namespace n ON n.oid = c.relnamespace
+			WHERE c.relkind IN ('r', 'v', 'm', 'f', 'p')
+				AND ` + schemaFilter("n.nspname") + `
+				AND ` + nameFilter("n.nspname", "c.relname") + `
+		UNION
+			SELECT d.refobjid FROM loaded
+			INNER JOIN pg_rewrite rw ON rw.ev_class = loaded.oid AND rw.rulename = '_RETURN'
+			INNER JOIN pg_depend d ON d.classid = 'pg_rewrite'::regclass AND d.objid = rw.oid AND d.refclassid = 'pg_class'::regclass
+			INNER JOIN pg_class base ON base.oid = d.refobjid AND base.relkind IN ('r', 'v', 'm', 'f', 'p')
+		)
+		SELECT oid FROM loaded
+	)`
+}
+
+// Turn a glob pattern into an anchored postgres regular expression
+func globToRegexp(pattern string) (string, error) {
+	var b strings.Builder
+	b.WriteString("^")
+
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		case '\\':
+			if i+1 >= len(pattern) {
+				return "", errors.Errorf("%s: nothing to escape at the end", pattern)
+			}
+			i++
+			b.WriteString(regexpQuote(pattern[i]))
+		case '[':
+			var start = i + 1
+			var negated = start < len(pattern) && (pattern[start] == '!' || pattern[start] == '^')
+			if negated {
+				start++
+			}
+			var end = start
+			// A ] right after the opening bracket is part of the set
+			if end < len(pattern) && pattern[end] == ']' {
+				end++
+			}
+			for end < len(pattern) && pattern[end] != ']' {
+				if pattern[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(pattern) {
+				return "", errors.Errorf("%s: unclosed [", pattern)
+			}
+			// Shells negate sets with [!...], regular expressions with [^...]
+			b.WriteString("[")
+			if negated {
+				b.WriteString("^")
+			}
+			b.WriteString(pattern[start : end+1])
+			i = end
+		default:
+			b.WriteString(regexpQuote(c))
+		}
+	}
+
+	b.WriteString("$")
+	return b.String(), nil
+}
+
+func regexpQuote(c byte) string {
+	if strings.IndexByte(`.+*?()[]{}|^$\`, c) >= 0 {
+		return `\` + string(c)
+	}
+	return string(c)
+}
+
+// The schemas to look unqualified names up in
+func (o *Options) SearchPath() []string {
+	if len(o.ExposedSchemas) > 0 {
+		return o.ExposedSchemas
+	}
+	if len(o.Schemas) > 0 {
+		return o.Schemas
+	}
+	return []string{"public"}
+}
+
+func (o *Options) IsExposed(schema string) bool {
+	return slices.Contains(o.SearchPath(), schema)
+}
+
+// Find a relation by name. An empty schema looks it up in the exposed schemas, in order.
+func (db *DbInfos) LookupRelation(schema string, name string) *Relation {
+	return db.LookupRelationIn(db.Options.SearchPath(), schema, name)
+}
+
+// Find a relation by name, an empty schema looking it up in the given search path. Relations outside of the
+// exposed schemas are not found, even schema-qualified, so that a query can't tell them from relations that
+// don't exist.
+func (db *DbInfos) LookupRelationIn(search_path []string, schema string, name string) *Relation {
+	if schema != "" {
+		if !db.Options.IsExposed(schema) {
+			return nil
+		}
+		return db.RelationMapByName[SqlIdentifier{Schema: schema, Name: name}.String()]
+	}
+
+	for _, s := range search_path {
+		if !db.Options.IsExposed(s) {
+			continue
+		}
+		if r, ok := db.RelationMapByName[SqlIdentifier{Schema: s, Name: name}.String()]; ok {
+			return r
+		}
+	}
+	return nil
+}
+
+// Find the overloads of a function by name. An empty schema looks it up in the exposed schemas, in order,
+// and stops at the first schema that has a function with this name.
+func (db *DbInfos) LookupFunctions(schema string, name string) []*Function {
+	return db.LookupFunctionsIn(db.Options.SearchPath(), schema, name)
+}
+
+// Find the overloads of a function by name, an empty schema looking it up in the given search path.
+// Just like relations, functions outside of the exposed schemas are not found.
+func (db *DbInfos) LookupFunctionsIn(search_path []string, schema string, name string) []*Function {
+	if schema != "" {
+		if !db.Options.IsExposed(schema) {
+			return nil
+		}
+		return db.GetFunctionOverloads(SqlIdentifier{Schema: schema, Name: name})
+	}
+
+	for _, s := range search_path {
+		if !db.Options.IsExposed(s) {
+			continue
+		}
+		if fns := db.GetFunctionOverloads(SqlIdentifier{Schema: s, Name: name}); len(fns) > 0 {
+			return fns
+		}
+	}
+	return nil
+}
+
+// The relations of the exposed schemas
+func (db *DbInfos) ExposedRelations() []*Relation {
+	var res []*Relation
+	for _, r := range db.Relations {
+		if db.Options.IsExposed(r.Identifier.Schema) {
+			res = append(res, r)
+		}
+	}
+	return res
+}
@@ -0,0 +1,94 @@
+// Copyright 2025 Christophe Eymard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg
+
+import (
+	"regexp"
+	"testing"
+)
+
+// The expressions are checked with Go's regexp, which agrees with postgres on the syntax globToRegexp produces
+func TestGlobToRegexp(t *testing.T) {
+	var tests = []struct {
+		pattern string
+		regexp  string
+		matches []string
+		misses  []string
+	}{
+		{`api.*`, `^api\..*$`, []string{"api.orders", "api."}, []string{"apix.orders", "shop.api.orders"}},
+		{`*.audit_*`, `^.*\.audit_.*$`, []string{"internal.audit_log"}, []string{"internal.audits"}},
+		{`api.order?`, `^api\.order.$`, []string{"api.orders", "api.order_"}, []string{"api.order", "api.orders2"}},
+		{`api.[a-c]*`, `^api\.[a-c].*$`, []string{"api.customers"}, []string{"api.orders"}},
+		{`api.[^a-c]*`, `^api\.[^a-c].*$`, []string{"api.orders"}, []string{"api.customers"}},
+		{`api.[!a-c]*`, `^api\.[^a-c].*$`, []string{"api.orders"}, []string{"api.customers"}},
+		{`api.[!]x]`, `^api\.[^]x]$`, []string{"api.y"}, []string{"api.]", "api.x"}},
+		{`api.[]x]`, `^api\.[]x]$`, []string{"api.]", "api.x"}, []string{"api.y"}},
+		{`api.a\*b`, `^api\.a\*b$`, []string{"api.a*b"}, []string{"api.aab"}},
+		{`api.a+b(c)`, `^api\.a\+b\(c\)$`, []string{"api.a+b(c)"}, []string{"api.aab c"}},
+	}
+
+	for _, test := range tests {
+		re, err := globToRegexp(test.pattern)
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", test.pattern, err)
+			continue
+		}
+		if re != test.regexp {
+			t.Errorf("%s: got %s, expected %s", test.pattern, re, test.regexp)
+			continue
+		}
+
+		var compiled = regexp.MustCompile(re)
+		for _, name := range test.matches {
+			if !compiled.MatchString(name) {
+				t.Errorf("%s: should match %s", test.pattern, name)
+			}
+		}
+		for _, name := range test.misses {
+			if compiled.MatchString(name) {
+				t.Errorf("%s: should not match %s", test.pattern, name)
+			}
+		}
+	}
+
+	for _, pattern := range []string{`api.[a-c`, `api.x\`, `api.[]`} {
+		if _, err := globToRegexp(pattern); err == nil {
+			t.Errorf("%s: expected an error", pattern)
+		}
+	}
+}
+
+func TestNameFilterArgs(t *testing.T) {
+	var opts = Options{Relations: []string{"api.*"}}
+	args, err := opts.relationFilterArgs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(args) != 5 {
+		t.Fatalf("expected the 3 arguments of schemaFilter and 2 of nameFilter, got %d", len(args))
+	}
+	if exclude, ok := args[4].([]string); !ok || exclude == nil {
+		t.Errorf("exclusions have to be an empty array rather than NULL, got %#v", args[4])
+	}
+
+	if args, _ := opts.functionFilterArgs(); args[3].([]string) != nil {
+		t.Errorf("no function pattern has to give a NULL array, got %#v", args[3])
+	}
+
+	opts.ExcludeFunctions = []string{"api.[x"}
+	if _, err := opts.functionFilterArgs(); err == nil {
+		t.Error("expected an error for an unclosed [")
+	}
+}
@@ -0,0 +1,219 @@
+// Copyright 2025 Christophe Eymard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg
+
+import (
+	"strconv"
+	"strings"
+
+	"gitlab.com/tozd/go/errors"
+)
+
+// A node of a pg_node_tree, such as the ones found in pg_rewrite.ev_action.
+// Values of its fields are either *pgNode, []any for lists, string for scalars or nil.
+type pgNode struct {
+	Type   string
+	Fields map[string]any
+}
+
+func (n *pgNode) Node(field string) *pgNode {
+	if n == nil {
+		return nil
+	}
+	res, _ := n.Fields[field].(*pgNode)
+	return res
+}
+
+func (n *pgNode) List(field string) []any {
+	if n == nil {
+		return nil
+	}
+	res, _ := n.Fields[field].([]any)
+	return res
+}
+
+func (n *pgNode) String(field string) string {
+	if n == nil {
+		return ""
+	}
+	res, _ := n.Fields[field].(string)
+	return res
+}
+
+func (n *pgNode) Int(field string) int {
+	res, _ := strconv.Atoi(n.String(field))
+	return res
+}
+
+func (n *pgNode) Bool(field string) bool {
+	return n.String(field) == "true"
+}
+
+// Parse the textual representation of a pg_node_tree.
+// It follows what pg_strtok does in src/backend/nodes/read.c.
+func parsePgNodeTree(src string) (any, error) {
+	var p = &pgNodeParser{tokens: tokenizePgNodeTree(src)}
+	res, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, errors.Errorf("unexpected token %q at the end of the node tree", p.tokens[p.pos])
+	}
+	return res, nil
+}
+
+type pgNodeParser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *pgNodeParser) next() (string, bool) {
+	if p.pos >= len(p.tokens) {
+		return "", false
+	}
+	p.pos++
+	return p.tokens[p.pos-1], true
+}
+
+func (p *pgNodeParser) peek() string {
+	if p.pos >= len(p.tokens) {
+		return ""
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *pgNodeParser) parseValue() (any, error) {
+	tk, ok := p.next()
+	if !ok {
+		return nil, errors.Errorf("unexpected end of node tree")
+	}
+
+	switch tk {
+	case "<>":
+		return nil, nil
+	case `""`:
+		// The empty string is written as "" to tell it apart from the absence of a token
+		return "", nil
+	case "{":
+		return p.parseNode()
+	case "(", "[":
+		var closing = ")"
+		if tk == "[" {
+			closing = "]"
+		}
+		var list = []any{}
+		for p.peek() != closing {
+			if p.pos >= len(p.tokens) {
+				return nil, errors.Errorf("unterminated list in node tree")
+			}
+			item, err := p.parseValue()
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, item)
+		}
+		p.pos++
+		return list, nil
+	}
+
+	return tk, nil
+}
+
+func (p *pgNodeParser) parseNode() (*pgNode, error) {
+	name, ok := p.next()
+	if !ok {
+		return nil, errors.Errorf("unterminated node in node tree")
+	}
+
+	var node = &pgNode{Type: name, Fields: make(map[string]any)}
+
+	for {
+		tk := p.peek()
+		if tk == "" {
+			return nil, errors.Errorf("unterminated node %s in node tree", name)
+		}
+
+		if tk == "}" {
+			p.pos++
+			return node, nil
+		}
+
+		if !strings.HasPrefix(tk, ":") {
+			return nil, errors.Errorf("expected a field name in node %s, got %q", name, tk)
+		}
+		p.pos++
+
+		// Most fields have one value, but some like Const's :constvalue are followed by several tokens
+		var values []any
+		for tk := p.peek(); tk != "}" && !strings.HasPrefix(tk, ":"); tk = p.peek() {
+			if tk == "" {
+				return nil, errors.Errorf("unterminated node %s in node tree", name)
+			}
+			value, err := p.parseValue()
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+		}
+
+		switch len(values) {
+		case 0:
+			node.Fields[tk[1:]] = nil
+		case 1:
+			node.Fields[tk[1:]] = values[0]
+		default:
+			node.Fields[tk[1:]] = values
+		}
+	}
+}
+
+func tokenizePgNodeTree(src string) []string {
+	var tokens []string
+	var i = 0
+
+	for i < len(src) {
+		var c = src[i]
+
+		if c == ' ' || c == '\t' || c == '\n' || c == '\r' {
+			i++
+			continue
+		}
+
+		if c == '(' || c == ')' || c == '{' || c == '}' {
+			tokens = append(tokens, src[i:i+1])
+			i++
+			continue
+		}
+
+		var sb strings.Builder
+		for i < len(src) {
+			c = src[i]
+			if c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '(' || c == ')' || c == '{' || c == '}' {
+				break
+			}
+			if c == '\\' && i+1 < len(src) {
+				i++
+				c = src[i]
+			}
+			sb.WriteByte(c)
+			i++
+		}
+
+		tokens = append(tokens, sb.String())
+	}
+
+	return tokens
+}
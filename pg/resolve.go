@@ -0,0 +1,277 @@
+// Copyright 2025 Christophe Eymard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg
+
+import (
+	"gitlab.com/tozd/go/errors"
+)
+
+// Link together everything that was loaded from the catalog : types, relations, columns, functions and foreign keys.
+//
+// Only the Pg* fields and names are used to find the objects, and everything that is computed here is reset first,
+// so it can be run again on infos that were modified or that did not come from a database.
+// References that point to objects that were not loaded on purpose, like a foreign key to a relation in a schema that
+// was not fetched, are ignored, but dangling oids are all reported together in the returned error.
+func (db *DbInfos) Resolve() error {
+	var r = &resolver{db: db}
+
+	r.indexObjects()
+	r.resolveTypes()
+	r.resolveRelations()
+	r.resolveFunctions()
+	r.resolveForeignKeys()
+	r.resolveViews()
+	r.resolveTriggers()
+	r.resolveSequences()
+	r.resolveOperators()
+	r.resolveCasts()
+
+	fillValidationRules(db)
+
+	if len(r.errs) > 0 {
+		return errors.Errorf("%d unresolved references in the catalog: %w", len(r.errs), errors.Join(r.errs...))
+	}
+	return nil
+}
+
+type resolver struct {
+	db   *DbInfos
+	errs []error
+}
+
+func (r *resolver) dangling(format string, args ...any) {
+	r.errs = append(r.errs, errors.Errorf(format, args...))
+}
+
+// Find a type that has to exist, an oid of 0 meaning there is none.
+func (r *resolver) requireType(oid int, format string, args ...any) *Type {
+	if oid == 0 {
+		return nil
+	}
+	if t, ok := r.db.TypeMapByOid[oid]; ok {
+		return t
+	}
+	r.dangling("type %d not found for "+format, append([]any{oid}, args...)...)
+	return nil
+}
+
+func (r *resolver) indexObjects() {
+	var db = r.db
+
+	db.TypeMapByOid = make(map[int]*Type, len(db.Types))
+	for _, t := range db.Types {
+		db.TypeMapByOid[t.PgOid] = t
+	}
+
+	db.RelationMapByRelid = make(map[int]*Relation, len(db.Relations))
+	db.RelationMapByName = make(map[string]*Relation, len(db.Relations))
+	for _, rel := range db.Relations {
+		db.RelationMapByRelid[rel.PgRelId] = rel
+		db.RelationMapByName[rel.Identifier.String()] = rel
+	}
+
+	db.FunctionMapByName = make(map[string][]*Function)
+	db.FunctionMapByOid = make(map[int]*Function, len(db.Functions))
+	for _, f := range db.Functions {
+		var key = f.Identifier.String()
+		db.FunctionMapByName[key] = append(db.FunctionMapByName[key], f)
+		db.FunctionMapByOid[f.PgOid] = f
+	}
+
+	db.RoleMapByName = make(map[string]*Role, len(db.Roles))
+	for _, role := range db.Roles {
+		db.RoleMapByName[role.Name] = role
+	}
+
+	db.OperatorMapByOid = make(map[int]*Operator, len(db.Operators))
+	db.OperatorMapByName = make(map[string][]*Operator)
+	for _, o := range db.Operators {
+		db.OperatorMapByOid[o.PgOid] = o
+		db.OperatorMapByName[o.Identifier.Name] = append(db.OperatorMapByName[o.Identifier.Name], o)
+	}
+
+	db.castMap = make(map[[2]int]*Cast, len(db.Casts))
+	for _, c := range db.Casts {
+		db.castMap[[2]int{c.PgSourceOid, c.PgTargetOid}] = c
+	}
+}
+
+func (r *resolver) resolveTypes() {
+	for _, t := range r.db.Types {
+		var name = t.PgIdentifier.String()
+		t.ElementType = r.requireType(t.PgElemOid, "the elements of %s", name)
+		t.ArrayType = r.requireType(t.PgArrayOid, "the array of %s", name)
+		// Domains over domains are linked one level at a time, UnderlyingType goes all the way down.
+		t.BaseType = r.requireType(t.PgRealTypeId, "the base of domain %s", name)
+		// The relation of a composite type may not have been loaded, as is the case for CREATE TYPE ... AS (...)
+		t.Relation = r.db.GetRelation(t.PgRelId)
+
+		t.RangeSubtype = r.requireType(t.PgRangeSubtypeOid, "the subtype of range %s", name)
+		t.MultirangeType = r.requireType(t.PgMultirangeOid, "the multirange of %s", name)
+		t.RangeType = r.requireType(t.PgRangeOid, "the range of multirange %s", name)
+
+		for _, a := range t.Attributes {
+			a.Type = r.requireType(a.PgTypeOid, "attribute %s of %s", a.Name, name)
+		}
+	}
+}
+
+func (r *resolver) resolveRelations() {
+	for _, rel := range r.db.Relations {
+		var name = rel.Identifier.String()
+
+		rel.IsView = rel.Kind == RELKIND_VIEW
+		rel.IsMaterializedView = rel.Kind == RELKIND_MATERIALIZED_VIEW
+		rel.Type = r.requireType(rel.PgTypeOid, "relation %s", name)
+
+		rel.ColumnsMap = make(map[string]*Column, len(rel.Columns))
+		for _, c := range rel.Columns {
+			rel.ColumnsMap[c.Name] = c
+			c.Type = r.requireType(c.PgTypeOid, "column %s of %s", c.Name, name)
+			c.BaseColumn = nil
+			c.Sequence = nil
+		}
+
+		fillRelationKeys(rel)
+
+		rel.OutgoingForeignKeys = nil
+		rel.IncomingForeignKeys = nil
+		rel.outgoingForeignKeysMap = make(map[string]*OutgoingForeignKey)
+		rel.incomingForeignKeysMap = make(map[incomingForeignKeyKey]*IncomingForeignKey)
+
+		rel.PartitionParent = nil
+		rel.Partitions = nil
+		rel.InheritsFrom = nil
+	}
+
+	// Link partitions and inheritance parents, which may have been excluded from the results
+	for _, rel := range r.db.Relations {
+		if parent := r.db.GetRelation(rel.PgPartitionParentRelId); parent != nil {
+			rel.PartitionParent = parent
+			parent.Partitions = append(parent.Partitions, rel)
+		}
+
+		for _, relid := range rel.PgInheritsRelIds {
+			if parent := r.db.GetRelation(relid); parent != nil {
+				rel.InheritsFrom = append(rel.InheritsFrom, parent)
+			}
+		}
+	}
+}
+
+func (r *resolver) resolveFunctions() {
+	for _, f := range r.db.Functions {
+		var name = f.String()
+		f.ReturnType = r.requireType(f.PgReturnTypeOid, "the return of %s", name)
+
+		for _, a := range f.Arguments {
+			a.Type = r.requireType(a.PgTypeOid, "argument %d of %s", a.Index, name)
+		}
+	}
+}
+
+func (r *resolver) resolveForeignKeys() {
+	for _, fk := range r.db.ForeignKeys {
+		self := r.db.GetRelation(fk.PgRelId)
+		other := r.db.GetRelation(fk.PgOtherRelId)
+		if self == nil || other == nil {
+			// One of the sides was not loaded
+			continue
+		}
+
+		self_columns, err := getColumnsByName(self, fk.ColumnNames)
+		if err != nil {
+			r.errs = append(r.errs, errors.Errorf("foreign key %s: %w", fk.Identifier.String(), err))
+			continue
+		}
+
+		other_columns, err := getColumnsByName(other, fk.OtherColumnNames)
+		if err != nil {
+			r.errs = append(r.errs, errors.Errorf("foreign key %s: %w", fk.Identifier.String(), err))
+			continue
+		}
+
+		linkForeignKey(fk, self, self_columns, other, other_columns)
+	}
+}
+
+func (r *resolver) resolveViews() {
+	for _, rel := range r.db.Relations {
+		for _, c := range rel.Columns {
+			if c.PgBaseRelId == 0 {
+				continue
+			}
+
+			// The base relation may not have been loaded
+			base := r.db.GetRelation(c.PgBaseRelId)
+			if base == nil {
+				continue
+			}
+
+			if c.BaseColumn = base.GetColumnByIndex(c.PgBaseColumnIndex); c.BaseColumn == nil {
+				r.dangling("column %d of %s not found for column %s of view %s", c.PgBaseColumnIndex, base.Identifier.String(), c.Name, rel.Identifier.String())
+			}
+		}
+	}
+
+	inheritViewForeignKeys(r.db)
+}
+
+func (r *resolver) resolveTriggers() {
+	for _, rel := range r.db.Relations {
+		for _, t := range rel.Triggers {
+			// Trigger functions often live in a schema of their own that may not have been loaded
+			t.Function = r.db.GetFunction(t.PgFunctionOid)
+		}
+	}
+}
+
+func (r *resolver) resolveSequences() {
+	for _, s := range r.db.Sequences {
+		var name = s.Identifier.String()
+		s.DataType = r.requireType(s.PgTypeOid, "sequence %s", name)
+
+		s.OwnerRelation = r.db.GetRelation(s.PgOwnerRelId)
+		s.OwnerColumn = nil
+		if s.OwnerRelation == nil {
+			continue
+		}
+
+		if s.OwnerColumn = s.OwnerRelation.GetColumnByIndex(s.PgOwnerColumnIndex); s.OwnerColumn == nil {
+			r.dangling("column %d of %s not found for sequence %s", s.PgOwnerColumnIndex, s.OwnerRelation.Identifier.String(), name)
+			continue
+		}
+		s.OwnerColumn.Sequence = s
+	}
+}
+
+func (r *resolver) resolveOperators() {
+	for _, o := range r.db.Operators {
+		var name = o.Identifier.String()
+		o.LeftType = r.requireType(o.PgLeftTypeOid, "the left operand of %s", name)
+		o.RightType = r.requireType(o.PgRightTypeOid, "the right operand of %s", name)
+		o.ResultType = r.requireType(o.PgResultTypeOid, "the result of %s", name)
+		// Commutators and negators may only have been declared, in which case they are not in pg_operator
+		o.Commutator = r.db.GetOperator(o.PgCommutatorOid)
+		o.Negator = r.db.GetOperator(o.PgNegatorOid)
+	}
+}
+
+func (r *resolver) resolveCasts() {
+	for _, c := range r.db.Casts {
+		c.Source = r.requireType(c.PgSourceOid, "the source of cast %d", c.PgOid)
+		c.Target = r.requireType(c.PgTargetOid, "the target of cast %d", c.PgOid)
+	}
+}
@@ -0,0 +1,382 @@
+// Copyright 2025 Christophe Eymard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+func readFixture(t *testing.T, name string) (*DbInfos, error) {
+	t.Helper()
+	f, err := os.Open("testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	return ReadSnapshot(f)
+}
+
+func loadFixture(t *testing.T, name string) *DbInfos {
+	t.Helper()
+	db, err := readFixture(t, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func typeByName(t *testing.T, db *DbInfos, schema string, name string) *Type {
+	t.Helper()
+	for _, typ := range db.Types {
+		if typ.PgIdentifier.Schema == schema && typ.PgIdentifier.Name == name {
+			return typ
+		}
+	}
+	t.Fatalf("type %s.%s not in the fixture", schema, name)
+	return nil
+}
+
+func relationByName(t *testing.T, db *DbInfos, name string) *Relation {
+	t.Helper()
+	var rel = db.RelationMapByName[SqlIdentifier{Schema: "api", Name: name}.String()]
+	if rel == nil {
+		t.Fatalf("relation api.%s not in the fixture", name)
+	}
+	return rel
+}
+
+// Every pointer has to lead to the objects of the slices, not to copies of them
+func TestResolvePointers(t *testing.T) {
+	var db = loadFixture(t, "catalog.json")
+
+	for i, typ := range db.Types {
+		if db.TypeMapByOid[typ.PgOid] != db.Types[i] {
+			t.Errorf("TypeMapByOid[%d] is not the type of the slice", typ.PgOid)
+		}
+	}
+	for i, rel := range db.Relations {
+		if db.RelationMapByRelid[rel.PgRelId] != db.Relations[i] {
+			t.Errorf("RelationMapByRelid[%d] is not the relation of the slice", rel.PgRelId)
+		}
+		if db.RelationMapByName[rel.Identifier.String()] != db.Relations[i] {
+			t.Errorf("RelationMapByName[%s] is not the relation of the slice", rel.Identifier.String())
+		}
+		for j, c := range rel.Columns {
+			if rel.ColumnsMap[c.Name] != rel.Columns[j] {
+				t.Errorf("ColumnsMap[%s] of %s is not the column of the slice", c.Name, rel.Identifier.String())
+			}
+			if c.Type == nil || db.TypeMapByOid[c.PgTypeOid] != c.Type {
+				t.Errorf("column %s of %s is not linked to type %d", c.Name, rel.Identifier.String(), c.PgTypeOid)
+			}
+		}
+	}
+	for _, f := range db.Functions {
+		if !containsPointer(db.FunctionMapByName[f.Identifier.String()], f) || db.FunctionMapByOid[f.PgOid] != f {
+			t.Errorf("%s is not in the function maps", f.String())
+		}
+	}
+
+	// Resolving again relinks the same objects instead of making new ones or duplicating foreign keys
+	var orders = relationByName(t, db, "orders")
+	var quantity = orders.ColumnsMap["quantity"]
+	var quantity_type = quantity.Type
+
+	if err := db.Resolve(); err != nil {
+		t.Fatal(err)
+	}
+
+	if relationByName(t, db, "orders") != orders || orders.ColumnsMap["quantity"] != quantity || quantity.Type != quantity_type {
+		t.Error("Resolve replaced objects that were already linked")
+	}
+	if len(orders.OutgoingForeignKeys) != 1 || len(relationByName(t, db, "customers").IncomingForeignKeys) != 1 {
+		t.Errorf("foreign keys were linked more than once: %d outgoing, %d incoming",
+			len(orders.OutgoingForeignKeys), len(relationByName(t, db, "customers").IncomingForeignKeys))
+	}
+}
+
+func containsPointer[T comparable](list []T, v T) bool {
+	for _, e := range list {
+		if e == v {
+			return true
+		}
+	}
+	return false
+}
+
+func TestResolveTypeLinks(t *testing.T) {
+	var db = loadFixture(t, "catalog.json")
+
+	var int4 = typeByName(t, db, "pg_catalog", "int4")
+	var int4s = typeByName(t, db, "pg_catalog", "_int4")
+	if int4.ArrayType != int4s || int4s.ElementType != int4 || !int4s.IsArray() || int4.IsArray() {
+		t.Error("int4 and _int4 are not linked as element and array")
+	}
+
+	// Domains over domains go down one level at a time
+	var positive = typeByName(t, db, "api", "positive_int")
+	var quantity = typeByName(t, db, "api", "quantity")
+	if quantity.BaseType != positive || positive.BaseType != int4 {
+		t.Error("domains are not linked to their base types")
+	}
+	if quantity.UnderlyingType() != int4 || !quantity.IsDomain() || int4.IsDomain() {
+		t.Error("the underlying type of a domain over a domain should be int4")
+	}
+	if rules := quantity.ValidationRules(); len(rules) != 3 {
+		t.Errorf("quantity should have the rules of both domains, got %d", len(rules))
+	}
+
+	// A standalone composite type has attributes and no relation
+	var address = typeByName(t, db, "api", "address")
+	if !address.IsComposite() || address.Relation != nil {
+		t.Error("address should be a composite type without relation")
+	}
+	if len(address.Attributes) != 2 || address.Attributes[0].Type != typeByName(t, db, "pg_catalog", "text") || address.Attributes[1].Type != int4 {
+		t.Error("the attributes of address are not linked to their types")
+	}
+
+	// The row type of a relation and the relation point to each other
+	var orders = relationByName(t, db, "orders")
+	var orders_type = typeByName(t, db, "api", "orders")
+	if orders.Type != orders_type || orders_type.Relation != orders {
+		t.Error("orders and its row type are not linked")
+	}
+
+	var int4range = typeByName(t, db, "pg_catalog", "int4range")
+	var int4multirange = typeByName(t, db, "pg_catalog", "int4multirange")
+	if int4range.RangeSubtype != int4 || int4range.MultirangeType != int4multirange || int4multirange.RangeType != int4range {
+		t.Error("int4range is not linked to its subtype and multirange")
+	}
+}
+
+func TestResolveRelationLinks(t *testing.T) {
+	var db = loadFixture(t, "catalog.json")
+	var orders = relationByName(t, db, "orders")
+	var customers = relationByName(t, db, "customers")
+
+	if orders.ColumnsMap["quantity"].Type != typeByName(t, db, "api", "quantity") {
+		t.Error("orders.quantity should have the quantity domain as its type")
+	}
+	if customers.ColumnsMap["address"].Type != typeByName(t, db, "api", "address") {
+		t.Error("customers.address should have the address composite as its type")
+	}
+	if !orders.ColumnsMap["id"].IsPrimaryKey || !orders.ColumnsMap["id"].IsUnique || orders.ColumnsMap["customer_id"].IsUnique {
+		t.Error("the primary key of orders was not taken from its indexes")
+	}
+
+	var fk = orders.GetOutgoingFkByName("orders_customer_id_fkey")
+	if fk == nil || fk.OtherRelation != customers || fk.SelfColumns[0] != orders.ColumnsMap["customer_id"] || fk.OtherColumns[0] != customers.ColumnsMap["id"] {
+		t.Fatal("the outgoing foreign key of orders is not linked to customers")
+	}
+	var incoming = customers.GetIncomingFkByName(orders.Identifier, "orders_customer_id_fkey")
+	if incoming == nil || incoming.OtherRelation != orders || incoming.ForeignKey != fk.ForeignKey || incoming.OtherIsUnique {
+		t.Fatal("the incoming foreign key of customers is not linked to orders")
+	}
+}
+
+// Foreign key names are only unique per table, two tables can both reference customers through fk_customer
+func TestResolveSameNamedForeignKeys(t *testing.T) {
+	var db = loadFixture(t, "foreign_keys.json")
+	var customers = relationByName(t, db, "customers")
+	var orders = relationByName(t, db, "orders")
+	var invoices = relationByName(t, db, "invoices")
+
+	if len(customers.IncomingForeignKeys) != 2 {
+		t.Fatalf("customers should have 2 incoming foreign keys, got %d", len(customers.IncomingForeignKeys))
+	}
+
+	var from_orders = customers.GetIncomingFkByName(orders.Identifier, "fk_customer")
+	if from_orders == nil || from_orders.OtherRelation != orders || from_orders.OtherColumns[0] != orders.ColumnsMap["customer_id"] {
+		t.Error("fk_customer from orders is not linked to orders.customer_id")
+	}
+	var from_invoices = customers.GetIncomingFkByName(invoices.Identifier, "fk_customer")
+	if from_invoices == nil || from_invoices.OtherRelation != invoices || from_invoices.OtherColumns[0] != invoices.ColumnsMap["billed_to"] {
+		t.Error("fk_customer from invoices is not linked to invoices.billed_to")
+	}
+
+	if orders.GetOutgoingFkByName("fk_customer").OtherRelation != customers || invoices.GetOutgoingFkByName("fk_customer").OtherRelation != customers {
+		t.Error("the outgoing sides of fk_customer do not lead to customers")
+	}
+}
+
+// With Schemas set to api, the private tables the api views are built upon are still there to give them their
+// foreign keys, but they are not exposed.
+func TestResolveFilteredCatalog(t *testing.T) {
+	var db = loadFixture(t, "filtered.json")
+	var orders = relationByName(t, db, "orders")
+	var customers = relationByName(t, db, "customers")
+
+	// api.orders leads to both private.customers and api.customers
+	var fk *OutgoingForeignKey
+	for _, outgoing := range orders.OutgoingForeignKeys {
+		if outgoing.OtherRelation == customers {
+			fk = outgoing
+		}
+	}
+	if fk == nil || fk.Identifier.Name != "orders_customer_id_fkey" || fk.SelfColumns[0] != orders.ColumnsMap["customer_id"] || fk.OtherColumns[0] != customers.ColumnsMap["id"] {
+		t.Fatal("api.orders did not inherit the foreign key of private.orders to api.customers")
+	}
+	if incoming := customers.GetIncomingFkByName(orders.Identifier, "orders_customer_id_fkey"); incoming == nil || incoming.ForeignKey != fk.ForeignKey {
+		t.Error("api.customers did not get the incoming side of the foreign key from api.orders")
+	}
+
+	var exposed = db.ExposedRelations()
+	if len(exposed) != 2 || exposed[0] != customers || exposed[1] != orders {
+		t.Errorf("only the api views should be exposed, got %d relations", len(exposed))
+	}
+	if db.LookupRelation("", "orders") != orders {
+		t.Error("orders should be found in api")
+	}
+	if db.LookupRelation("private", "orders") != nil {
+		t.Error("private.orders is not exposed and should not be found")
+	}
+}
+
+func TestResolveFunctionLinks(t *testing.T) {
+	var db = loadFixture(t, "catalog.json")
+
+	var total = db.LookupFunctions("", "order_total")
+	if len(total) != 1 {
+		t.Fatalf("expected one order_total, got %d", len(total))
+	}
+	var f = total[0]
+	if f.ReturnType != typeByName(t, db, "pg_catalog", "numeric") {
+		t.Error("the return type of order_total is not numeric")
+	}
+	if f.Arguments[0].Type != typeByName(t, db, "pg_catalog", "int4") || f.Arguments[1].Type != typeByName(t, db, "pg_catalog", "numeric") {
+		t.Error("the arguments of order_total are not linked to their types")
+	}
+	if !f.Arguments[1].HasDefault || !f.IsExportable() {
+		t.Error("order_total should have a default for discount and be exportable")
+	}
+
+	var orders = db.LookupFunctions("api", "customer_orders")
+	if len(orders) != 1 || orders[0].ReturnType.Relation != relationByName(t, db, "orders") {
+		t.Error("customer_orders should return rows of orders")
+	}
+}
+
+func TestResolveSequences(t *testing.T) {
+	var db = loadFixture(t, "catalog.json")
+	var orders = relationByName(t, db, "orders")
+
+	var seq = db.GetSequence(SqlIdentifier{Schema: "api", Name: "orders_id_seq"})
+	if seq == nil {
+		t.Fatal("api.orders_id_seq not found")
+	}
+	if seq.DataType != typeByName(t, db, "pg_catalog", "int4") {
+		t.Error("the data type of orders_id_seq is not int4")
+	}
+	if seq.OwnerRelation != orders || seq.OwnerColumn != orders.ColumnsMap["id"] || orders.ColumnsMap["id"].Sequence != seq {
+		t.Error("orders_id_seq and orders.id are not linked")
+	}
+	if orders.ColumnsMap["customer_id"].Sequence != nil {
+		t.Error("orders.customer_id has no sequence")
+	}
+
+	// A sequence that is owned by no column
+	var invoices = db.GetSequence(SqlIdentifier{Schema: "api", Name: "invoice_numbers"})
+	if invoices == nil || invoices.OwnerRelation != nil || invoices.OwnerColumn != nil || !invoices.Cycle {
+		t.Error("invoice_numbers should be a cycling sequence without owner")
+	}
+	if invoices.DataType != typeByName(t, db, "pg_catalog", "int8") {
+		t.Error("the data type of invoice_numbers is not int8")
+	}
+}
+
+func TestResolveTriggers(t *testing.T) {
+	var db = loadFixture(t, "catalog.json")
+	var orders = relationByName(t, db, "orders")
+	var quantities = relationByName(t, db, "order_quantities")
+	var touch = db.LookupFunctions("api", "touch_order")
+
+	var touch_trigger, audit = orders.Triggers[0], orders.Triggers[1]
+	if len(touch) != 1 || touch_trigger.Function != touch[0] {
+		t.Error("orders_touch is not linked to touch_order")
+	}
+	if audit.Function != nil {
+		t.Error("the function of orders_audit was not loaded and should be nil")
+	}
+	if !touch_trigger.FiresOn(TRIGGER_EVENT_UPDATE) || touch_trigger.FiresOn(TRIGGER_EVENT_DELETE) {
+		t.Error("orders_touch fires on insert and update only")
+	}
+
+	// Disabled triggers don't count
+	if !quantities.HasInsteadOfTrigger(TRIGGER_EVENT_INSERT) || quantities.HasInsteadOfTrigger(TRIGGER_EVENT_DELETE) || quantities.HasInsteadOfTrigger(TRIGGER_EVENT_UPDATE) {
+		t.Error("order_quantities only has an enabled INSTEAD OF INSERT trigger")
+	}
+	if orders.HasInsteadOfTrigger(TRIGGER_EVENT_INSERT) {
+		t.Error("orders has no INSTEAD OF trigger")
+	}
+}
+
+func TestExtensions(t *testing.T) {
+	var db = loadFixture(t, "catalog.json")
+
+	if !db.HasExtension("pgcrypto") || db.HasExtension("postgis") {
+		t.Error("pgcrypto is installed and postgis is not")
+	}
+	if e := db.GetExtension("pgcrypto"); e.Schema != "public" || e.Version != "1.3" || !e.IsRelocatable {
+		t.Errorf("pgcrypto was not read back from the snapshot, got %+v", e)
+	}
+}
+
+// All the dangling oids are reported at once
+func TestResolveDangling(t *testing.T) {
+	_, err := readFixture(t, "dangling.json")
+	if err == nil {
+		t.Fatal("expected an error for the dangling oids")
+	}
+
+	var msg = err.Error()
+	for _, expected := range []string{
+		"4 unresolved references",
+		"type 99001 not found for the base of domain",
+		"type 99002 not found for relation",
+		"type 99003 not found for column lost",
+		"type 99004 not found for argument 1",
+	} {
+		if !strings.Contains(msg, expected) {
+			t.Errorf("expected %q in %q", expected, msg)
+		}
+	}
+}
+
+// A snapshot written from a loaded catalog reads back the same
+func TestSnapshotRoundTrip(t *testing.T) {
+	var db = loadFixture(t, "catalog.json")
+
+	var first bytes.Buffer
+	if err := db.WriteSnapshot(&first); err != nil {
+		t.Fatal(err)
+	}
+	reloaded, err := ReadSnapshot(bytes.NewReader(first.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var second bytes.Buffer
+	if err := reloaded.WriteSnapshot(&second); err != nil {
+		t.Fatal(err)
+	}
+	if first.String() != second.String() {
+		t.Error("the snapshot changed after being read back")
+	}
+
+	if _, err := ReadSnapshot(strings.NewReader(`{"Version": 0}`)); err == nil || !strings.Contains(err.Error(), "unsupported snapshot version 0") {
+		t.Errorf("expected an error for an unsupported version, got %v", err)
+	}
+}
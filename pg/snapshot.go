@@ -0,0 +1,92 @@
+// Copyright 2025 Christophe Eymard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+
+	"gitlab.com/tozd/go/errors"
+)
+
+// Bumped whenever the catalog changes in a way older snapshots can't be read with.
+const SNAPSHOT_VERSION = 1
+
+// A snapshot only holds what was read from the database. Pointers and lookup maps are
+// left out and rebuilt by Resolve when it is loaded.
+type snapshot struct {
+	Version int
+	*DbInfos
+}
+
+// Write the catalog as JSON, so that it can be loaded back with ReadSnapshot without a database.
+func (db *DbInfos) WriteSnapshot(w io.Writer) error {
+	var enc = json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(snapshot{Version: SNAPSHOT_VERSION, DbInfos: db}); err != nil {
+		return errors.Errorf("failed to encode snapshot: %w", err)
+	}
+	return nil
+}
+
+// Write the catalog to a file, see WriteSnapshot
+func (db *DbInfos) Save(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return errors.Errorf("failed to create snapshot: %w", err)
+	}
+
+	if err := db.WriteSnapshot(f); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return errors.Errorf("failed to write snapshot: %w", err)
+	}
+	return nil
+}
+
+// Read a catalog written by WriteSnapshot and resolve it. The resulting DbInfos has no Pool.
+// Only snapshots of the current SNAPSHOT_VERSION are read, older ones have to be taken again.
+func ReadSnapshot(r io.Reader) (*DbInfos, error) {
+	var snap = snapshot{DbInfos: &DbInfos{}}
+
+	if err := json.NewDecoder(r).Decode(&snap); err != nil {
+		return nil, errors.Errorf("failed to decode snapshot: %w", err)
+	}
+
+	if snap.Version != SNAPSHOT_VERSION {
+		return nil, errors.Errorf("unsupported snapshot version %d, expected %d, the snapshot has to be taken again", snap.Version, SNAPSHOT_VERSION)
+	}
+
+	if err := snap.DbInfos.Resolve(); err != nil {
+		return nil, err
+	}
+
+	return snap.DbInfos, nil
+}
+
+// Load a catalog saved with Save, without connecting to the database.
+func LoadInfos(path string) (*DbInfos, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Errorf("failed to open snapshot: %w", err)
+	}
+	defer f.Close()
+
+	return ReadSnapshot(f)
+}
@@ -0,0 +1,446 @@
+// Copyright 2025 Christophe Eymard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg
+
+import (
+	"strconv"
+	"strings"
+)
+
+const (
+	RULE_MIN        = "min" // Value is a number or a literal the value can't be under
+	RULE_MAX        = "max"
+	RULE_IN         = "in" // The value has to be one of Values
+	RULE_PATTERN    = "pattern"
+	RULE_MIN_LENGTH = "min_length"
+	RULE_MAX_LENGTH = "max_length"
+	RULE_NOT_NULL   = "not_null"
+)
+
+// A simple rule that was recognized in a CHECK constraint, that clients can enforce before sending data.
+type ValidationRule struct {
+	Kind   string // One of the RULE_* constants
+	Column string // The column the rule applies to, empty for the ones of a domain
+
+	Value           string   // The bound, length or pattern
+	Values          []string // The allowed values of RULE_IN
+	Exclusive       bool     // The bound of RULE_MIN or RULE_MAX is not allowed itself
+	CaseInsensitive bool     // The pattern was matched with ~*
+}
+
+// Recognize common shapes in a constraint definition as returned by pg_get_constraintdef : comparisons
+// with constants, IN lists, regular expressions, length checks and IS NOT NULL, possibly AND'ed together.
+// Everything else is ignored.
+func parseValidationRules(definition string) []*ValidationRule {
+	var expr = strings.TrimSpace(definition)
+	expr = strings.TrimSuffix(expr, " NOT VALID")
+
+	if !strings.HasPrefix(expr, "CHECK ") {
+		return nil
+	}
+	expr = strings.TrimSpace(strings.TrimPrefix(expr, "CHECK "))
+	expr = strings.TrimSuffix(expr, " NO INHERIT")
+
+	var rules []*ValidationRule
+	for _, part := range splitTopLevel(stripParens(expr), " AND ") {
+		if rule := parseValidationRule(stripParens(part)); rule != nil {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+var comparison_operators = []string{" >= ", " <= ", " > ", " < ", " = ", " ~* ", " ~ "}
+
+var flipped_operators = map[string]string{
+	">=": "<=",
+	"<=": ">=",
+	">":  "<",
+	"<":  ">",
+	"=":  "=",
+}
+
+func parseValidationRule(expr string) *ValidationRule {
+	if left, ok := strings.CutSuffix(expr, " IS NOT NULL"); ok {
+		if col, ok := parseColumnRef(left); ok {
+			return &ValidationRule{Kind: RULE_NOT_NULL, Column: col}
+		}
+		return nil
+	}
+
+	left, op, right, ok := cutTopLevelOperator(expr)
+	if !ok {
+		return nil
+	}
+
+	// col = ANY (ARRAY[...]), which is what IN (...) becomes
+	if op == "=" && strings.HasPrefix(right, "ANY ") {
+		col, ok := parseColumnRef(left)
+		if !ok {
+			return nil
+		}
+		values, ok := parseArrayLiteral(strings.TrimPrefix(right, "ANY "))
+		if !ok {
+			return nil
+		}
+		return &ValidationRule{Kind: RULE_IN, Column: col, Values: values}
+	}
+
+	if op == "~" || op == "~*" {
+		col, ok := parseColumnRef(left)
+		if !ok {
+			return nil
+		}
+		pattern, ok := parseLiteral(right)
+		if !ok {
+			return nil
+		}
+		return &ValidationRule{Kind: RULE_PATTERN, Column: col, Value: pattern, CaseInsensitive: op == "~*"}
+	}
+
+	// Put the constant on the right
+	if _, ok := parseLiteral(left); ok {
+		left, right = right, left
+		op = flipped_operators[op]
+	}
+
+	value, ok := parseLiteral(right)
+	if !ok {
+		return nil
+	}
+
+	var is_length = false
+	if inner, ok := parseLengthCall(left); ok {
+		left = inner
+		is_length = true
+	}
+
+	col, ok := parseColumnRef(left)
+	if !ok {
+		return nil
+	}
+
+	var rule = &ValidationRule{Column: col, Value: value}
+	switch op {
+	case ">", ">=":
+		rule.Kind = RULE_MIN
+		rule.Exclusive = op == ">"
+	case "<", "<=":
+		rule.Kind = RULE_MAX
+		rule.Exclusive = op == "<"
+	case "=":
+		if is_length {
+			return nil
+		}
+		return &ValidationRule{Kind: RULE_IN, Column: col, Values: []string{value}}
+	default:
+		return nil
+	}
+
+	if is_length {
+		// Lengths are integers, so an exclusive bound is turned into an inclusive one
+		rule.Kind = map[string]string{RULE_MIN: RULE_MIN_LENGTH, RULE_MAX: RULE_MAX_LENGTH}[rule.Kind]
+		if rule.Exclusive {
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return nil
+			}
+			if rule.Kind == RULE_MIN_LENGTH {
+				n++
+			} else {
+				n--
+			}
+			rule.Value = strconv.Itoa(n)
+			rule.Exclusive = false
+		}
+	}
+
+	return rule
+}
+
+// length(col), char_length(col) or character_length(col)
+func parseLengthCall(expr string) (string, bool) {
+	for _, fn := range []string{"length(", "char_length(", "character_length("} {
+		if strings.HasPrefix(expr, fn) && strings.HasSuffix(expr, ")") {
+			return strings.TrimSpace(expr[len(fn) : len(expr)-1]), true
+		}
+	}
+	return "", false
+}
+
+// A column name, possibly cast and quoted, or VALUE for domains in which case the name is empty.
+func parseColumnRef(expr string) (string, bool) {
+	expr = stripParens(stripCast(stripParens(expr)))
+
+	if expr == "VALUE" {
+		return "", true
+	}
+
+	if len(expr) >= 2 && expr[0] == '"' && expr[len(expr)-1] == '"' {
+		return strings.ReplaceAll(expr[1:len(expr)-1], `""`, `"`), true
+	}
+
+	if expr == "" {
+		return "", false
+	}
+	for _, c := range expr {
+		if !(c == '_' || c == '$' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c > 127) {
+			return "", false
+		}
+	}
+	return expr, true
+}
+
+// A constant : a number, a string, possibly cast, or a negative number
+func parseLiteral(expr string) (string, bool) {
+	expr = stripParens(stripCast(stripParens(expr)))
+
+	if len(expr) >= 2 && expr[0] == '\'' {
+		// The string has to end with the expression, so that 'a' OR c = 'b' isn't taken for one
+		var end = 1
+		for end < len(expr) {
+			if expr[end] == '\'' {
+				if end+1 < len(expr) && expr[end+1] == '\'' {
+					end += 2
+					continue
+				}
+				break
+			}
+			end++
+		}
+		if end != len(expr)-1 {
+			return "", false
+		}
+		return strings.ReplaceAll(expr[1:end], "''", "'"), true
+	}
+
+	if strings.HasPrefix(expr, "- ") {
+		if num, ok := parseLiteral(expr[2:]); ok {
+			return "-" + num, true
+		}
+		return "", false
+	}
+
+	if expr == "" {
+		return "", false
+	}
+	for i, c := range expr {
+		if !(c >= '0' && c <= '9' || c == '.' || (c == 'e' || c == 'E') && i > 0 || (c == '-' || c == '+') && i > 0 && (expr[i-1] == 'e' || expr[i-1] == 'E')) {
+			return "", false
+		}
+	}
+	return expr, true
+}
+
+// (ARRAY['a'::text, 'b'::text]) or ((ARRAY['a'::character varying, ...])::text[])
+func parseArrayLiteral(expr string) ([]string, bool) {
+	expr = stripParens(stripCast(stripParens(expr)))
+
+	if !strings.HasPrefix(expr, "ARRAY[") || !strings.HasSuffix(expr, "]") {
+		return nil, false
+	}
+
+	var values []string
+	for _, item := range splitTopLevel(expr[len("ARRAY["):len(expr)-1], ", ") {
+		value, ok := parseLiteral(item)
+		if !ok {
+			return nil, false
+		}
+		values = append(values, value)
+	}
+	return values, true
+}
+
+// Call fn for every byte of s that is outside quotes, with the parenthesis depth at that point.
+// It stops when fn returns true.
+func scanTopLevel(s string, fn func(i int, depth int) bool) {
+	var depth = 0
+	var quote byte = 0
+
+	for i := 0; i < len(s); i++ {
+		var c = s[i]
+
+		if quote != 0 {
+			if c == quote {
+				quote = 0
+			}
+			continue
+		}
+
+		switch c {
+		case '\'', '"':
+			quote = c
+			continue
+		case '(', '[':
+			depth++
+			continue
+		case ')', ']':
+			depth--
+			continue
+		}
+
+		if fn(i, depth) {
+			return
+		}
+	}
+}
+
+func splitTopLevel(s string, sep string) []string {
+	var parts []string
+	var last = 0
+
+	scanTopLevel(s, func(i int, depth int) bool {
+		if depth == 0 && i >= last && strings.HasPrefix(s[i:], sep) {
+			parts = append(parts, strings.TrimSpace(s[last:i]))
+			last = i + len(sep)
+		}
+		return false
+	})
+
+	return append(parts, strings.TrimSpace(s[last:]))
+}
+
+func cutTopLevelOperator(s string) (string, string, string, bool) {
+	var left, op, right string
+	var found = false
+
+	scanTopLevel(s, func(i int, depth int) bool {
+		if depth != 0 {
+			return false
+		}
+		for _, o := range comparison_operators {
+			if strings.HasPrefix(s[i:], o) {
+				left, op, right = strings.TrimSpace(s[:i]), strings.TrimSpace(o), strings.TrimSpace(s[i+len(o):])
+				found = true
+				return true
+			}
+		}
+		return false
+	})
+
+	return left, op, right, found
+}
+
+// Remove the parenthesis that enclose the whole expression
+func stripParens(s string) string {
+	s = strings.TrimSpace(s)
+	for len(s) >= 2 && s[0] == '(' && s[len(s)-1] == ')' {
+		var encloses = true
+		scanTopLevel(s, func(i int, depth int) bool {
+			// depth is only reported outside of parenthesis, so anything at depth 0 before the end means
+			// the first parenthesis was closed early
+			if depth == 0 && i < len(s)-1 {
+				encloses = false
+				return true
+			}
+			return false
+		})
+		if !encloses {
+			return s
+		}
+		s = strings.TrimSpace(s[1 : len(s)-1])
+	}
+	return s
+}
+
+// Remove the ::type casts at the end of an operand, as in 'a'::character varying::text.
+// Casts are taken from the last one, and only as long as what follows :: is a type name, so that
+// a::text || b is left alone.
+func stripCast(s string) string {
+	for {
+		var last = -1
+		scanTopLevel(s, func(i int, depth int) bool {
+			if depth == 0 && strings.HasPrefix(s[i:], "::") {
+				last = i
+			}
+			return false
+		})
+		if last < 0 || !isTypeName(s[last+2:]) {
+			return s
+		}
+		s = strings.TrimSpace(s[:last])
+	}
+}
+
+// Tell if s looks like a type name, like character varying(10)[] or "my schema".my_type
+func isTypeName(s string) bool {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return false
+	}
+
+	var ok = true
+	scanTopLevel(s, func(i int, depth int) bool {
+		var c = s[i]
+		if depth == 0 && !(c == '_' || c == '.' || c == ' ' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9') {
+			ok = false
+			return true
+		}
+		return false
+	})
+	return ok
+}
+
+// The rules that apply to a value of this type, which are the ones of the domain and the domains it is based on.
+func (t *Type) ValidationRules() []*ValidationRule {
+	var res []*ValidationRule
+	for cur := t; cur != nil; cur = cur.BaseType {
+		if cur.IsNotNull {
+			res = append(res, &ValidationRule{Kind: RULE_NOT_NULL})
+		}
+		for _, check := range cur.Checks {
+			res = append(res, check.Rules...)
+		}
+	}
+	return res
+}
+
+// Compute the rules of the check constraints of types and relations, and gather the ones of each column.
+func fillValidationRules(db *DbInfos) {
+	for _, t := range db.Types {
+		for _, check := range t.Checks {
+			check.Rules = parseValidationRules(check.Definition)
+		}
+	}
+
+	for _, rel := range db.Relations {
+		for _, check := range rel.Checks {
+			check.Rules = parseValidationRules(check.Definition)
+		}
+
+		for _, c := range rel.Columns {
+			c.Rules = nil
+
+			if c.IsNotNull && c.DefaultExpression == "" && !c.IsIdentity && !c.IsGenerated {
+				c.Rules = append(c.Rules, &ValidationRule{Kind: RULE_NOT_NULL, Column: c.Name})
+			}
+
+			// Domain rules are copied, since they are not bound to a column
+			for _, rule := range c.Type.ValidationRules() {
+				var copy = *rule
+				copy.Column = c.Name
+				c.Rules = append(c.Rules, &copy)
+			}
+
+			for _, check := range rel.Checks {
+				for _, rule := range check.Rules {
+					if rule.Column == c.Name {
+						c.Rules = append(c.Rules, rule)
+					}
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,148 @@
+// Copyright 2025 Christophe Eymard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg
+
+import (
+	"strings"
+	"testing"
+)
+
+// The rules as kind(column value), with values joined by | and with ! for exclusive bounds and * for ~*
+func describeRules(rules []*ValidationRule) string {
+	var res []string
+	for _, rule := range rules {
+		var value = rule.Value
+		if rule.Kind == RULE_IN {
+			value = strings.Join(rule.Values, "|")
+		}
+		if rule.Exclusive {
+			value += "!"
+		}
+		if rule.CaseInsensitive {
+			value += "*"
+		}
+		res = append(res, rule.Kind+"("+rule.Column+" "+value+")")
+	}
+	return strings.Join(res, " ")
+}
+
+func TestParseValidationRules(t *testing.T) {
+	var tests = []struct {
+		definition string
+		rules      string
+	}{
+		// Comparisons
+		{`CHECK ((price > (0)::numeric))`, `min(price 0!)`},
+		{`CHECK ((price >= 0))`, `min(price 0)`},
+		{`CHECK ((0 < price))`, `min(price 0!)`},
+		{`CHECK ((quantity <= 100))`, `max(quantity 100)`},
+		{`CHECK ((delta >= '-5'::integer))`, `min(delta -5)`},
+		{`CHECK ((delta >= (- 5)))`, `min(delta -5)`},
+		{`CHECK ((ratio < 1.5e-3))`, `max(ratio 1.5e-3!)`},
+		{`CHECK ((status = 'draft'::text))`, `in(status draft)`},
+		{`CHECK (("Status" = 'it''s'::text))`, `in(Status it's)`},
+
+		// Ranges
+		{`CHECK (((price >= (0)::numeric) AND (price <= (1000)::numeric)))`, `min(price 0) max(price 1000)`},
+		{`CHECK (((starts_at >= '2020-01-01'::date) AND (starts_at < '2030-01-01'::date)))`, `min(starts_at 2020-01-01) max(starts_at 2030-01-01!)`},
+		{`CHECK (((VALUE > 0) AND (VALUE < 10)))`, `min( 0!) max( 10!)`},
+
+		// IN lists
+		{`CHECK ((status = ANY (ARRAY['draft'::text, 'sent'::text])))`, `in(status draft|sent)`},
+		{`CHECK (((status)::text = ANY ((ARRAY['draft'::character varying, 'sent'::character varying])::text[])))`, `in(status draft|sent)`},
+		{`CHECK ((kind = ANY (ARRAY[1, 2, 3])))`, `in(kind 1|2|3)`},
+		{`CHECK ((status = ANY (ARRAY['a'::text, lower(other)])))`, ``},
+
+		// Regular expressions
+		{`CHECK ((email ~ '^[^@]+@[^@]+$'::text))`, `pattern(email ^[^@]+@[^@]+$)`},
+		{`CHECK ((code ~* '^[a-z]{3}$'::text))`, `pattern(code ^[a-z]{3}$*)`},
+		{`CHECK ((VALUE ~ '^\d+ AND more$'::text))`, `pattern( ^\d+ AND more$)`},
+
+		// Lengths
+		{`CHECK ((length(name) > 2))`, `min_length(name 3)`},
+		{`CHECK ((char_length(name) <= 50))`, `max_length(name 50)`},
+		{`CHECK ((character_length((name)::text) < 10))`, `max_length(name 9)`},
+		{`CHECK ((length(name) = 5))`, ``},
+
+		// Not null, flags and what is not recognized
+		{`CHECK ((name IS NOT NULL))`, `not_null(name )`},
+		{`CHECK ((price > (0)::numeric)) NOT VALID`, `min(price 0!)`},
+		{`CHECK ((price > (0)::numeric)) NO INHERIT`, `min(price 0!)`},
+		{`CHECK (((price > (0)::numeric) OR (price IS NULL)))`, ``},
+		{`CHECK ((price > discount))`, ``},
+		{`CHECK ((lower(name) = name))`, ``},
+		{`UNIQUE (name)`, ``},
+		{`CHECK ((status = 'a'::text OR status = 'b'::text))`, ``},
+		{`CHECK (((price > (0)::numeric) AND (lower(name) = name)))`, `min(price 0!)`},
+	}
+
+	for _, test := range tests {
+		if got := describeRules(parseValidationRules(test.definition)); got != test.rules {
+			t.Errorf("%s: got %q, expected %q", test.definition, got, test.rules)
+		}
+	}
+}
+
+func TestParseLiteral(t *testing.T) {
+	var tests = []struct {
+		expr  string
+		value string
+		ok    bool
+	}{
+		{`'a'`, "a", true},
+		{`'it''s'`, "it's", true},
+		{`''`, "", true},
+		{`('a'::text)`, "a", true},
+		{`'a'::character varying::text`, "a", true},
+		{`(42)::bigint`, "42", true},
+		{`- 3.5`, "-3.5", true},
+		{`1e+10`, "1e+10", true},
+		{`'a' OR c = 'b'`, "", false},
+		{`'a' || 'b'`, "", false},
+		{`'a''`, "", false},
+		{`'a'::text || b`, "", false},
+		{`e5`, "", false},
+		{`name`, "", false},
+	}
+
+	for _, test := range tests {
+		value, ok := parseLiteral(test.expr)
+		if ok != test.ok || value != test.value {
+			t.Errorf("%s: got %q %v, expected %q %v", test.expr, value, ok, test.value, test.ok)
+		}
+	}
+}
+
+func TestStripCast(t *testing.T) {
+	var tests = []struct {
+		expr     string
+		stripped string
+	}{
+		{`'a'::text`, `'a'`},
+		{`'a'::character varying::text`, `'a'`},
+		{`(ARRAY['a'::text])::text[]`, `(ARRAY['a'::text])`},
+		{`x::numeric(10, 2)`, `x`},
+		{`x::"my schema".my_type`, `x`},
+		{`a::text || b`, `a::text || b`},
+		{`'a::text'`, `'a::text'`},
+		{`a`, `a`},
+	}
+
+	for _, test := range tests {
+		if got := stripCast(test.expr); got != test.stripped {
+			t.Errorf("%s: got %s, expected %s", test.expr, got, test.stripped)
+		}
+	}
+}
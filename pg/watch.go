@@ -0,0 +1,184 @@
+// Copyright 2025 Christophe Eymard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"gitlab.com/tozd/go/errors"
+)
+
+// The channel the event trigger notifies on whenever DDL is run
+const SCHEMA_CHANGE_CHANNEL = "pgrel_schema_changed"
+
+// Install the event triggers that NOTIFY on SCHEMA_CHANGE_CHANNEL after DDL commands.
+// They live in the pgrel schema, and creating event triggers requires a superuser.
+func InstallSchemaChangeTrigger(ctx context.Context, pool *pgxpool.Pool) error {
+	if _, err := pool.Exec(ctx, INSTALL_SCHEMA_CHANGE_TRIGGER); err != nil {
+		return errors.Errorf("failed to install the schema change trigger: %w", err)
+	}
+	return nil
+}
+
+func UninstallSchemaChangeTrigger(ctx context.Context, pool *pgxpool.Pool) error {
+	if _, err := pool.Exec(ctx, UNINSTALL_SCHEMA_CHANGE_TRIGGER); err != nil {
+		return errors.Errorf("failed to uninstall the schema change trigger: %w", err)
+	}
+	return nil
+}
+
+// Keeps a catalog up to date by reloading it whenever a schema change is notified.
+//
+// The catalog is swapped atomically and never modified after it was loaded, so code that got it
+// through Infos keeps working on a consistent snapshot while a reload happens.
+type Watcher struct {
+	pool    *pgxpool.Pool
+	current atomic.Pointer[DbInfos]
+	fill    func(db *DbInfos, ctx context.Context) error // Loads a new catalog, FillConcurrently unless testing
+
+	// DDL tends to come in bursts during migrations, so notifications that arrive within
+	// this delay of each other only trigger one reload.
+	Debounce time.Duration
+
+	OnReload func(db *DbInfos)
+	OnError  func(err error) // Reload errors, the previous catalog is kept
+}
+
+// The catalog has to come from a database, a catalog read from a snapshot has no pool to reload it with.
+func NewWatcher(db *DbInfos) (*Watcher, error) {
+	if db.Pool == nil {
+		return nil, errors.Errorf("can't watch a catalog that has no database connection")
+	}
+
+	var w = &Watcher{
+		pool:     db.Pool,
+		fill:     (*DbInfos).FillConcurrently,
+		Debounce: 500 * time.Millisecond,
+	}
+	w.current.Store(db)
+	return w, nil
+}
+
+// The current catalog
+func (w *Watcher) Infos() *DbInfos {
+	return w.current.Load()
+}
+
+// Listen for schema changes until the context is done.
+//
+// The listening connection is opened apart from the pool, since holding one of its connections for
+// as long as we watch could leave a reload waiting forever for the others.
+func (w *Watcher) Watch(ctx context.Context) error {
+	conn, err := pgx.ConnectConfig(ctx, w.pool.Config().ConnConfig.Copy())
+	if err != nil {
+		return errors.Errorf("failed to connect: %w", err)
+	}
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{SCHEMA_CHANGE_CHANNEL}.Sanitize()); err != nil {
+		return errors.Errorf("failed to listen: %w", err)
+	}
+
+	return w.listen(ctx, conn)
+}
+
+// What the watcher needs from the listening connection
+type notifier interface {
+	WaitForNotification(ctx context.Context) (*pgconn.Notification, error)
+}
+
+// Reload whenever a burst of notifications is over, until the context is done.
+func (w *Watcher) listen(ctx context.Context, conn notifier) error {
+	for {
+		if _, err := conn.WaitForNotification(ctx); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return errors.Errorf("failed to wait for notifications: %w", err)
+		}
+
+		if err := w.debounce(ctx, conn); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		if err := w.Reload(ctx); err != nil && w.OnError != nil {
+			w.OnError(err)
+		}
+	}
+}
+
+// Swallow the notifications that keep coming until there is a pause of at least Debounce.
+func (w *Watcher) debounce(ctx context.Context, conn notifier) error {
+	for {
+		wait_ctx, cancel := context.WithTimeout(ctx, w.Debounce)
+		_, err := conn.WaitForNotification(wait_ctx)
+		cancel()
+
+		if err == nil {
+			continue
+		}
+		if ctx.Err() == nil && errors.Is(err, context.DeadlineExceeded) {
+			return nil
+		}
+		return errors.Errorf("failed to wait for notifications: %w", err)
+	}
+}
+
+// Load the catalog again and swap it with the current one if it succeeded.
+func (w *Watcher) Reload(ctx context.Context) error {
+	var db = &DbInfos{Pool: w.pool, Options: w.Infos().Options}
+	if err := w.fill(db, ctx); err != nil {
+		return err
+	}
+
+	w.current.Store(db)
+	if w.OnReload != nil {
+		w.OnReload(db)
+	}
+	return nil
+}
+
+var INSTALL_SCHEMA_CHANGE_TRIGGER = /* sql */ `
+CREATE SCHEMA IF NOT EXISTS pgrel;
+
+CREATE OR REPLACE FUNCTION pgrel.notify_schema_change() RETURNS event_trigger
+LANGUAGE plpgsql AS $$
+BEGIN
+	PERFORM pg_notify('` + SCHEMA_CHANGE_CHANNEL + `', tg_tag);
+END;
+$$;
+
+DROP EVENT TRIGGER IF EXISTS pgrel_schema_change_ddl;
+CREATE EVENT TRIGGER pgrel_schema_change_ddl ON ddl_command_end
+	EXECUTE FUNCTION pgrel.notify_schema_change();
+
+DROP EVENT TRIGGER IF EXISTS pgrel_schema_change_drop;
+CREATE EVENT TRIGGER pgrel_schema_change_drop ON sql_drop
+	EXECUTE FUNCTION pgrel.notify_schema_change();
+`
+
+var UNINSTALL_SCHEMA_CHANGE_TRIGGER = /* sql */ `
+DROP EVENT TRIGGER IF EXISTS pgrel_schema_change_ddl;
+DROP EVENT TRIGGER IF EXISTS pgrel_schema_change_drop;
+DROP FUNCTION IF EXISTS pgrel.notify_schema_change();
+`
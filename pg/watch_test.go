@@ -0,0 +1,192 @@
+// Copyright 2025 Christophe Eymard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pg
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"gitlab.com/tozd/go/errors"
+)
+
+// Notifications sent on the channel are received by the watcher
+type stubNotifier chan string
+
+func (n stubNotifier) WaitForNotification(ctx context.Context) (*pgconn.Notification, error) {
+	select {
+	case tag := <-n:
+		return &pgconn.Notification{Channel: SCHEMA_CHANGE_CHANNEL, Payload: tag}, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// A watcher that doesn't need a database, fill is called in place of FillConcurrently
+func newTestWatcher(db *DbInfos, fill func(db *DbInfos, ctx context.Context) error) *Watcher {
+	var w = &Watcher{fill: fill, Debounce: 50 * time.Millisecond}
+	w.current.Store(db)
+	return w
+}
+
+func TestWatcherDebounce(t *testing.T) {
+	var fills atomic.Int32
+	var reloads = make(chan *DbInfos, 10)
+
+	var w = newTestWatcher(&DbInfos{}, func(db *DbInfos, ctx context.Context) error {
+		fills.Add(1)
+		return nil
+	})
+	w.OnReload = func(db *DbInfos) { reloads <- db }
+
+	var ctx, cancel = context.WithCancel(context.Background())
+	var notifications = make(stubNotifier)
+	var done = make(chan error)
+	go func() { done <- w.listen(ctx, notifications) }()
+
+	// A burst of DDL only reloads once it is over
+	for _, tag := range []string{"CREATE TABLE", "ALTER TABLE", "CREATE INDEX"} {
+		notifications <- tag
+		time.Sleep(w.Debounce / 5)
+	}
+	select {
+	case <-reloads:
+	case <-time.After(time.Second):
+		t.Fatal("no reload after the burst")
+	}
+	time.Sleep(3 * w.Debounce)
+	if n := fills.Load(); n != 1 {
+		t.Errorf("got %d reloads for one burst, expected 1", n)
+	}
+
+	// The next notification triggers another one
+	notifications <- "DROP TABLE"
+	select {
+	case <-reloads:
+	case <-time.After(time.Second):
+		t.Fatal("no reload after the second notification")
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("unexpected error when the context is done: %s", err)
+	}
+	if n := fills.Load(); n != 2 {
+		t.Errorf("got %d reloads, expected 2", n)
+	}
+}
+
+func TestWatcherReload(t *testing.T) {
+	var initial = &DbInfos{Options: Options{Schemas: []string{"api"}}}
+	var fail_with error
+
+	var w = newTestWatcher(initial, func(db *DbInfos, ctx context.Context) error {
+		if fail_with != nil {
+			return fail_with
+		}
+		if len(db.Options.Schemas) != 1 || db.Options.Schemas[0] != "api" {
+			t.Errorf("the reload did not keep the options, got %v", db.Options.Schemas)
+		}
+		return nil
+	})
+
+	var reloaded *DbInfos
+	w.OnReload = func(db *DbInfos) { reloaded = db }
+
+	if err := w.Reload(context.Background()); err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if reloaded == nil || reloaded == initial {
+		t.Fatal("OnReload was not given the new catalog")
+	}
+	if w.Infos() != reloaded {
+		t.Error("the new catalog did not replace the current one")
+	}
+
+	// A failed reload keeps the catalog we had
+	var previous = w.Infos()
+	reloaded = nil
+	fail_with = errors.New("connection refused")
+	if err := w.Reload(context.Background()); !errors.Is(err, fail_with) {
+		t.Errorf("got error %v, expected %s", err, fail_with)
+	}
+	if reloaded != nil {
+		t.Error("OnReload was called for a failed reload")
+	}
+	if w.Infos() != previous {
+		t.Error("a failed reload replaced the current catalog")
+	}
+}
+
+// Reload errors go to OnError and don't stop the watcher
+func TestWatcherOnError(t *testing.T) {
+	var initial = &DbInfos{}
+	var errs = make(chan error, 10)
+
+	var w = newTestWatcher(initial, func(db *DbInfos, ctx context.Context) error {
+		return errors.New("syntax error")
+	})
+	w.OnError = func(err error) { errs <- err }
+
+	var ctx, cancel = context.WithCancel(context.Background())
+	var notifications = make(stubNotifier)
+	var done = make(chan error)
+	go func() { done <- w.listen(ctx, notifications) }()
+
+	for range 2 {
+		notifications <- "CREATE TABLE"
+		select {
+		case err := <-errs:
+			if !strings.Contains(err.Error(), "syntax error") {
+				t.Errorf("unexpected error %s", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("OnError was not called")
+		}
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("unexpected error when the context is done: %s", err)
+	}
+	if w.Infos() != initial {
+		t.Error("failed reloads replaced the current catalog")
+	}
+}
+
+func TestSchemaChangeTriggerSQL(t *testing.T) {
+	for _, expected := range []string{
+		"pg_notify('" + SCHEMA_CHANGE_CHANNEL + "', tg_tag)",
+		"CREATE EVENT TRIGGER pgrel_schema_change_ddl ON ddl_command_end",
+		"CREATE EVENT TRIGGER pgrel_schema_change_drop ON sql_drop",
+	} {
+		if !strings.Contains(INSTALL_SCHEMA_CHANGE_TRIGGER, expected) {
+			t.Errorf("the install script lacks %q", expected)
+		}
+	}
+
+	for _, expected := range []string{
+		"DROP EVENT TRIGGER IF EXISTS pgrel_schema_change_ddl",
+		"DROP EVENT TRIGGER IF EXISTS pgrel_schema_change_drop",
+		"DROP FUNCTION IF EXISTS pgrel.notify_schema_change()",
+	} {
+		if !strings.Contains(UNINSTALL_SCHEMA_CHANGE_TRIGGER, expected) {
+			t.Errorf("the uninstall script lacks %q", expected)
+		}
+	}
+}
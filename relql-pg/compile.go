@@ -0,0 +1,535 @@
+// Copyright 2025 Christophe Eymard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package relqlpg
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ceymard/pgrel/pg"
+	"github.com/ceymard/pgrel/relql"
+	"github.com/ceymard/pgrel/relql/ast"
+	"github.com/jackc/pgx/v5"
+	"gitlab.com/tozd/go/errors"
+)
+
+// A relql query compiled to a single statement, which returns one row with one json column holding
+// the array of the selected rows, nested relations included.
+// Literals of the query are sent as parameters along with the $1 and :name ones, so Sql never contains any value
+// and can be logged or prepared as is.
+type Query struct {
+	Sql      string
+	Params   *relql.Params
+	Relation *ast.AstRelation
+}
+
+func (q *Query) String() string {
+	return q.Sql
+}
+
+// The arguments to run the statement with, see relql.Params.Bind
+func (q *Query) Args(positional []any, named map[string]any) ([]any, error) {
+	return q.Params.Bind(positional, named)
+}
+
+// Anything that can run a query, like a *pgx.Conn, a pgx.Tx or a *pgxpool.Pool
+type Querier interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// Run the statement with arguments given by Args and return its json result
+func (q *Query) Fetch(ctx context.Context, conn Querier, args []any) (json.RawMessage, error) {
+	var res []byte
+	if err := conn.QueryRow(ctx, q.Sql, args...).Scan(&res); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return res, nil
+}
+
+// Parse, resolve and compile a relql query. Errors are relql diagnostics that can be shown with relql.RenderError.
+func Prepare(db *pg.DbInfos, source []byte) (*Query, error) {
+	rel, err := relql.Parse(source)
+	if err != nil {
+		return nil, err
+	}
+	if err := Resolve(db, rel); err != nil {
+		return nil, err
+	}
+	return Compile(db, rel)
+}
+
+// Compile a tree that went through Resolve without errors
+func Compile(db *pg.DbInfos, rel *ast.AstRelation) (*Query, error) {
+	if rel.ResolvedRelation == nil {
+		return nil, relql.NodeError(rel, "the query has to be resolved before it is compiled")
+	}
+
+	var c = &compiler{
+		resolver: NewResolver(db),
+		params:   relql.NewParams(),
+		aliases:  make(map[*ast.AstRelation]string),
+	}
+
+	var sql = c.aggregate(rel, c.alias(rel), nil)
+
+	if len(c.errs) > 0 {
+		return nil, errors.Join(c.errs...)
+	}
+	return &Query{Sql: sql, Params: c.params, Relation: rel}, nil
+}
+
+type compiler struct {
+	resolver *Resolver
+	params   *relql.Params
+	aliases  map[*ast.AstRelation]string // t0, t1, ... in the order relations are met
+	errs     []error
+}
+
+func (c *compiler) error(node interface{ GetSpan() ast.Span }, format string, args ...any) string {
+	c.errs = append(c.errs, relql.NodeError(node, fmt.Sprintf(format, args...)))
+	return "NULL"
+}
+
+func quoteIdent(name string) string {
+	return pgx.Identifier{name}.Sanitize()
+}
+
+func indent(sql string) string {
+	return "\t" + strings.ReplaceAll(sql, "\n", "\n\t")
+}
+
+// Give the next alias to a relation of the query
+func (c *compiler) alias(rel *ast.AstRelation) string {
+	var alias = "t" + strconv.Itoa(len(c.aliases))
+	c.aliases[rel] = alias
+	return alias
+}
+
+// The SELECT that gives the json array of the rows of a relation, in the order the relation asks for.
+//
+// json_agg does not have to follow the order of the rows it is given, so when there is one the rows
+// are turned into a "row" record, next to the keys they are sorted by, that json_agg sorts again.
+func (c *compiler) aggregate(rel *ast.AstRelation, alias string, join []string) string {
+	var n = strings.TrimPrefix(alias, "t")
+	var rows = c.rows(rel, alias, join, len(rel.Order) > 0)
+
+	if len(rel.Order) == 0 {
+		// rN.* and not rN, which would be a column of the rows if a field had that name
+		return "SELECT coalesce(json_agg(r" + n + ".*), '[]'::json) AS \"json\" FROM (\n" + indent(rows) + "\n) AS r" + n
+	}
+
+	var order []string
+	for i, o := range rel.Order {
+		order = append(order, "r"+n+"."+orderKey(i)+orderDirection(o))
+	}
+	return "SELECT coalesce(json_agg(r" + n + ".\"row\" ORDER BY " + strings.Join(order, ", ") + "), '[]'::json) AS \"json\" FROM (\n" +
+		indent(rows) + "\n) AS r" + n
+}
+
+// The name of the column that holds the i-th key rows are sorted by
+func orderKey(i int) string {
+	return quoteIdent("order" + strconv.Itoa(i+1))
+}
+
+// The SELECT that gives the rows of a relation, with one column per field.
+// join is the condition that ties a nested relation to the rows of its parent.
+// When keyed, the fields are gathered in a "row" record and the keys the rows are sorted by come after it.
+func (c *compiler) rows(rel *ast.AstRelation, alias string, join []string, keyed bool) string {
+	var columns []string
+	var laterals []string
+
+	for _, field := range rel.Fields {
+		switch f := field.(type) {
+		case *ast.AstWildcard:
+			for _, col := range rel.ResolvedRelation.Columns {
+				columns = append(columns, alias+"."+quoteIdent(col.Name)+" AS "+quoteIdent(col.Name))
+			}
+
+		case *ast.AstField:
+			if f.ResolvedColumn == nil {
+				c.error(f, "unresolved column %s", f.Id.Name)
+				continue
+			}
+			columns = append(columns, alias+"."+quoteIdent(f.ResolvedColumn.Name)+" AS "+quoteIdent(FieldName(f)))
+
+		case *ast.AstComputedField:
+			columns = append(columns, c.expression(f.Expression)+" AS "+quoteIdent(FieldName(f)))
+
+		case *ast.AstRelation:
+			var lateral, name = c.nested(f, alias)
+			if lateral == "" {
+				continue
+			}
+			laterals = append(laterals, lateral)
+			columns = append(columns, name+".\"json\" AS "+quoteIdent(FieldName(f)))
+		}
+	}
+
+	var conditions = join
+	if rel.Where != nil {
+		conditions = append(conditions, c.expression(rel.Where))
+	}
+
+	var order, keys []string
+	for i, o := range rel.Order {
+		var expr = c.orderExpression(o)
+		if keyed {
+			// Output columns can be used in ORDER BY, which spares computing the keys twice
+			keys = append(keys, expr+" AS "+orderKey(i))
+			expr = orderKey(i)
+		}
+		order = append(order, expr+orderDirection(o))
+	}
+
+	if keyed {
+		var e = "e" + strings.TrimPrefix(alias, "t")
+		var record = "(SELECT " + e + " FROM (SELECT\n\t\t" + strings.Join(columns, ",\n\t\t") + "\n\t) AS " + e + ") AS \"row\""
+		columns = append([]string{record}, keys...)
+	}
+
+	var b strings.Builder
+	b.WriteString("SELECT\n\t")
+	b.WriteString(strings.Join(columns, ",\n\t"))
+	b.WriteString("\nFROM " + rel.ResolvedRelation.Identifier.String() + " AS " + alias)
+	for _, lateral := range laterals {
+		b.WriteString("\n" + lateral)
+	}
+
+	if len(conditions) > 0 {
+		b.WriteString("\nWHERE " + strings.Join(conditions, " AND "))
+	}
+
+	if len(order) > 0 {
+		b.WriteString("\nORDER BY " + strings.Join(order, ", "))
+	}
+
+	if rel.Limit != nil {
+		b.WriteString("\nLIMIT " + c.count(rel.Limit))
+	}
+	if rel.Offset != nil {
+		b.WriteString("\nOFFSET " + c.count(rel.Offset))
+	}
+
+	return b.String()
+}
+
+// The LATERAL join that gives the json of a nested relation for each row of its parent, and its alias.
+// To-many relationships give an array, empty when there are no rows, and to-one relationships an object or null.
+func (c *compiler) nested(rel *ast.AstRelation, parent_alias string) (string, string) {
+	var rs = rel.ResolvedRelationship
+	if rs == nil || rel.ResolvedRelation == nil {
+		c.error(rel.Id, "unresolved relation %s", rel.Id.String())
+		return "", ""
+	}
+
+	var parent_columns, columns []*pg.Column
+	if rs.Outgoing != nil {
+		parent_columns, columns = rs.Outgoing.SelfColumns, rs.Outgoing.OtherColumns
+	} else {
+		parent_columns, columns = rs.Incoming.SelfColumns, rs.Incoming.OtherColumns
+	}
+
+	var alias = c.alias(rel)
+	var join []string
+	for i, col := range columns {
+		join = append(join, alias+"."+quoteIdent(col.Name)+" = "+parent_alias+"."+quoteIdent(parent_columns[i].Name))
+	}
+
+	var n = strings.TrimPrefix(alias, "t")
+
+	var agg string
+	if rs.IsToOne() {
+		agg = "SELECT row_to_json(r" + n + ".*) AS \"json\" FROM (\n" + indent(c.rows(rel, alias, join, false)) + "\n) AS r" + n
+	} else {
+		agg = c.aggregate(rel, alias, join)
+	}
+
+	return "LEFT JOIN LATERAL (\n" + indent(agg) + "\n) AS j" + n + " ON true", "j" + n
+}
+
+// The value of LIMIT or OFFSET, whose numbers are bigints and not the int4 they would be elsewhere
+func (c *compiler) count(expr ast.IAstExpression) string {
+	if n, ok := expr.(*ast.AstNumber); ok {
+		return c.literal(n.Value, "int8")
+	}
+	return c.expression(expr)
+}
+
+// The expression rows are sorted by, without its direction
+func (c *compiler) orderExpression(expr ast.IAstExpression) string {
+	if o, ok := expr.(*ast.AstOrderExpression); ok {
+		return c.expression(o.Expression)
+	}
+	return c.expression(expr)
+}
+
+func orderDirection(expr ast.IAstExpression) string {
+	var o, ok = expr.(*ast.AstOrderExpression)
+	if !ok {
+		return ""
+	}
+
+	var res string
+	if o.Desc {
+		res += " DESC"
+	}
+	if o.NullsFirst {
+		res += " NULLS FIRST"
+	} else if o.NullsLast {
+		res += " NULLS LAST"
+	}
+	return res
+}
+
+//----------------------------------------------------------------------------------
+
+// A literal of the query, as a placeholder cast to the type postgres would have given it.
+// The type is qualified like the ones of parameters, so that a type of the search path can't take its place.
+func (c *compiler) literal(value string, type_name string) string {
+	var placeholder = c.params.Literal(value, c.resolver.typeByName("pg_catalog", type_name))
+	if type_name == "unknown" {
+		return placeholder
+	}
+	return placeholder + "::" + pg.SqlIdentifier{Schema: "pg_catalog", Name: type_name}.String()
+}
+
+func (c *compiler) expressions(exprs []ast.IAstExpression) string {
+	var res = make([]string, len(exprs))
+	for i, e := range exprs {
+		res[i] = c.expression(e)
+	}
+	return strings.Join(res, ", ")
+}
+
+func (c *compiler) expression(expr ast.IAstExpression) string {
+	switch e := expr.(type) {
+	case *ast.AstIdentifier:
+		if e.ResolvedColumn == nil || e.ResolvedRelation == nil {
+			return c.error(e, "unresolved column %s", e.Name)
+		}
+		return c.aliases[e.ResolvedRelation] + "." + quoteIdent(e.ResolvedColumn.Name)
+
+	case *ast.AstMemberExpression:
+		if e.ResolvedRelation != nil {
+			if e.Member == "*" {
+				return c.aliases[e.ResolvedRelation] + ".*"
+			}
+			return c.aliases[e.ResolvedRelation] + "." + quoteIdent(e.ResolvedColumn.Name)
+		}
+		return "(" + c.expression(e.Expression) + ")." + quoteIdent(e.Member)
+
+	case *ast.AstNumber:
+		return c.literal(e.Value, c.resolver.numberType(e.Value).PgIdentifier.Name)
+
+	case *ast.AstString:
+		if e.IsHex {
+			// Token.StringValue only lets hexadecimal digits through
+			var bits strings.Builder
+			for _, d := range e.Value {
+				v, _ := strconv.ParseUint(string(d), 16, 8)
+				fmt.Fprintf(&bits, "%04b", v)
+			}
+			return c.literal(bits.String(), "varbit")
+		}
+		if e.IsBit {
+			return c.literal(e.Value, "varbit")
+		}
+		return c.literal(e.Value, "unknown")
+
+	case *ast.AstBoolean:
+		return c.literal(strconv.FormatBool(e.Value), "bool")
+
+	case *ast.AstNull:
+		return "NULL"
+
+	case *ast.AstStar:
+		return "*"
+
+	case *ast.AstPositionalParameter, *ast.AstNamedParameter:
+		placeholder, err := c.params.Param(e)
+		if err != nil {
+			return c.error(e, "%s", err.Error())
+		}
+		if t := parameterType(e); t != nil {
+			return placeholder + "::" + t.PgIdentifier.String()
+		}
+		return placeholder
+
+	case *ast.AstUnaryExpression:
+		if e.Operator == "not" {
+			return "(NOT " + c.expression(e.Operand) + ")"
+		}
+		return "(" + e.Operator + " " + c.expression(e.Operand) + ")"
+
+	case *ast.AstBinaryExpression:
+		var op = e.Operator
+		if op == "and" || op == "or" {
+			op = strings.ToUpper(op)
+		}
+		return "(" + c.expression(e.Left) + " " + op + " " + c.expression(e.Right) + ")"
+
+	case *ast.AstCastExpression:
+		return "CAST(" + c.expression(e.Expression) + " AS " + c.typeName(e.Type) + ")"
+
+	case *ast.AstIsExpression:
+		var not = ""
+		if e.Not {
+			not = "NOT "
+		}
+		return "(" + c.expression(e.Expression) + " IS " + not + strings.ToUpper(e.Test) + ")"
+
+	case *ast.AstDistinctExpression:
+		var not = ""
+		if e.Not {
+			not = "NOT "
+		}
+		return "(" + c.expression(e.Left) + " IS " + not + "DISTINCT FROM " + c.expression(e.Right) + ")"
+
+	case *ast.AstBetweenExpression:
+		var op = "BETWEEN "
+		if e.Not {
+			op = "NOT " + op
+		}
+		if e.Symmetric {
+			op += "SYMMETRIC "
+		}
+		return "(" + c.expression(e.Expression) + " " + op + c.expression(e.Low) + " AND " + c.expression(e.High) + ")"
+
+	case *ast.AstInExpression:
+		var op = "IN"
+		if e.Not {
+			op = "NOT IN"
+		}
+		return "(" + c.expression(e.Expression) + " " + op + " (" + c.expressions(e.List) + "))"
+
+	case *ast.AstLikeExpression:
+		var op = strings.ToUpper(e.Operator)
+		if e.Not {
+			op = "NOT " + op
+		}
+		var res = "(" + c.expression(e.Expression) + " " + op + " " + c.expression(e.Pattern)
+		if e.Escape != nil {
+			res += " ESCAPE " + c.expression(e.Escape)
+		}
+		return res + ")"
+
+	case *ast.AstSubscriptExpression:
+		var res = "(" + c.expression(e.Expression) + ")["
+		if e.Index != nil {
+			res += c.expression(e.Index)
+		}
+		if e.IsSlice {
+			res += ":"
+			if e.Upper != nil {
+				res += c.expression(e.Upper)
+			}
+		}
+		return res + "]"
+
+	case *ast.AstFunctionCall:
+		return c.function(e)
+
+	case *ast.AstNamedArgument:
+		return quoteIdent(e.Name) + " => " + c.expression(e.Value)
+
+	case *ast.AstCaseExpression:
+		var b strings.Builder
+		b.WriteString("CASE")
+		if e.Subject != nil {
+			b.WriteString(" " + c.expression(e.Subject))
+		}
+		for _, when := range e.Whens {
+			b.WriteString(" WHEN " + c.expression(when.Condition) + " THEN " + c.expression(when.Result))
+		}
+		if e.Else != nil {
+			b.WriteString(" ELSE " + c.expression(e.Else))
+		}
+		b.WriteString(" END")
+		return b.String()
+
+	case *ast.AstArrayExpression:
+		return "ARRAY[" + c.expressions(e.Elements) + "]"
+
+	case *ast.AstRowExpression:
+		return "ROW(" + c.expressions(e.Elements) + ")"
+	}
+
+	return c.error(expr, "cannot compile %T", expr)
+}
+
+func (c *compiler) function(call *ast.AstFunctionCall) string {
+	var name string
+	switch f := call.ResolvedFunction; {
+	case f != nil:
+		// The resolver does not find the others, but the tree may have been resolved by other means
+		if !c.resolver.Db.Options.IsExposed(f.Identifier.Schema) {
+			return c.error(call.Function, "function %s is not exposed", f.Identifier.String())
+		}
+		name = f.Identifier.String()
+	case isBuiltinCall(call):
+		// Built-in functions like coalesce are part of the grammar and can't be quoted
+		name = call.Function.(*ast.AstIdentifier).Name
+	default:
+		return c.error(call.Function, "unresolved function")
+	}
+
+	var args string
+	if call.Star {
+		args = "*"
+	} else {
+		args = c.expressions(call.Arguments)
+	}
+	if call.Distinct {
+		args = "DISTINCT " + args
+	}
+	return name + "(" + args + ")"
+}
+
+// Type modifiers are kept as they were written since they can't be parameters
+func (c *compiler) typeName(tn *ast.AstTypeName) string {
+	var element = tn.ResolvedType
+	for i := 0; i < tn.ArrayDimensions && element.IsArray(); i++ {
+		element = element.ElementType
+	}
+
+	var res string
+	if element != nil {
+		res = element.PgIdentifier.String()
+	} else if tn.Schema != "" {
+		res = quoteIdent(tn.Schema) + "." + quoteIdent(tn.Name)
+	} else {
+		return c.error(tn, "unresolved type %s", tn.Name)
+	}
+
+	if len(tn.Modifiers) > 0 {
+		var mods []string
+		for _, m := range tn.Modifiers {
+			n, ok := m.(*ast.AstNumber)
+			if !ok {
+				return c.error(m, "type modifiers must be numbers")
+			}
+			mods = append(mods, n.Value)
+		}
+		res += "(" + strings.Join(mods, ", ") + ")"
+	} else if element != nil && element.PgIdentifier.Name == "bpchar" && tn.Name != "bpchar" {
+		// char and character without a length are char(1)
+		res += "(1)"
+	}
+
+	return res + strings.Repeat("[]", tn.ArrayDimensions)
+}
@@ -0,0 +1,126 @@
+// Copyright 2025 Christophe Eymard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package relqlpg
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ceymard/pgrel/pg"
+	"github.com/ceymard/pgrel/relql"
+	"github.com/ceymard/pgrel/relql/ast"
+)
+
+var update = flag.Bool("update", false, "rewrite the .sql files of testdata/compile")
+
+// Every .relql file of testdata/compile is compiled and compared with the .sql file next to it, which holds
+// the statement followed by its arguments when given $1 = 7, :min = 2 and :n = 3.
+func TestCompileGolden(t *testing.T) {
+	var db = loadCatalog(t)
+
+	files, err := filepath.Glob("testdata/compile/*.relql")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, file := range files {
+		t.Run(strings.TrimSuffix(filepath.Base(file), ".relql"), func(t *testing.T) {
+			source, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			q, err := Prepare(db, source)
+			if err != nil {
+				t.Fatal(err)
+			}
+			args, err := q.Args([]any{"7"}, map[string]any{"min": "2", "n": "3"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var b strings.Builder
+			b.WriteString(q.Sql + "\n")
+			for i, arg := range args {
+				fmt.Fprintf(&b, "-- $%d = %T(%v)\n", i+1, arg, arg)
+			}
+
+			var golden = strings.TrimSuffix(file, ".relql") + ".sql"
+			if *update {
+				if err := os.WriteFile(golden, []byte(b.String()), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+
+			expected, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if b.String() != string(expected) {
+				t.Errorf("compiled %s differs from %s:\n%s", file, golden, b.String())
+			}
+		})
+	}
+}
+
+// Only the functions of the exposed schemas and a few built-ins can be called
+func TestCompileFunctions(t *testing.T) {
+	var db = loadCatalog(t)
+
+	for _, c := range []struct {
+		source string
+		err    string
+	}{
+		{`orders { id, total: order_total(id) }`, ""},
+		{`orders { id, quantity: coalesce(quantity, 0) }`, ""},
+		{`orders { id, n: pg_sleep(1) }`, "unknown function pg_sleep"},
+		{`orders { id, total: order_totl(id) }`, "unknown function order_totl, did you mean order_total?"},
+		{`orders { id, quantity: "coalesce"(quantity, 0) }`, "unknown function coalesce"},
+	} {
+		_, err := Prepare(db, []byte(c.source))
+		switch {
+		case c.err == "" && err != nil:
+			t.Errorf("%s: %v", c.source, err)
+		case c.err != "" && (err == nil || !strings.HasSuffix(err.Error(), c.err)):
+			t.Errorf("%s: expected %q, got %v", c.source, c.err, err)
+		}
+	}
+}
+
+// A tree that was resolved by other means can't call a function of a schema that is not exposed
+func TestCompileUnexposedFunction(t *testing.T) {
+	var db = loadCatalog(t)
+
+	rel, err := relql.Parse([]byte(`orders { id, total: order_total(id) }`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Resolve(db, rel); err != nil {
+		t.Fatal(err)
+	}
+
+	var call = rel.Fields[1].(*ast.AstComputedField).Expression.(*ast.AstFunctionCall)
+	call.ResolvedFunction = db.GetFunctionOverloads(pg.SqlIdentifier{Schema: "internal", Name: "secret"})[0]
+
+	var expected = `function "internal"."secret" is not exposed`
+	if _, err := Compile(db, rel); err == nil || !strings.HasSuffix(err.Error(), expected) {
+		t.Errorf("expected %q, got %v", expected, err)
+	}
+}
@@ -1,10 +1,905 @@
+// Copyright 2025 Christophe Eymard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
 package relqlpg
 
 import (
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
 	"github.com/ceymard/pgrel/pg"
+	"github.com/ceymard/pgrel/relql"
+	"github.com/ceymard/pgrel/relql/ast"
+	"gitlab.com/tozd/go/errors"
 )
 
-// Resolve the correct by linking the ast to the known informations
-func Resolve(db *pg.DbInfos) {
+// Links a parsed query to the catalog : relations, columns, functions, types and the foreign keys
+// nested relations are reached through.
+type Resolver struct {
+	Db *pg.DbInfos
+
+	// Where unqualified relations and functions are looked up, in order
+	SearchPath []string
+
+	errs  []error
+	types map[string]*pg.Type
+}
+
+func NewResolver(db *pg.DbInfos) *Resolver {
+	return &Resolver{
+		Db:         db,
+		SearchPath: db.Options.SearchPath(),
+	}
+}
+
+// Link the ast to the catalog, looking names up in the search path of the options the catalog was loaded with.
+func Resolve(db *pg.DbInfos, rel *ast.AstRelation) error {
+	return NewResolver(db).Resolve(rel)
+}
+
+// Resolve the whole tree. All the errors that were found are returned together, each of them
+// being a *relql.Diagnostic that points at the offending part of the query.
+func (r *Resolver) Resolve(rel *ast.AstRelation) error {
+	r.errs = nil
+
+	if rel.ResolvedRelation = r.lookupRelation(rel.Id); rel.ResolvedRelation == nil {
+		r.unknownRelation(rel.Id)
+		return errors.Join(r.errs...)
+	}
+
+	r.relation(&scope{rel: rel})
+	return errors.Join(r.errs...)
+}
+
+func (r *Resolver) error(node interface{ GetSpan() ast.Span }, format string, args ...any) {
+	r.errs = append(r.errs, relql.NodeError(node, fmt.Sprintf(format, args...)))
+}
+
+// The relations of the query a name can be taken from, the innermost first
+type scope struct {
+	rel    *ast.AstRelation
+	parent *scope
+}
+
+//----------------------------------------------------------------------------------
+
+// Find a relation by name, amongst the exposed ones only
+func (r *Resolver) lookupRelation(id *ast.AstSqlIdentifier) *pg.Relation {
+	return r.Db.LookupRelationIn(r.SearchPath, id.Schema, id.Name)
+}
+
+func (r *Resolver) isExposed(rel *pg.Relation) bool {
+	return r.Db.Options.IsExposed(rel.Identifier.Schema)
+}
+
+func (r *Resolver) unknownRelation(id *ast.AstSqlIdentifier) {
+	var candidates []string
+	for _, rel := range r.Db.Relations {
+		if !r.isExposed(rel) {
+			continue
+		}
+		if id.Schema != "" {
+			candidates = append(candidates, rel.Identifier.Schema+"."+rel.Identifier.Name)
+		} else if r.inSearchPath(rel.Identifier.Schema) {
+			candidates = append(candidates, rel.Identifier.Name)
+		}
+	}
+	r.error(id, "%s", didYouMean(fmt.Sprintf("unknown relation %s", id.String()), id.String(), candidates))
+}
+
+func (r *Resolver) inSearchPath(schema string) bool {
+	for _, s := range r.SearchPath {
+		if s == schema {
+			return true
+		}
+	}
+	return false
+}
+
+// Resolve the fields, filter and ordering of a relation whose ResolvedRelation is set
+func (r *Resolver) relation(sc *scope) {
+	var rel = sc.rel
+	var names = make(map[string]bool)
+
+	var add_name = func(node ast.IAstField, name string) {
+		if names[name] {
+			r.error(node, "field %s is selected more than once, rename one of them with other_name: %s", name, name)
+		}
+		names[name] = true
+	}
+
+	for _, field := range rel.Fields {
+		switch f := field.(type) {
+		case *ast.AstWildcard:
+			for _, c := range rel.ResolvedRelation.Columns {
+				add_name(f, c.Name)
+			}
+
+		case *ast.AstField:
+			if f.ResolvedColumn = rel.ResolvedRelation.ColumnsMap[f.Id.Name]; f.ResolvedColumn == nil {
+				r.unknownColumn(f.Id, f.Id.Name, rel.ResolvedRelation)
+				continue
+			}
+			add_name(f, FieldName(f))
+
+		case *ast.AstComputedField:
+			r.expression(sc, f.Expression)
+			var name = FieldName(f)
+			if name == "" {
+				r.error(f, "this field needs a name, give it one with name: expression")
+				continue
+			}
+			add_name(f, name)
+
+		case *ast.AstRelation:
+			if r.nested(sc, f) {
+				r.relation(&scope{rel: f, parent: sc})
+			}
+			add_name(f, FieldName(f))
+		}
+	}
+
+	if rel.Where != nil {
+		r.expression(sc, rel.Where)
+	}
+
+	for _, order := range rel.Order {
+		if o, ok := order.(*ast.AstOrderExpression); ok {
+			r.expression(sc, o.Expression)
+		}
+	}
+
+	// Parameters of LIMIT and OFFSET are bigints, like in postgres
+	for _, count := range []ast.IAstExpression{rel.Limit, rel.Offset} {
+		if count != nil {
+			r.infer(count, r.typeByName("pg_catalog", "int8"))
+		}
+	}
+}
+
+// The key of a field in the result
+func FieldName(field ast.IAstField) string {
+	switch f := field.(type) {
+	case *ast.AstField:
+		if f.Alias != "" {
+			return f.Alias
+		}
+		return f.Id.Name
+	case *ast.AstRelation:
+		if f.Alias != "" {
+			return f.Alias
+		}
+		return f.Id.Name
+	case *ast.AstComputedField:
+		if f.Alias != "" {
+			return f.Alias
+		}
+		// Like postgres, name the column after what the expression ends with
+		switch e := f.Expression.(type) {
+		case *ast.AstIdentifier:
+			return e.Name
+		case *ast.AstMemberExpression:
+			return e.Member
+		case *ast.AstFunctionCall:
+			if fn, ok := e.Function.(*ast.AstIdentifier); ok {
+				return fn.Name
+			}
+			if fn, ok := e.Function.(*ast.AstMemberExpression); ok {
+				return fn.Member
+			}
+		case *ast.AstCastExpression:
+			return FieldName(&ast.AstComputedField{Expression: e.Expression})
+		}
+	}
+	return ""
+}
+
+func (r *Resolver) unknownColumn(node interface{ GetSpan() ast.Span }, name string, rel *pg.Relation) {
+	var message = fmt.Sprintf("unknown column %s in %s", name, rel.Identifier.Name)
+
+	if len(r.relationships(rel, &ast.AstSqlIdentifier{Name: name})) > 0 {
+		r.error(node, "%s is a related relation, select its fields with %s { ... }", name, name)
+		return
+	}
+
+	var candidates []string
+	for _, c := range rel.Columns {
+		candidates = append(candidates, c.Name)
+	}
+	r.error(node, "%s", didYouMean(message, name, candidates))
+}
+
+//----------------------------------------------------------------------------------
+
+// Both sides of a self-referencing foreign key would have the constraint's name, the incoming one
+// takes this suffix so that it can be told apart.
+const REVERSE_SUFFIX = "_reverse"
+
+// The name a relationship is looked up by amongst the foreign key names
+func relationshipName(parent *pg.Relation, rs *ast.AstRelationship) string {
+	if rs.Incoming != nil && rs.Incoming.OtherRelation == parent {
+		return rs.Incoming.Identifier.Name + REVERSE_SUFFIX
+	}
+	return rs.ForeignKey().Identifier.Name
+}
+
+// The relationships a nested relation of that name could go through, the best matches only.
+// A name is first looked for amongst the foreign key names, then as a foreign key column without its _id suffix,
+// then as the name of the related relation. Foreign keys that lead out of the exposed schemas are not followed.
+//
+// The relation's own name leads to the incoming side of a self-referencing foreign key, the rows that refer to this
+// one, since the outgoing side can be reached by its column.
+func (r *Resolver) relationships(parent *pg.Relation, id *ast.AstSqlIdentifier) []*ast.AstRelationship {
+	var by_fk, by_column, by_relation []*ast.AstRelationship
+
+	var matches_relation = func(other *pg.Relation) bool {
+		if id.Schema != "" {
+			return other.Identifier.Schema == id.Schema && other.Identifier.Name == id.Name
+		}
+		return other.Identifier.Name == id.Name
+	}
+
+	for _, fk := range parent.OutgoingForeignKeys {
+		if !r.isExposed(fk.OtherRelation) {
+			continue
+		}
+		var rs = &ast.AstRelationship{Outgoing: fk}
+		switch {
+		case id.Schema == "" && fk.Identifier.Name == id.Name:
+			by_fk = append(by_fk, rs)
+		case id.Schema == "" && len(fk.SelfColumnNames) == 1 && strings.HasSuffix(fk.SelfColumnNames[0], "_id") && strings.TrimSuffix(fk.SelfColumnNames[0], "_id") == id.Name:
+			by_column = append(by_column, rs)
+		case fk.OtherRelation != parent && matches_relation(fk.OtherRelation):
+			by_relation = append(by_relation, rs)
+		}
+	}
+
+	for _, fk := range parent.IncomingForeignKeys {
+		if !r.isExposed(fk.OtherRelation) {
+			continue
+		}
+		var rs = &ast.AstRelationship{Incoming: fk}
+		switch {
+		case id.Schema == "" && relationshipName(parent, rs) == id.Name:
+			by_fk = append(by_fk, rs)
+		case matches_relation(fk.OtherRelation):
+			by_relation = append(by_relation, rs)
+		}
+	}
+
+	for _, found := range [][]*ast.AstRelationship{by_fk, by_column, by_relation} {
+		if len(found) > 0 {
+			return found
+		}
+	}
+	return nil
+}
+
+// Find how a nested relation is reached from its parent
+func (r *Resolver) nested(sc *scope, rel *ast.AstRelation) bool {
+	var parent = sc.rel.ResolvedRelation
+	var found = r.relationships(parent, rel.Id)
+
+	switch len(found) {
+	case 1:
+		rel.ResolvedRelationship = found[0]
+		if found[0].Outgoing != nil {
+			rel.ResolvedRelation = found[0].Outgoing.OtherRelation
+		} else {
+			rel.ResolvedRelation = found[0].Incoming.OtherRelation
+		}
+		return true
+
+	case 0:
+		var candidates []string
+		for _, fk := range parent.OutgoingForeignKeys {
+			if !r.isExposed(fk.OtherRelation) {
+				continue
+			}
+			candidates = append(candidates, fk.Identifier.Name, fk.OtherRelation.Identifier.Name)
+			if len(fk.SelfColumnNames) == 1 && strings.HasSuffix(fk.SelfColumnNames[0], "_id") {
+				candidates = append(candidates, strings.TrimSuffix(fk.SelfColumnNames[0], "_id"))
+			}
+		}
+		for _, fk := range parent.IncomingForeignKeys {
+			if !r.isExposed(fk.OtherRelation) {
+				continue
+			}
+			candidates = append(candidates, relationshipName(parent, &ast.AstRelationship{Incoming: fk}), fk.OtherRelation.Identifier.Name)
+		}
+
+		var message = fmt.Sprintf("no foreign key links %s to %s", parent.Identifier.Name, rel.Id.String())
+		if r.lookupRelation(rel.Id) == nil {
+			message = fmt.Sprintf("unknown relation %s from %s", rel.Id.String(), parent.Identifier.Name)
+		}
+		r.error(rel.Id, "%s", didYouMean(message, rel.Id.String(), candidates))
+		return false
+
+	default:
+		var names []string
+		for _, rs := range found {
+			names = append(names, relationshipName(parent, rs))
+		}
+		r.error(rel.Id, "%s can be reached from %s through several foreign keys, use the name of one of them instead : %s",
+			rel.Id.String(), parent.Identifier.Name, strings.Join(names, ", "))
+		return false
+	}
+}
+
+//----------------------------------------------------------------------------------
+
+// Resolve an expression and return its type, or nil if it can't be told
+func (r *Resolver) expression(sc *scope, expr ast.IAstExpression) *pg.Type {
+	switch e := expr.(type) {
+	case *ast.AstIdentifier:
+		for cur := sc; cur != nil; cur = cur.parent {
+			if col := cur.rel.ResolvedRelation.ColumnsMap[e.Name]; col != nil {
+				e.ResolvedColumn, e.ResolvedRelation = col, cur.rel
+				return col.Type
+			}
+		}
+		r.unknownColumn(e, e.Name, sc.rel.ResolvedRelation)
+		return nil
+
+	case *ast.AstMemberExpression:
+		return r.member(sc, e)
+
+	case *ast.AstNumber:
+		return r.numberType(e.Value)
+
+	case *ast.AstString:
+		if e.IsBit {
+			return r.typeByName("pg_catalog", "varbit")
+		}
+		return r.Db.GetType(pg.UNKNOWN_TYPE_OID)
+
+	case *ast.AstBoolean:
+		return r.typeByName("pg_catalog", "bool")
+
+	case *ast.AstNull, *ast.AstStar:
+		return nil
+
+	case *ast.AstPositionalParameter, *ast.AstNamedParameter:
+		return parameterType(e)
+
+	case *ast.AstUnaryExpression:
+		var t = r.expression(sc, e.Operand)
+		if e.Operator == "not" {
+			r.infer(e.Operand, r.typeByName("pg_catalog", "bool"))
+			return r.typeByName("pg_catalog", "bool")
+		}
+		return r.operator(e, e.Operator, nil, t)
+
+	case *ast.AstBinaryExpression:
+		var lt = r.expression(sc, e.Left)
+		var rt = r.expression(sc, e.Right)
+
+		if e.Operator == "and" || e.Operator == "or" {
+			var boolean = r.typeByName("pg_catalog", "bool")
+			r.infer(e.Left, boolean)
+			r.infer(e.Right, boolean)
+			return boolean
+		}
+
+		lt, rt = r.inferBoth(e.Left, lt, e.Right, rt)
+		return r.operator(e, e.Operator, lt, rt)
+
+	case *ast.AstCastExpression:
+		r.expression(sc, e.Expression)
+		var t = r.typeName(e.Type)
+		r.infer(e.Expression, t)
+		return t
+
+	case *ast.AstIsExpression:
+		r.expression(sc, e.Expression)
+		return r.typeByName("pg_catalog", "bool")
+
+	case *ast.AstDistinctExpression:
+		r.inferBoth(e.Left, r.expression(sc, e.Left), e.Right, r.expression(sc, e.Right))
+		return r.typeByName("pg_catalog", "bool")
+
+	case *ast.AstBetweenExpression:
+		var t = r.expression(sc, e.Expression)
+		for _, bound := range []ast.IAstExpression{e.Low, e.High} {
+			r.inferBoth(e.Expression, t, bound, r.expression(sc, bound))
+		}
+		return r.typeByName("pg_catalog", "bool")
+
+	case *ast.AstInExpression:
+		var t = r.expression(sc, e.Expression)
+		for _, item := range e.List {
+			r.inferBoth(e.Expression, t, item, r.expression(sc, item))
+		}
+		return r.typeByName("pg_catalog", "bool")
+
+	case *ast.AstLikeExpression:
+		r.expression(sc, e.Expression)
+		r.expression(sc, e.Pattern)
+		r.infer(e.Pattern, r.typeByName("pg_catalog", "text"))
+		if e.Escape != nil {
+			r.expression(sc, e.Escape)
+			r.infer(e.Escape, r.typeByName("pg_catalog", "text"))
+		}
+		return r.typeByName("pg_catalog", "bool")
+
+	case *ast.AstSubscriptExpression:
+		var t = r.expression(sc, e.Expression)
+		for _, index := range []ast.IAstExpression{e.Index, e.Upper} {
+			if index != nil {
+				r.expression(sc, index)
+				r.infer(index, r.typeByName("pg_catalog", "int4"))
+			}
+		}
+		if e.IsSlice || t == nil {
+			return t
+		}
+		return t.UnderlyingType().ElementType
+
+	case *ast.AstFunctionCall:
+		return r.function(sc, e)
+
+	case *ast.AstNamedArgument:
+		return r.expression(sc, e.Value)
+
+	case *ast.AstCaseExpression:
+		var res *pg.Type
+		if e.Subject != nil {
+			r.expression(sc, e.Subject)
+		}
+		for _, when := range e.Whens {
+			r.expression(sc, when.Condition)
+			if t := r.expression(sc, when.Result); res == nil && t != nil && !t.IsUnknown() {
+				res = t
+			}
+		}
+		if e.Else != nil {
+			if t := r.expression(sc, e.Else); res == nil && t != nil && !t.IsUnknown() {
+				res = t
+			}
+		}
+		return res
+
+	case *ast.AstArrayExpression:
+		var element *pg.Type
+		for _, elt := range e.Elements {
+			if t := r.expression(sc, elt); element == nil && t != nil && !t.IsUnknown() {
+				element = t
+			}
+		}
+		for _, elt := range e.Elements {
+			r.infer(elt, element)
+		}
+		if element == nil {
+			return nil
+		}
+		return element.ArrayType
+
+	case *ast.AstRowExpression:
+		for _, elt := range e.Elements {
+			r.expression(sc, elt)
+		}
+		return r.typeByName("pg_catalog", "record")
+	}
+
+	return nil
+}
+
+// relation.column, composite.attribute or relation.*
+func (r *Resolver) member(sc *scope, e *ast.AstMemberExpression) *pg.Type {
+	// A relation of the query, by its alias or its name
+	if ident, ok := e.Expression.(*ast.AstIdentifier); ok {
+		for cur := sc; cur != nil; cur = cur.parent {
+			if cur.rel.Alias != ident.Name && (cur.rel.Alias != "" || cur.rel.Id.Name != ident.Name) {
+				continue
+			}
+			e.ResolvedRelation = cur.rel
+			if e.Member == "*" {
+				return cur.rel.ResolvedRelation.Type
+			}
+			if e.ResolvedColumn = cur.rel.ResolvedRelation.ColumnsMap[e.Member]; e.ResolvedColumn == nil {
+				r.unknownColumn(e, e.Member, cur.rel.ResolvedRelation)
+				return nil
+			}
+			return e.ResolvedColumn.Type
+		}
+	}
+
+	var t = r.expression(sc, e.Expression).UnderlyingType()
+	if t == nil {
+		return nil
+	}
+
+	if t.Relation != nil {
+		if col := t.Relation.ColumnsMap[e.Member]; col != nil {
+			return col.Type
+		}
+	}
+	var candidates []string
+	for _, a := range t.Attributes {
+		if a.Name == e.Member {
+			return a.Type
+		}
+		candidates = append(candidates, a.Name)
+	}
+	if t.Relation != nil {
+		for _, c := range t.Relation.Columns {
+			candidates = append(candidates, c.Name)
+		}
+	}
+
+	if t.IsComposite() {
+		r.error(e, "%s", didYouMean(fmt.Sprintf("type %s has no attribute %s", t.PgIdentifier.Name, e.Member), e.Member, candidates))
+	} else {
+		r.error(e, "%s is not a composite type, it has no attribute %s", t.PgIdentifier.Name, e.Member)
+	}
+	return nil
+}
+
+// Give a parameter the type of what it is used with, if it does not have one yet
+func (r *Resolver) infer(expr ast.IAstExpression, t *pg.Type) {
+	if t == nil || t.IsUnknown() {
+		return
+	}
+	switch p := expr.(type) {
+	case *ast.AstPositionalParameter:
+		if p.ResolvedType == nil {
+			p.ResolvedType = t
+		}
+	case *ast.AstNamedParameter:
+		if p.ResolvedType == nil {
+			p.ResolvedType = t
+		}
+	}
+}
+
+// Operands that are parameters take the type of the other side, and their new types are returned
+func (r *Resolver) inferBoth(left ast.IAstExpression, lt *pg.Type, right ast.IAstExpression, rt *pg.Type) (*pg.Type, *pg.Type) {
+	if lt == nil {
+		r.infer(left, rt)
+		lt = parameterType(left)
+	}
+	if rt == nil {
+		r.infer(right, lt)
+		rt = parameterType(right)
+	}
+	return lt, rt
+}
+
+func parameterType(expr ast.IAstExpression) *pg.Type {
+	switch p := expr.(type) {
+	case *ast.AstPositionalParameter:
+		return p.ResolvedType
+	case *ast.AstNamedParameter:
+		return p.ResolvedType
+	}
+	return nil
+}
+
+// Check that the operator exists for these operand types and return the type of its result.
+// Nothing is checked when the types are not all known or when the catalog has no operators.
+func (r *Resolver) operator(node interface{ GetSpan() ast.Span }, name string, left *pg.Type, right *pg.Type) *pg.Type {
+	var is_comparison = false
+	switch name {
+	case "=", "<", ">", "<=", ">=", "<>", "!=":
+		is_comparison = true
+	}
+	var result *pg.Type
+	if is_comparison {
+		result = r.typeByName("pg_catalog", "bool")
+	}
+
+	if len(r.Db.Operators) == 0 || right == nil {
+		return result
+	}
+	// A nil left operand only stands for a prefix operator
+	if _, binary := node.(*ast.AstBinaryExpression); binary && left == nil {
+		return result
+	}
+
+	if name == "!=" {
+		name = "<>"
+	}
+
+	op, err := r.Db.ResolveOperator(name, left, right)
+	if errors.Is(err, pg.ErrOperatorNotFound) {
+		r.error(node, "%s, you may need to add explicit casts", err.Error())
+		return result
+	}
+	if err != nil {
+		return result
+	}
+	return op.ResultTypeFor(left, right)
+}
+
+// Integers that fit are int4 or int8 like in postgres, the other numbers are numeric
+func (r *Resolver) numberType(value string) *pg.Type {
+	if _, err := strconv.ParseInt(value, 10, 32); err == nil {
+		return r.typeByName("pg_catalog", "int4")
+	}
+	if _, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return r.typeByName("pg_catalog", "int8")
+	}
+	return r.typeByName("pg_catalog", "numeric")
+}
+
+//----------------------------------------------------------------------------------
+
+// The SQL standard names of types that pg_type knows by another name
+var type_aliases = map[string]string{
+	"int":                         "int4",
+	"integer":                     "int4",
+	"smallint":                    "int2",
+	"bigint":                      "int8",
+	"real":                        "float4",
+	"float":                       "float8",
+	"double precision":            "float8",
+	"boolean":                     "bool",
+	"decimal":                     "numeric",
+	"dec":                         "numeric",
+	"character varying":           "varchar",
+	"character":                   "bpchar",
+	"char":                        "bpchar",
+	"bit varying":                 "varbit",
+	"timestamp without time zone": "timestamp",
+	"timestamp with time zone":    "timestamptz",
+	"time without time zone":      "time",
+	"time with time zone":         "timetz",
+}
+
+func (r *Resolver) typeByName(schema string, name string) *pg.Type {
+	if r.types == nil {
+		r.types = make(map[string]*pg.Type, len(r.Db.Types))
+		for _, t := range r.Db.Types {
+			r.types[t.PgIdentifier.Schema+"."+t.PgIdentifier.Name] = t
+		}
+	}
+	return r.types[schema+"."+name]
+}
+
+// Find the type of a cast, looking it up in pg_catalog first like postgres does
+func (r *Resolver) typeName(tn *ast.AstTypeName) *pg.Type {
+	var name = tn.Name
+	if alias, ok := type_aliases[name]; ok && tn.Schema == "" {
+		name = alias
+	}
+
+	var t *pg.Type
+	if tn.Schema != "" {
+		t = r.typeByName(tn.Schema, name)
+	} else {
+		for _, schema := range append([]string{"pg_catalog"}, r.SearchPath...) {
+			if t = r.typeByName(schema, name); t != nil {
+				break
+			}
+		}
+	}
+
+	if t == nil {
+		var candidates []string
+		for _, t := range r.Db.Types {
+			if t.PgIdentifier.Schema == "pg_catalog" || r.inSearchPath(t.PgIdentifier.Schema) {
+				if !strings.HasPrefix(t.PgIdentifier.Name, "_") {
+					candidates = append(candidates, t.PgIdentifier.Name)
+				}
+			}
+		}
+		for alias := range type_aliases {
+			candidates = append(candidates, alias)
+		}
+		r.error(tn, "%s", didYouMean(fmt.Sprintf("unknown type %s", tn.Name), tn.Name, candidates))
+		return nil
+	}
+
+	// Arrays of arrays are the same type in postgres
+	for i := 0; i < tn.ArrayDimensions && !t.IsArray(); i++ {
+		if t.ArrayType == nil {
+			r.error(tn, "type %s has no array type", t.PgIdentifier.Name)
+			return nil
+		}
+		t = t.ArrayType
+	}
+
+	tn.ResolvedType = t
+	return t
+}
+
+//----------------------------------------------------------------------------------
+
+// Functions that can be called without being in the catalog, since they are part of the grammar of postgres
+// or are the common aggregates. Any other function has to be found in the search path or be schema-qualified,
+// so that a query can't call whatever the server provides, like pg_sleep or set_config.
+var builtin_functions = map[string]bool{
+	"coalesce": true,
+	"nullif":   true,
+	"greatest": true,
+	"least":    true,
+	"count":    true,
+	"sum":      true,
+	"avg":      true,
+	"min":      true,
+	"max":      true,
+}
+
+// Tell if the call is to one of builtin_functions, which have to be written unquoted and unqualified
+func isBuiltinCall(call *ast.AstFunctionCall) bool {
+	fn, ok := call.Function.(*ast.AstIdentifier)
+	return ok && !fn.Quoted && builtin_functions[fn.Name]
+}
+
+// Find the function that is called amongst its overloads, by the number and names of its arguments, then by their types.
+// Unqualified functions that are not in the search path have to be one of builtin_functions, which are not checked.
+func (r *Resolver) function(sc *scope, call *ast.AstFunctionCall) *pg.Type {
+	var types = make([]*pg.Type, len(call.Arguments))
+	var positional = 0
+	var named []string
+	for i, arg := range call.Arguments {
+		types[i] = r.expression(sc, arg)
+		if n, ok := arg.(*ast.AstNamedArgument); ok {
+			named = append(named, n.Name)
+		} else {
+			positional++
+		}
+	}
+
+	var schema, name string
+	switch fn := call.Function.(type) {
+	case *ast.AstIdentifier:
+		name = fn.Name
+	case *ast.AstMemberExpression:
+		if ident, ok := fn.Expression.(*ast.AstIdentifier); ok {
+			schema, name = ident.Name, fn.Member
+		}
+	}
+	if name == "" {
+		r.error(call.Function, "expected a function name")
+		return nil
+	}
+
+	// Functions outside of the exposed schemas are neither found nor suggested, just like relations
+	var overloads = r.Db.LookupFunctionsIn(r.SearchPath, schema, name)
+	if len(overloads) == 0 {
+		if schema == "" && isBuiltinCall(call) {
+			return nil
+		}
+
+		var candidates []string
+		for _, f := range r.Db.Functions {
+			if !r.Db.Options.IsExposed(f.Identifier.Schema) {
+				continue
+			}
+			if schema != "" && f.Identifier.Schema == schema || schema == "" && r.inSearchPath(f.Identifier.Schema) {
+				candidates = append(candidates, f.Identifier.Name)
+			}
+		}
+
+		if schema != "" {
+			r.error(call.Function, "%s", didYouMean(fmt.Sprintf("unknown function %s.%s", schema, name), name, candidates))
+		} else {
+			candidates = append(candidates, slices.Sorted(maps.Keys(builtin_functions))...)
+			r.error(call.Function, "%s", didYouMean(fmt.Sprintf("unknown function %s", name), name, candidates))
+		}
+		return nil
+	}
+
+	var candidates []*pg.Function
+	for _, f := range overloads {
+		if acceptsArguments(f, positional, named) {
+			candidates = append(candidates, f)
+		}
+	}
+
+	if len(candidates) > 1 {
+		var typed []*pg.Function
+		for _, f := range candidates {
+			if r.acceptsTypes(f, call.Arguments, types) {
+				typed = append(typed, f)
+			}
+		}
+		if len(typed) > 0 {
+			candidates = typed
+		}
+	}
+
+	switch len(candidates) {
+	case 0:
+		var signatures []string
+		for _, f := range overloads {
+			signatures = append(signatures, fmt.Sprintf("%s(%s)", name, f.Signature))
+		}
+		r.error(call, "no overload of %s takes these arguments, there is %s", name, strings.Join(signatures, ", "))
+		return nil
+	case 1:
+	default:
+		r.error(call, "call to %s is ambiguous, add casts to its arguments", name)
+		return nil
+	}
+
+	var f = candidates[0]
+	call.ResolvedFunction = f
+
+	// Parameters take the type of the argument they are given to
+	var inputs = f.InputArguments()
+	for i, arg := range call.Arguments {
+		if n, ok := arg.(*ast.AstNamedArgument); ok {
+			for _, a := range inputs {
+				if a.Name == n.Name {
+					r.infer(n.Value, a.Type)
+				}
+			}
+		} else if i < len(inputs) && !inputs[i].IsVariadic() {
+			r.infer(arg, inputs[i].Type)
+		}
+	}
+
+	return f.ReturnType
+}
+
+// Tell if a function can be called with that many positional arguments followed by these named ones
+func acceptsArguments(f *pg.Function, positional int, named []string) bool {
+	var inputs = f.InputArguments()
+	var given = make([]bool, len(inputs))
+
+	for i := 0; i < positional; i++ {
+		if i >= len(inputs) {
+			if !f.IsVariadic() {
+				return false
+			}
+			continue
+		}
+		given[i] = true
+	}
+
+	for _, name := range named {
+		var found = false
+		for i, a := range inputs {
+			if a.Name == name && !given[i] {
+				given[i], found = true, true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	for i, a := range inputs {
+		if !given[i] && !a.HasDefault && !a.IsVariadic() {
+			return false
+		}
+	}
+	return true
+}
 
+func (r *Resolver) acceptsTypes(f *pg.Function, args []ast.IAstExpression, types []*pg.Type) bool {
+	var inputs = f.InputArguments()
+	for i, arg := range args {
+		var expected *pg.Type
+		if n, ok := arg.(*ast.AstNamedArgument); ok {
+			for _, a := range inputs {
+				if a.Name == n.Name {
+					expected = a.Type
+				}
+			}
+		} else if i < len(inputs) {
+			expected = inputs[i].Type
+		}
+		if types[i] != nil && expected != nil && !r.Db.CanCoerceImplicitly(types[i], expected) {
+			return false
+		}
+	}
+	return true
 }
@@ -0,0 +1,165 @@
+// Copyright 2025 Christophe Eymard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package relqlpg
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ceymard/pgrel/pg"
+	"github.com/ceymard/pgrel/relql"
+	"github.com/ceymard/pgrel/relql/ast"
+)
+
+func loadCatalog(t *testing.T) *pg.DbInfos {
+	t.Helper()
+	db, err := pg.LoadInfos("testdata/catalog.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func resolveSource(t *testing.T, r *Resolver, source string) (*ast.AstRelation, error) {
+	t.Helper()
+	rel, err := relql.Parse([]byte(source))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rel, r.Resolve(rel)
+}
+
+// Check that the source resolves, or that it fails with an error that ends with expected
+func checkResolve(t *testing.T, db *pg.DbInfos, source string, expected string) {
+	t.Helper()
+	_, err := resolveSource(t, NewResolver(db), source)
+	switch {
+	case expected == "" && err != nil:
+		t.Errorf("%s: %v", source, err)
+	case expected != "" && (err == nil || !strings.HasSuffix(err.Error(), ": "+expected)):
+		t.Errorf("%s: expected %q, got %v", source, expected, err)
+	}
+}
+
+func TestResolveSearchPath(t *testing.T) {
+	var db = loadCatalog(t)
+
+	for _, c := range []struct {
+		search_path []string
+		source      string
+		expected    string
+	}{
+		{nil, `customers { id }`, "api.customers"},
+		{nil, `products { id }`, "shop.products"},
+		{nil, `shop.customers { email }`, "shop.customers"},
+		{[]string{"shop", "api"}, `customers { email }`, "shop.customers"},
+		{[]string{"shop", "api"}, `api.customers { name }`, "api.customers"},
+	} {
+		var r = NewResolver(db)
+		if c.search_path != nil {
+			r.SearchPath = c.search_path
+		}
+		rel, err := resolveSource(t, r, c.source)
+		if err != nil {
+			t.Errorf("%s: %v", c.source, err)
+			continue
+		}
+		var id = rel.ResolvedRelation.Identifier
+		if id.Schema+"."+id.Name != c.expected {
+			t.Errorf("%s: expected %s, got %s.%s", c.source, c.expected, id.Schema, id.Name)
+		}
+	}
+}
+
+// Foreign key names come before foreign key columns, which come before relation names
+func TestResolveRelationships(t *testing.T) {
+	var db = loadCatalog(t)
+
+	for _, c := range []struct {
+		source   string
+		fk       string
+		outgoing bool
+	}{
+		{`orders { customer { id } }`, "orders_customer_id_fkey", true},
+		{`orders { customers { id } }`, "orders_customer_id_fkey", true},
+		{`orders { api.customers { id } }`, "orders_customer_id_fkey", true},
+		{`orders { orders_customer_id_fkey { id } }`, "orders_customer_id_fkey", true},
+		{`customers { orders { id } }`, "orders_customer_id_fkey", false},
+		{`customers { profiles { bio } }`, "profiles_customer_id_fkey", false},
+		{`transfers { target { id } }`, "source", true},
+		{`transfers { source { id } }`, "source", true},
+		{`transfers { customers { id } }`, "transfers_customers_id_fkey", true},
+		// Self-referencing foreign keys
+		{`employees { manager { id } }`, "employees_manager_id_fkey", true},
+		{`employees { employees_manager_id_fkey { id } }`, "employees_manager_id_fkey", true},
+		{`employees { employees_manager_id_fkey_reverse { id } }`, "employees_manager_id_fkey", false},
+		{`employees { employees_mentor_id_fkey_reverse { id } }`, "employees_mentor_id_fkey", false},
+	} {
+		rel, err := resolveSource(t, NewResolver(db), c.source)
+		if err != nil {
+			t.Errorf("%s: %v", c.source, err)
+			continue
+		}
+		var rs = rel.Fields[0].(*ast.AstRelation).ResolvedRelationship
+		if name := rs.ForeignKey().Identifier.Name; name != c.fk || (rs.Outgoing != nil) != c.outgoing {
+			t.Errorf("%s: expected %s outgoing=%v, got %s outgoing=%v", c.source, c.fk, c.outgoing, name, rs.Outgoing != nil)
+		}
+	}
+}
+
+func TestResolveErrors(t *testing.T) {
+	var db = loadCatalog(t)
+
+	for _, c := range []struct {
+		source   string
+		expected string
+	}{
+		{`ordrs { id }`, "unknown relation ordrs, did you mean orders?"},
+		{`orders { quantit }`, "unknown column quantit in orders, did you mean quantity?"},
+		{`orders { custmer { id } }`, "unknown relation custmer from orders, did you mean customer?"},
+		{`orders { id, customer }`, "customer is a related relation, select its fields with customer { ... }"},
+		{`orders { id, id }`, "field id is selected more than once, rename one of them with other_name: id"},
+		{`customers { transfers { id } }`, "transfers can be reached from customers through several foreign keys, use the name of one of them instead : transfers_source_id_fkey, source, transfers_customers_id_fkey"},
+		{`employees { employees { id } }`, "employees can be reached from employees through several foreign keys, use the name of one of them instead : employees_manager_id_fkey_reverse, employees_mentor_id_fkey_reverse"},
+		{`employees { employees_manager_id_fkey_revers { id } }`, "unknown relation employees_manager_id_fkey_revers from employees, did you mean employees_manager_id_fkey_reverse?"},
+	} {
+		checkResolve(t, db, c.source, c.expected)
+	}
+}
+
+// Relations and functions outside of the exposed schemas can't be selected, reached through a foreign key, called or suggested
+func TestResolveExposure(t *testing.T) {
+	var db = loadCatalog(t)
+
+	for _, c := range []struct {
+		source   string
+		expected string
+	}{
+		{`orders { id, user_id }`, ""},
+		{`internal.users { id, password }`, "unknown relation internal.users"},
+		{`internal.user { id }`, "unknown relation internal.user"},
+		{`users { id }`, "unknown relation users"},
+		{`orders { id, user { id } }`, "unknown relation user from orders"},
+		{`orders { id, users { id } }`, "unknown relation users from orders"},
+		{`orders { id, orders_user_id_fkey { id } }`, "unknown relation orders_user_id_fkey from orders, did you mean orders_customer_id_fkey?"},
+		{`orders { id, audits { id } }`, "unknown relation audits from orders"},
+		{`orders { id, s: internal.secret() }`, "unknown function internal.secret"},
+		{`orders { id, s: internal.secre() }`, "unknown function internal.secre"},
+		{`orders { id, s: secre() }`, "unknown function secre"},
+		{`orders { id, t: api.order_totl(id) }`, "unknown function api.order_totl, did you mean order_total?"},
+	} {
+		checkResolve(t, db, c.source, c.expected)
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright 2025 Christophe Eymard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package relqlpg
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Levenshtein distance, counted in runes
+func editDistance(a string, b string) int {
+	var ra, rb = []rune(a), []rune(b)
+	var prev = make([]int, len(rb)+1)
+	var cur = make([]int, len(rb)+1)
+
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			var cost = 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+
+	return prev[len(rb)]
+}
+
+// The candidate closest to name, if it is close enough to be a typo, or an empty string.
+// Case differences count as a single edit since they are a frequent mistake with quoted identifiers.
+func suggest(name string, candidates []string) string {
+	var best = ""
+	var best_distance = max(2, len([]rune(name))/3) + 1
+
+	for _, c := range candidates {
+		// The name itself is no suggestion, which happens when it exists elsewhere than where it was looked up
+		if c == name {
+			continue
+		}
+		var d = editDistance(name, c)
+		if d > 0 && strings.EqualFold(name, c) {
+			d = 1
+		}
+		if d < best_distance {
+			best, best_distance = c, d
+		}
+	}
+	return best
+}
+
+// A message ending with a suggestion, if there is one
+func didYouMean(message string, name string, candidates []string) string {
+	if s := suggest(name, candidates); s != "" {
+		return fmt.Sprintf("%s, did you mean %s?", message, s)
+	}
+	return message
+}
@@ -0,0 +1,69 @@
+// Copyright 2025 Christophe Eymard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package relqlpg
+
+import "testing"
+
+func TestEditDistance(t *testing.T) {
+	for _, c := range []struct {
+		a, b     string
+		distance int
+	}{
+		{"", "", 0},
+		{"abc", "", 3},
+		{"", "abc", 3},
+		{"orders", "orders", 0},
+		{"ordrs", "orders", 1},
+		{"customer", "custmoer", 2},
+		{"kitten", "sitting", 3},
+		{"été", "ete", 2},
+	} {
+		if d := editDistance(c.a, c.b); d != c.distance {
+			t.Errorf("editDistance(%q, %q) = %d, expected %d", c.a, c.b, d, c.distance)
+		}
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	for _, c := range []struct {
+		name       string
+		candidates []string
+		expected   string
+	}{
+		{"ordrs", []string{"customers", "orders"}, "orders"},
+		{"Orders", []string{"orders"}, "orders"},
+		{"ORDERS", []string{"orders", "order"}, "orders"},
+		{"orders", []string{"orders"}, ""},
+		{"orders", []string{"orders", "order"}, "order"},
+		// Short names allow two edits, longer ones a third of their length
+		{"ab", []string{"xy"}, "xy"},
+		{"ab", []string{"xyz"}, ""},
+		{"quantit", []string{"quantity"}, "quantity"},
+		{"customer", []string{"costumes"}, ""},
+		{"line_numbers", []string{"lines_number"}, "lines_number"},
+		{"id", nil, ""},
+	} {
+		if s := suggest(c.name, c.candidates); s != c.expected {
+			t.Errorf("suggest(%q, %v) = %q, expected %q", c.name, c.candidates, s, c.expected)
+		}
+	}
+
+	if m := didYouMean("unknown column ordrs", "ordrs", []string{"orders"}); m != "unknown column ordrs, did you mean orders?" {
+		t.Errorf("unexpected message %q", m)
+	}
+	if m := didYouMean("unknown column zzz", "zzz", []string{"orders"}); m != "unknown column zzz" {
+		t.Errorf("unexpected message %q", m)
+	}
+}
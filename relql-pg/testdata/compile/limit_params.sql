@@ -0,0 +1,27 @@
+SELECT coalesce(json_agg(r0."row" ORDER BY r0."order1"), '[]'::json) AS "json" FROM (
+	SELECT
+		(SELECT e0 FROM (SELECT
+			t0."id" AS "id",
+			j1."json" AS "lines"
+		) AS e0) AS "row",
+		t0."id" AS "order1"
+	FROM "api"."orders" AS t0
+	LEFT JOIN LATERAL (
+		SELECT coalesce(json_agg(r1."row" ORDER BY r1."order1"), '[]'::json) AS "json" FROM (
+			SELECT
+				(SELECT e1 FROM (SELECT
+					t1."id" AS "id"
+				) AS e1) AS "row",
+				t1."id" AS "order1"
+			FROM "api"."lines" AS t1
+			WHERE t1."order_id" = t0."id"
+			ORDER BY "order1"
+			LIMIT $1::"pg_catalog"."int8"
+		) AS r1
+	) AS j1 ON true
+	ORDER BY "order1"
+	LIMIT $2::"pg_catalog"."int8"
+	OFFSET $1::"pg_catalog"."int8"
+) AS r0
+-- $1 = int64(3)
+-- $2 = int64(7)
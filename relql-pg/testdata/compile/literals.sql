@@ -0,0 +1,18 @@
+SELECT coalesce(json_agg(r0.*), '[]'::json) AS "json" FROM (
+	SELECT
+		t0."id" AS "id",
+		$1::"pg_catalog"."int4" AS "small",
+		$2::"pg_catalog"."int8" AS "big",
+		$3::"pg_catalog"."numeric" AS "ratio",
+		$4 AS "label",
+		$5::"pg_catalog"."bool" AS "flag",
+		"api"."order_total"(t0."id") AS "total"
+	FROM "api"."orders" AS t0
+	WHERE (t0."quantity" > $6::"pg_catalog"."int4")
+) AS r0
+-- $1 = string(1)
+-- $2 = string(5000000000)
+-- $3 = string(1.5)
+-- $4 = string(x)
+-- $5 = string(true)
+-- $6 = string(2)
@@ -0,0 +1,9 @@
+SELECT coalesce(json_agg(r0.*), '[]'::json) AS "json" FROM (
+	SELECT
+		t0."id" AS "id"
+	FROM "api"."orders" AS t0
+	WHERE (((t0."customer_id" = $1::"pg_catalog"."int4") OR (t0."id" = $1::"pg_catalog"."int4")) OR ((t0."quantity" >= $2::"pg_catalog"."int4") AND (t0."quantity" < ($2::"pg_catalog"."int4" + $3::"pg_catalog"."int4"))))
+) AS r0
+-- $1 = int64(7)
+-- $2 = int64(2)
+-- $3 = string(10)
@@ -0,0 +1,24 @@
+SELECT coalesce(json_agg(r0.*), '[]'::json) AS "json" FROM (
+	SELECT
+		t0."id" AS "r0",
+		t0."quantity" AS "r1",
+		j1."json" AS "customer",
+		j2."json" AS "lines"
+	FROM "api"."orders" AS t0
+	LEFT JOIN LATERAL (
+		SELECT row_to_json(r1.*) AS "json" FROM (
+			SELECT
+				t1."name" AS "r1"
+			FROM "api"."customers" AS t1
+			WHERE t1."id" = t0."customer_id"
+		) AS r1
+	) AS j1 ON true
+	LEFT JOIN LATERAL (
+		SELECT coalesce(json_agg(r2.*), '[]'::json) AS "json" FROM (
+			SELECT
+				t2."product" AS "r2"
+			FROM "api"."lines" AS t2
+			WHERE t2."order_id" = t0."id"
+		) AS r2
+	) AS j2 ON true
+) AS r0
@@ -0,0 +1,27 @@
+SELECT coalesce(json_agg(r0.*), '[]'::json) AS "json" FROM (
+	SELECT
+		t0."id" AS "id",
+		j1."json" AS "orders"
+	FROM "api"."customers" AS t0
+	LEFT JOIN LATERAL (
+		SELECT coalesce(json_agg(r1.*), '[]'::json) AS "json" FROM (
+			SELECT
+				t1."id" AS "id",
+				j2."json" AS "lines"
+			FROM "api"."orders" AS t1
+			LEFT JOIN LATERAL (
+				SELECT coalesce(json_agg(r2."row" ORDER BY r2."order1"), '[]'::json) AS "json" FROM (
+					SELECT
+						(SELECT e2 FROM (SELECT
+							t2."product" AS "product"
+						) AS e2) AS "row",
+						t2."position" AS "order1"
+					FROM "api"."lines" AS t2
+					WHERE t2."order_id" = t1."id"
+					ORDER BY "order1"
+				) AS r2
+			) AS j2 ON true
+			WHERE t1."customer_id" = t0."id"
+		) AS r1
+	) AS j1 ON true
+) AS r0
@@ -0,0 +1,14 @@
+SELECT coalesce(json_agg(r0.*), '[]'::json) AS "json" FROM (
+	SELECT
+		t0."id" AS "id",
+		j1."json" AS "customer"
+	FROM "api"."orders" AS t0
+	LEFT JOIN LATERAL (
+		SELECT row_to_json(r1.*) AS "json" FROM (
+			SELECT
+				t1."name" AS "name"
+			FROM "api"."customers" AS t1
+			WHERE t1."id" = t0."customer_id"
+		) AS r1
+	) AS j1 ON true
+) AS r0
@@ -0,0 +1,14 @@
+SELECT coalesce(json_agg(r0.*), '[]'::json) AS "json" FROM (
+	SELECT
+		t0."id" AS "id",
+		j1."json" AS "profile"
+	FROM "api"."customers" AS t0
+	LEFT JOIN LATERAL (
+		SELECT row_to_json(r1.*) AS "json" FROM (
+			SELECT
+				t1."bio" AS "bio"
+			FROM "api"."profiles" AS t1
+			WHERE t1."customer_id" = t0."id"
+		) AS r1
+	) AS j1 ON true
+) AS r0
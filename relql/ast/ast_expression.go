@@ -1,10 +1,193 @@
 package ast
 
+import (
+	"github.com/ceymard/pgrel/pg"
+)
+
+// Where a node comes from in the source
+type Span struct {
+	Pos    int
+	End    int
+	Line   int
+	Column int
+}
+
+func (s Span) GetSpan() Span {
+	return s
+}
+
 type IAstExpression interface {
+	GetSpan() Span
 }
 
 type AstBinaryExpression struct {
+	Span
 	Left     IAstExpression
 	Right    IAstExpression
+	Operator string // and, or and the other keywords are lower case
+}
+
+// NOT, - and the other prefix operators
+type AstUnaryExpression struct {
+	Span
 	Operator string
+	Operand  IAstExpression
+}
+
+// An unqualified name, which may be a column, a relation or a function
+type AstIdentifier struct {
+	Span
+	Name   string // Folded to lower case unless it was quoted
+	Quoted bool
+
+	ResolvedColumn   *pg.Column
+	ResolvedRelation *AstRelation // The relation of the query the column is taken from, which may be a parent of the current one
+}
+
+// expr.member, or expr.* when Member is *
+type AstMemberExpression struct {
+	Span
+	Expression IAstExpression
+	Member     string
+
+	// Set when this is relation.column
+	ResolvedColumn   *pg.Column
+	ResolvedRelation *AstRelation
+}
+
+type AstNumber struct {
+	Span
+	Value string // As written, which may not fit in an int64 or a float64
+}
+
+type AstString struct {
+	Span
+	Value string // With quotes and escapes removed
+	IsBit bool   // B'...' or X'...', in which case Value holds the digits
+	IsHex bool
+}
+
+type AstBoolean struct {
+	Span
+	Value bool
+}
+
+type AstNull struct {
+	Span
+}
+
+// * as in count(*)
+type AstStar struct {
+	Span
+}
+
+type AstTypeName struct {
+	Span
+	Schema          string
+	Name            string           // Multi-word names like double precision are kept as they are written, with single spaces
+	Modifiers       []IAstExpression // The (10) of varchar(10)
+	ArrayDimensions int
+
+	ResolvedType *pg.Type
+}
+
+// expr::type, CAST(expr AS type) or type 'literal'
+type AstCastExpression struct {
+	Span
+	Expression IAstExpression
+	Type       *AstTypeName
+}
+
+// expr IS [NOT] NULL, TRUE, FALSE or UNKNOWN, as well as expr ISNULL and expr NOTNULL
+type AstIsExpression struct {
+	Span
+	Expression IAstExpression
+	Not        bool
+	Test       string // null, true, false or unknown
+}
+
+// left IS [NOT] DISTINCT FROM right
+type AstDistinctExpression struct {
+	Span
+	Left  IAstExpression
+	Right IAstExpression
+	Not   bool
+}
+
+// expr [NOT] BETWEEN [SYMMETRIC] low AND high
+type AstBetweenExpression struct {
+	Span
+	Expression IAstExpression
+	Low        IAstExpression
+	High       IAstExpression
+	Not        bool
+	Symmetric  bool
+}
+
+// expr [NOT] IN (list)
+type AstInExpression struct {
+	Span
+	Expression IAstExpression
+	List       []IAstExpression
+	Not        bool
+}
+
+// expr [NOT] LIKE, ILIKE or SIMILAR TO pattern [ESCAPE escape]
+type AstLikeExpression struct {
+	Span
+	Expression IAstExpression
+	Operator   string // like, ilike or similar to
+	Pattern    IAstExpression
+	Escape     IAstExpression
+	Not        bool
+}
+
+// expr[index] or expr[lower:upper]
+type AstSubscriptExpression struct {
+	Span
+	Expression IAstExpression
+	Index      IAstExpression // The lower bound of a slice, nil if omitted
+	Upper      IAstExpression
+	IsSlice    bool
+}
+
+type AstFunctionCall struct {
+	Span
+	Function  IAstExpression // An AstIdentifier or an AstMemberExpression for schema.function
+	Arguments []IAstExpression
+	Distinct  bool
+	Star      bool // count(*)
+
+	ResolvedFunction *pg.Function
+}
+
+// name => value in the arguments of a function call
+type AstNamedArgument struct {
+	Span
+	Name  string
+	Value IAstExpression
+}
+
+type AstCaseExpression struct {
+	Span
+	Subject IAstExpression // CASE subject WHEN value ..., nil for CASE WHEN condition ...
+	Whens   []*AstWhen
+	Else    IAstExpression
+}
+
+type AstWhen struct {
+	Condition IAstExpression
+	Result    IAstExpression
+}
+
+// ARRAY[...]
+type AstArrayExpression struct {
+	Span
+	Elements []IAstExpression
+}
+
+// (a, b) or ROW(a, b)
+type AstRowExpression struct {
+	Span
+	Elements []IAstExpression
 }
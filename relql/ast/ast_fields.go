@@ -1,9 +1,30 @@
 package ast
 
+import (
+	"github.com/ceymard/pgrel/pg"
+)
+
 type IAstField interface {
+	GetSpan() Span
 }
 
+// A column, possibly renamed with alias: column
 type AstField struct {
+	Span
 	Id    *AstSqlIdentifier
 	Alias string
+
+	ResolvedColumn *pg.Column
+}
+
+// An expression in the fields, like total: price * quantity
+type AstComputedField struct {
+	Span
+	Alias      string // May be empty if the expression was not given a name
+	Expression IAstExpression
+}
+
+// * selects all the columns
+type AstWildcard struct {
+	Span
 }
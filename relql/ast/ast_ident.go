@@ -14,5 +14,16 @@
 
 package ast
 
+// A possibly schema qualified name, like api.orders
 type AstSqlIdentifier struct {
+	Span
+	Schema string
+	Name   string
+}
+
+func (id *AstSqlIdentifier) String() string {
+	if id.Schema == "" {
+		return id.Name
+	}
+	return id.Schema + "." + id.Name
 }
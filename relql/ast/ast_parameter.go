@@ -0,0 +1,36 @@
+// Copyright 2025 Christophe Eymard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ast
+
+import (
+	"github.com/ceymard/pgrel/pg"
+)
+
+// $1, a parameter given by its position when running the query
+type AstPositionalParameter struct {
+	Span
+	Index int // From 1
+
+	// The type of what the parameter is compared to or assigned to, nil until resolved or when it can't be told
+	ResolvedType *pg.Type
+}
+
+// :name, a parameter given by name when running the query
+type AstNamedParameter struct {
+	Span
+	Name string
+
+	ResolvedType *pg.Type
+}
@@ -18,17 +18,31 @@ import (
 	"github.com/ceymard/pgrel/pg"
 )
 
+// A relation and the fields to select from it, at the top of a query or nested in another relation,
+// in which case Id is how to get to it from its parent.
 type AstRelation struct {
+	Span
 	Id    *AstSqlIdentifier
 	Alias string
 
 	Fields []IAstField
 
-	Order  []IAstExpression
-	Limit  int
-	Offset int
+	Where  IAstExpression
+	Order  []IAstExpression // *AstOrderExpression
+	Limit  IAstExpression   // An *AstNumber or a parameter, nil when there is no limit
+	Offset IAstExpression
 
 	// Fields []IAstField
 
-	ResolvedRelation *pg.Relation
+	ResolvedRelation     *pg.Relation
+	ResolvedRelationship *AstRelationship // How a nested relation is joined to its parent, nil for the top one
+}
+
+// An expression of ORDER BY
+type AstOrderExpression struct {
+	Span
+	Expression IAstExpression
+	Desc       bool
+	NullsFirst bool
+	NullsLast  bool
 }
@@ -13,3 +13,26 @@
 // limitations under the License.
 
 package ast
+
+import (
+	"github.com/ceymard/pgrel/pg"
+)
+
+// The foreign key that links a nested relation to its parent. Only one of Outgoing and Incoming is set.
+type AstRelationship struct {
+	Outgoing *pg.OutgoingForeignKey // The parent references the nested relation
+	Incoming *pg.IncomingForeignKey // The nested relation references the parent
+}
+
+// Tell if there is at most one row of the nested relation for each row of its parent
+func (r *AstRelationship) IsToOne() bool {
+	return r.Outgoing != nil || r.Incoming != nil && r.Incoming.OtherIsUnique
+}
+
+// The foreign key constraint behind the relationship
+func (r *AstRelationship) ForeignKey() *pg.ForeignKey {
+	if r.Outgoing != nil {
+		return r.Outgoing.ForeignKey
+	}
+	return r.Incoming.ForeignKey
+}
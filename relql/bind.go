@@ -0,0 +1,259 @@
+// Copyright 2025 Christophe Eymard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package relql
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/ceymard/pgrel/pg"
+	"github.com/ceymard/pgrel/relql/ast"
+	"github.com/jackc/pgx/v5/pgtype"
+	"gitlab.com/tozd/go/errors"
+)
+
+// The placeholders of a statement. They are either literals of the query, which never end up in the SQL text, or the
+// $1 and :name parameters it was written with, which get their values when running it.
+type Params struct {
+	slots      []*paramSlot
+	positional map[int]*paramSlot
+	named      map[string]*paramSlot
+}
+
+type paramSlot struct {
+	Placeholder int // $N in the statement
+	Index       int // For $1 parameters
+	Name        string
+	Type        *pg.Type
+
+	IsLiteral bool
+	Value     any
+}
+
+func (s *paramSlot) String() string {
+	if s.Name != "" {
+		return ":" + s.Name
+	}
+	return "$" + strconv.Itoa(s.Index)
+}
+
+func NewParams() *Params {
+	return &Params{
+		positional: make(map[int]*paramSlot),
+		named:      make(map[string]*paramSlot),
+	}
+}
+
+func (p *Params) add(slot *paramSlot) string {
+	p.slots = append(p.slots, slot)
+	slot.Placeholder = len(p.slots)
+	return "$" + strconv.Itoa(slot.Placeholder)
+}
+
+// Add a literal of the query and return its placeholder
+func (p *Params) Literal(value any, typ *pg.Type) string {
+	return p.add(&paramSlot{IsLiteral: true, Value: value, Type: typ})
+}
+
+// Return the placeholder of a parameter node of the AST. A parameter that appears several times
+// has the same placeholder, with the first type that could be inferred for it.
+func (p *Params) Param(node any) (string, error) {
+	var slot *paramSlot
+	var typ *pg.Type
+
+	switch n := node.(type) {
+	case *ast.AstPositionalParameter:
+		if slot = p.positional[n.Index]; slot == nil {
+			slot = &paramSlot{Index: n.Index}
+			p.positional[n.Index] = slot
+			p.add(slot)
+		}
+		typ = n.ResolvedType
+	case *ast.AstNamedParameter:
+		if slot = p.named[n.Name]; slot == nil {
+			slot = &paramSlot{Name: n.Name}
+			p.named[n.Name] = slot
+			p.add(slot)
+		}
+		typ = n.ResolvedType
+	default:
+		return "", errors.Errorf("not a parameter: %T", node)
+	}
+
+	if slot.Type == nil {
+		slot.Type = typ
+	}
+	return "$" + strconv.Itoa(slot.Placeholder), nil
+}
+
+// The number of placeholders
+func (p *Params) Len() int {
+	return len(p.slots)
+}
+
+// The names of the :name parameters, in the order they appear in the query
+func (p *Params) Names() []string {
+	var res []string
+	for _, s := range p.slots {
+		if s.Name != "" {
+			res = append(res, s.Name)
+		}
+	}
+	return res
+}
+
+// Give the arguments of the statement, in the order of the placeholders. positional[0] is the value of $1.
+// Strings are converted to the type that was inferred for their parameter, other values are given as is to pgx.
+func (p *Params) Bind(positional []any, named map[string]any) ([]any, error) {
+	return p.bind(func(s *paramSlot) (any, bool) {
+		if s.Name != "" {
+			v, ok := named[s.Name]
+			return v, ok
+		}
+		if s.Index < 1 || s.Index > len(positional) {
+			return nil, false
+		}
+		return positional[s.Index-1], true
+	})
+}
+
+// Same as Bind, with values coming from a query string. $1 is looked up as 1. Parameters that are arrays
+// take all the values given for their key, the others only take the first one.
+func (p *Params) BindValues(values url.Values) ([]any, error) {
+	return p.bind(func(s *paramSlot) (any, bool) {
+		var key = s.Name
+		if key == "" {
+			key = strconv.Itoa(s.Index)
+		}
+		vals, ok := values[key]
+		if !ok || len(vals) == 0 {
+			return nil, false
+		}
+		if s.Type.UnderlyingType().IsArray() {
+			return vals, true
+		}
+		return vals[0], true
+	})
+}
+
+func (p *Params) bind(lookup func(s *paramSlot) (any, bool)) ([]any, error) {
+	var args = make([]any, len(p.slots))
+	var errs []error
+
+	for i, s := range p.slots {
+		if s.IsLiteral {
+			args[i] = s.Value
+			continue
+		}
+
+		value, ok := lookup(s)
+		if !ok {
+			errs = append(errs, errors.Errorf("missing value for parameter %s", s.String()))
+			continue
+		}
+
+		converted, err := convertValue(value, s.Type)
+		if err != nil {
+			errs = append(errs, errors.Errorf("parameter %s: %w", s.String(), err))
+			continue
+		}
+		args[i] = converted
+	}
+
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return args, nil
+}
+
+// Convert strings to what pgx expects for the type. Domains are converted as their underlying type.
+func convertValue(value any, typ *pg.Type) (any, error) {
+	var base = typ.UnderlyingType()
+	if base == nil {
+		return value, nil
+	}
+
+	switch v := value.(type) {
+	case string:
+		return parseText(v, base)
+	case []string:
+		if !base.IsArray() {
+			return nil, errors.Errorf("expected a single value of type %s", base.PgIdentifier.Name)
+		}
+		var res = make([]any, len(v))
+		for i, s := range v {
+			elt, err := parseText(s, base.ElementType.UnderlyingType())
+			if err != nil {
+				return nil, err
+			}
+			res[i] = elt
+		}
+		return res, nil
+	}
+	return value, nil
+}
+
+func parseText(s string, typ *pg.Type) (any, error) {
+	if typ.IsEnum() {
+		for _, label := range typ.EnumLabels {
+			if label == s {
+				return s, nil
+			}
+		}
+		return nil, errors.Errorf("invalid value %q for enum %s, expected one of %s", s, typ.PgIdentifier.Name, strings.Join(typ.EnumLabels, ", "))
+	}
+
+	if typ.PgIdentifier.Schema != "pg_catalog" {
+		return s, nil
+	}
+
+	var invalid = func(err error) error {
+		return errors.Errorf("invalid value %q for type %s: %w", s, typ.PgIdentifier.Name, err)
+	}
+
+	switch typ.PgIdentifier.Name {
+	case "bool":
+		switch strings.ToLower(strings.TrimSpace(s)) {
+		case "t", "true", "y", "yes", "on", "1":
+			return true, nil
+		case "f", "false", "n", "no", "off", "0":
+			return false, nil
+		}
+		return nil, invalid(errors.New("not a boolean"))
+	case "int2", "int4", "int8":
+		var bits = map[string]int{"int2": 16, "int4": 32, "int8": 64}[typ.PgIdentifier.Name]
+		n, err := strconv.ParseInt(strings.TrimSpace(s), 10, bits)
+		if err != nil {
+			return nil, invalid(err)
+		}
+		return n, nil
+	case "float4", "float8":
+		f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+		if err != nil {
+			return nil, invalid(err)
+		}
+		return f, nil
+	case "numeric":
+		var n pgtype.Numeric
+		if err := n.Scan(strings.TrimSpace(s)); err != nil {
+			return nil, invalid(err)
+		}
+		return n, nil
+	}
+
+	// Everything else is sent as text and parsed by postgres
+	return s, nil
+}
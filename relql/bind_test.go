@@ -0,0 +1,217 @@
+// Copyright 2025 Christophe Eymard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package relql
+
+import (
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ceymard/pgrel/pg"
+	"github.com/ceymard/pgrel/relql/ast"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func builtinType(name string) *pg.Type {
+	return &pg.Type{PgIdentifier: pg.SqlIdentifier{Schema: "pg_catalog", Name: name}, PgKind: pg.KIND_BASE}
+}
+
+func arrayOf(element *pg.Type) *pg.Type {
+	var array = &pg.Type{PgIdentifier: pg.SqlIdentifier{Schema: "pg_catalog", Name: "_" + element.PgIdentifier.Name}, PgKind: pg.KIND_BASE, ElementType: element}
+	element.ArrayType = array
+	return array
+}
+
+var (
+	type_bool    = builtinType("bool")
+	type_int2    = builtinType("int2")
+	type_int4    = builtinType("int4")
+	type_int8    = builtinType("int8")
+	type_float8  = builtinType("float8")
+	type_numeric = builtinType("numeric")
+	type_text    = builtinType("text")
+	type_status  = &pg.Type{PgIdentifier: pg.SqlIdentifier{Schema: "api", Name: "status"}, PgKind: pg.KIND_ENUM, EnumLabels: []string{"draft", "sent"}}
+	type_amount  = &pg.Type{PgIdentifier: pg.SqlIdentifier{Schema: "api", Name: "amount"}, PgKind: pg.KIND_DOMAIN, BaseType: type_int4}
+	type_int4s   = arrayOf(type_int4)
+)
+
+func TestConvertValue(t *testing.T) {
+	var tests = []struct {
+		value    any
+		typ      *pg.Type
+		expected any
+		err      string
+	}{
+		{"t", type_bool, true, ""},
+		{"Yes", type_bool, true, ""},
+		{" on ", type_bool, true, ""},
+		{"1", type_bool, true, ""},
+		{"f", type_bool, false, ""},
+		{"NO", type_bool, false, ""},
+		{"off", type_bool, false, ""},
+		{"0", type_bool, false, ""},
+		{"maybe", type_bool, nil, "not a boolean"},
+
+		{"32767", type_int2, int64(32767), ""},
+		{"-32768", type_int2, int64(-32768), ""},
+		{"32768", type_int2, nil, "invalid value"},
+		{"2147483647", type_int4, int64(2147483647), ""},
+		{"2147483648", type_int4, nil, "invalid value"},
+		{"9223372036854775807", type_int8, int64(9223372036854775807), ""},
+		{"9223372036854775808", type_int8, nil, "invalid value"},
+		{"1.5", type_int4, nil, "invalid value"},
+
+		{"1.5", type_float8, 1.5, ""},
+		{"abc", type_float8, nil, "invalid value"},
+		{"10.25", type_numeric, pgtype.Numeric{}, ""},
+
+		{"draft", type_status, "draft", ""},
+		{"archived", type_status, nil, "expected one of draft, sent"},
+
+		// Domains are converted as their base type
+		{"12", type_amount, int64(12), ""},
+		{"x", type_amount, nil, "invalid value"},
+
+		{"anything", type_text, "anything", ""},
+		{"anything", nil, "anything", ""},
+		// Values that are not strings are given to pgx as they are
+		{42, type_int2, 42, ""},
+
+		{[]string{"1", "2"}, type_int4s, []any{int64(1), int64(2)}, ""},
+		{[]string{"1", "x"}, type_int4s, nil, "invalid value"},
+		{[]string{"1", "2"}, type_int4, nil, "expected a single value"},
+	}
+
+	for _, test := range tests {
+		got, err := convertValue(test.value, test.typ)
+		var name = "untyped"
+		if test.typ != nil {
+			name = test.typ.PgIdentifier.Name
+		}
+
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%v as %s: expected an error containing %q, got %v, %v", test.value, name, test.err, got, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v as %s: unexpected error %s", test.value, name, err)
+			continue
+		}
+
+		if _, ok := test.expected.(pgtype.Numeric); ok {
+			if n, ok := got.(pgtype.Numeric); !ok || !n.Valid {
+				t.Errorf("%v as %s: expected a valid numeric, got %#v", test.value, name, got)
+			}
+			continue
+		}
+		if !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("%v as %s: got %#v, expected %#v", test.value, name, got, test.expected)
+		}
+	}
+}
+
+// $2, :id, $1 and :id again, giving placeholders $1 to $3 after a literal
+func testParams(t *testing.T) *Params {
+	var p = NewParams()
+	if placeholder := p.Literal("lit", type_text); placeholder != "$1" {
+		t.Fatalf("the literal should be $1, got %s", placeholder)
+	}
+
+	for _, test := range []struct {
+		node        any
+		placeholder string
+	}{
+		{&ast.AstPositionalParameter{Index: 2, ResolvedType: type_int4}, "$2"},
+		{&ast.AstNamedParameter{Name: "id", ResolvedType: type_int8}, "$3"},
+		{&ast.AstPositionalParameter{Index: 1, ResolvedType: type_bool}, "$4"},
+		{&ast.AstNamedParameter{Name: "id"}, "$3"},
+		{&ast.AstPositionalParameter{Index: 2}, "$2"},
+	} {
+		placeholder, err := p.Param(test.node)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if placeholder != test.placeholder {
+			t.Fatalf("%#v: got placeholder %s, expected %s", test.node, placeholder, test.placeholder)
+		}
+	}
+	return p
+}
+
+func TestBind(t *testing.T) {
+	var p = testParams(t)
+
+	if p.Len() != 4 {
+		t.Errorf("expected 4 placeholders, got %d", p.Len())
+	}
+	if names := p.Names(); !reflect.DeepEqual(names, []string{"id"}) {
+		t.Errorf("expected the names [id], got %v", names)
+	}
+
+	args, err := p.Bind([]any{"true", "7"}, map[string]any{"id": "12"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []any{"lit", int64(7), int64(12), true}; !reflect.DeepEqual(args, expected) {
+		t.Errorf("got %#v, expected %#v", args, expected)
+	}
+
+	_, err = p.Bind([]any{"true"}, nil)
+	if err == nil {
+		t.Fatal("expected errors for the missing parameters")
+	}
+	for _, missing := range []string{"missing value for parameter $2", "missing value for parameter :id"} {
+		if !strings.Contains(err.Error(), missing) {
+			t.Errorf("expected %q in %q", missing, err.Error())
+		}
+	}
+
+	_, err = p.Bind([]any{"maybe", "7"}, map[string]any{"id": "12"})
+	if err == nil || !strings.Contains(err.Error(), "parameter $1") {
+		t.Errorf("expected an error for $1, got %v", err)
+	}
+}
+
+func TestBindValues(t *testing.T) {
+	var p = NewParams()
+	p.Param(&ast.AstNamedParameter{Name: "ids", ResolvedType: type_int4s})
+	p.Param(&ast.AstNamedParameter{Name: "status", ResolvedType: type_status})
+	p.Param(&ast.AstPositionalParameter{Index: 1, ResolvedType: type_int2})
+
+	args, err := p.BindValues(url.Values{
+		"ids":    {"1", "2", "3"},
+		"status": {"sent", "draft"}, // Only the first one is taken
+		"1":      {"5"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []any{[]any{int64(1), int64(2), int64(3)}, "sent", int64(5)}; !reflect.DeepEqual(args, expected) {
+		t.Errorf("got %#v, expected %#v", args, expected)
+	}
+
+	_, err = p.BindValues(url.Values{"ids": {}, "status": {"archived"}})
+	if err == nil {
+		t.Fatal("expected errors")
+	}
+	for _, expected := range []string{"missing value for parameter :ids", "invalid value \"archived\"", "missing value for parameter $1"} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected %q in %q", expected, err.Error())
+		}
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright 2025 Christophe Eymard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package relql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ceymard/pgrel/relql/ast"
+	"gitlab.com/tozd/go/errors"
+)
+
+// An error located at a token of the source
+type Diagnostic struct {
+	Token   *Token
+	Message string
+}
+
+func (d *Diagnostic) Error() string {
+	if d.Token == nil {
+		return d.Message
+	}
+	if d.Token.Kind == T_EOF {
+		return fmt.Sprintf("line %d, column %d, at end of input: %s", d.Token.Line, d.Token.Column, d.Message)
+	}
+	if d.Token.Bytes == nil {
+		return fmt.Sprintf("line %d, column %d: %s", d.Token.Line, d.Token.Column, d.Message)
+	}
+	return fmt.Sprintf("line %d, column %d, near %s: %s", d.Token.Line, d.Token.Column, d.Token.String(), d.Message)
+}
+
+// Show the message followed by the line the token is on, with the token underlined.
+//
+//	line 1, column 17, near ,: unexpected token
+//	  |
+//	1 | api.orders { id,, total }
+//	  |                 ^
+func (d *Diagnostic) Render(source []byte) string {
+	var b strings.Builder
+	b.WriteString(d.Error())
+
+	var tk = d.Token
+	if tk == nil || tk.Pos > len(source) {
+		return b.String()
+	}
+
+	var start = tk.Pos
+	for start > 0 && source[start-1] != '\n' {
+		start--
+	}
+	var end = tk.Pos
+	for end < len(source) && source[end] != '\n' {
+		end++
+	}
+	var line = strings.TrimRight(string(source[start:end]), "\r")
+
+	// Tokens that span several lines, like strings, are only underlined up to the end of their first line
+	var width = utf8.RuneCount(source[tk.Pos:min(tk.End, end)])
+	if width == 0 {
+		width = 1
+	}
+
+	var gutter = strings.Repeat(" ", len(strconv.Itoa(tk.Line)))
+	fmt.Fprintf(&b, "\n%s |\n%d | %s\n%s | ", gutter, tk.Line, line, gutter)
+
+	// Keep the tabs so that the caret lines up whatever their width
+	for _, r := range string(source[start:tk.Pos]) {
+		if r == '\t' {
+			b.WriteByte('\t')
+		} else {
+			b.WriteByte(' ')
+		}
+	}
+	b.WriteString(strings.Repeat("^", width))
+
+	return b.String()
+}
+
+// Render err with its source line if it comes from a token, or just return its message.
+// Errors that were joined together are rendered one after the other.
+func RenderError(source []byte, err error) string {
+	var joined interface{ Unwrap() []error }
+	if errors.As(err, &joined) {
+		var parts []string
+		for _, e := range joined.Unwrap() {
+			parts = append(parts, RenderError(source, e))
+		}
+		return strings.Join(parts, "\n\n")
+	}
+
+	var diag *Diagnostic
+	if errors.As(err, &diag) {
+		return diag.Render(source)
+	}
+	return err.Error()
+}
+
+// Make an error that points at a node of the AST, for the errors that are found after parsing.
+func NodeError(node interface{ GetSpan() ast.Span }, message string) error {
+	var span = node.GetSpan()
+	return errors.WithStack(&Diagnostic{
+		Token:   &Token{Kind: T_INVALID, Pos: span.Pos, End: span.End, Line: span.Line, Column: span.Column},
+		Message: message,
+	})
+}
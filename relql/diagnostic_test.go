@@ -0,0 +1,159 @@
+// Copyright 2025 Christophe Eymard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package relql
+
+import (
+	"fmt"
+	"testing"
+
+	"gitlab.com/tozd/go/errors"
+)
+
+// The position of every token, as line:column
+func TestTokenPositions(t *testing.T) {
+	var tests = []struct {
+		source    string
+		positions string
+	}{
+		{"a b", "[1:1 1:3 1:4]"},
+		{"a\nb\n\n  c", "[1:1 2:1 4:3 4:4]"},
+		{"a\r\nb", "[1:1 2:1 2:2]"},
+		{"é = ü", "[1:1 1:3 1:5 1:6]"},
+		{"'日本' x", "[1:1 1:6 1:7]"},
+		{"\ta", "[1:2 1:3]"},
+		{"'a\nb' c", "[1:1 2:4 2:5]"},
+	}
+
+	for _, test := range tests {
+		var lexer = NewLexer([]byte(test.source))
+		var positions []string
+		for {
+			var tk = lexer.Next()
+			positions = append(positions, fmt.Sprintf("%d:%d", tk.Line, tk.Column))
+			if tk.IsEOF() || tk.IsIllegal() {
+				break
+			}
+		}
+		if got := fmt.Sprint(positions); got != test.positions {
+			t.Errorf("%q: got %s, expected %s", test.source, got, test.positions)
+		}
+	}
+}
+
+// The token at index n of source, or the end of input if n is past the last token
+func tokenAt(source string, n int) *Token {
+	var lexer = NewLexer([]byte(source))
+	for i := 0; ; i++ {
+		var tk = lexer.Next()
+		if i == n || tk.IsEOF() {
+			return tk
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	var tests = []struct {
+		source   string
+		token    int
+		expected string
+	}{
+		{
+			"api.orders { id,, total }", 6,
+			"line 1, column 17, near ,: message\n" +
+				"  |\n" +
+				"1 | api.orders { id,, total }\n" +
+				"  |                 ^",
+		},
+		{
+			"orders {\n\tid,\n\ttotal +\n}", 6,
+			"line 4, column 1, near }: message\n" +
+				"  |\n" +
+				"4 | }\n" +
+				"  | ^",
+		},
+		{
+			// Runes are counted as one column, whatever their size
+			"orders { é, 'ü' 'x' }", 5,
+			"line 1, column 17, near 'x': message\n" +
+				"  |\n" +
+				"1 | orders { é, 'ü' 'x' }\n" +
+				"  |                 ^^^",
+		},
+		{
+			// Tabs are kept so that the caret lines up with the token
+			"orders {\n\t\tid\tname }", 3,
+			"line 2, column 6, near name: message\n" +
+				"  |\n" +
+				"2 | \t\tid\tname }\n" +
+				"  | \t\t  \t^^^^",
+		},
+		{
+			// A token that spans lines is underlined up to the end of its first line
+			"a = 1 'a\nbc'", 3,
+			"line 1, column 7, near 'a\nbc': message\n" +
+				"  |\n" +
+				"1 | a = 1 'a\n" +
+				"  |       ^^",
+		},
+		{
+			"where 'a\nb' x", 2,
+			"line 2, column 4, near x: message\n" +
+				"  |\n" +
+				"2 | b' x\n" +
+				"  |    ^",
+		},
+		{
+			"orders where\r\n", 2,
+			"line 2, column 1, at end of input: message\n" +
+				"  |\n" +
+				"2 | \n" +
+				"  | ^",
+		},
+		{
+			"orders { id", 3,
+			"line 1, column 12, at end of input: message\n" +
+				"  |\n" +
+				"1 | orders { id\n" +
+				"  |            ^",
+		},
+		{
+			"\n\n\n\n\n\n\n\n\norders { id } extra", 4,
+			"line 10, column 15, near extra: message\n" +
+				"   |\n" +
+				"10 | orders { id } extra\n" +
+				"   |               ^^^^^",
+		},
+	}
+
+	for _, test := range tests {
+		var err = tokenAt(test.source, test.token).ErrorMessage("message")
+		if got := RenderError([]byte(test.source), err); got != test.expected {
+			t.Errorf("%q: got\n%s\nexpected\n%s", test.source, got, test.expected)
+		}
+	}
+}
+
+func TestRenderJoinedErrors(t *testing.T) {
+	var source = []byte("a\nbb")
+	var first = &Diagnostic{Token: &Token{Kind: T_IDENT, Pos: 0, End: 1, Line: 1, Column: 1, Bytes: source[0:1]}, Message: "first"}
+	var second = &Diagnostic{Token: &Token{Kind: T_IDENT, Pos: 2, End: 4, Line: 2, Column: 1, Bytes: source[2:4]}, Message: "second"}
+
+	var expected = "line 1, column 1, near a: first\n  |\n1 | a\n  | ^\n\n" +
+		"line 2, column 1, near bb: second\n  |\n2 | bb\n  | ^^\n\n" +
+		"plain"
+	if got := RenderError(source, errors.Join(first, second, errors.New("plain"))); got != expected {
+		t.Errorf("got\n%s\nexpected\n%s", got, expected)
+	}
+}
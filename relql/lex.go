@@ -15,6 +15,7 @@
 package relql
 
 import (
+	"bytes"
 	"fmt"
 	"strings"
 	"unicode"
@@ -45,6 +46,7 @@ const (
 	T_COMMA
 	T_COLON
 	T_SEMICOLON
+	T_PARAM // $1 or :name
 
 	// operators
 	T_OPERATOR
@@ -53,6 +55,8 @@ const (
 )
 
 var token_names = map[TokenType]string{
+	T_ILLEGAL:   "Illegal",
+	T_EOF:       "EOF",
 	T_IDENT:     "Ident",
 	T_STRING:    "String",
 	T_NUMBER:    "Number",
@@ -67,6 +71,7 @@ var token_names = map[TokenType]string{
 	T_OPERATOR:  "Operator",
 	T_INVALID:   "Invalid",
 	T_SEMICOLON: "Semicolon",
+	T_PARAM:     "Param",
 }
 
 // TokenDef represents an operator token definition
@@ -94,6 +99,11 @@ type Token struct {
 
 	// The position of the token in the lexer's buffer
 	Pos int
+	End int // The offset right after the token
+
+	// Where the token starts, both 1-based. Columns are counted in runes, not bytes.
+	Line   int
+	Column int
 
 	//
 	Bytes []byte
@@ -129,11 +139,9 @@ func (t *Token) Error() error {
 	return fmt.Errorf("unexpected token: %s (%s)", t.String(), t.Name())
 }
 
+// Make an error that points at the token. It is a *Diagnostic, which can be rendered along with the source with RenderError.
 func (t *Token) ErrorMessage(message string) error {
-	if t == nil {
-		return errors.Errorf("at position %d: %s", -1, message)
-	}
-	return errors.Errorf("at position %d '%s' (%s): %s", t.Pos, t.String(), t.Name(), message)
+	return errors.WithStack(&Diagnostic{Token: t, Message: message})
 }
 
 func NewLexer(buf []byte) *Lexer {
@@ -238,7 +246,7 @@ var whitespace = [256]bool{
 	'\t': true,
 	'\n': true,
 	'\r': true,
-	'~':  true,
+	'\f': true,
 }
 
 var single_char_tokens = map[byte]TokenType{
@@ -254,14 +262,54 @@ var single_char_tokens = map[byte]TokenType{
 	';': T_SEMICOLON,
 }
 
-// skipWhitespace skips whitespace in the buffer, returning the index of the first non-whitespace character.
+// skipWhitespace skips whitespace and comments in the buffer, returning the index of the first character that is neither.
+// A block comment that is not closed is not skipped, so that it ends up as an illegal token.
 func skipWhitespace(buf []byte, start int) int {
-	for i := start; i < len(buf); i++ {
-		if !whitespace[buf[i]] {
-			return i
+	var l = len(buf)
+	var i = start
+	for i < l {
+		if whitespace[buf[i]] {
+			i++
+			continue
+		}
+
+		if buf[i] == '-' && i+1 < l && buf[i+1] == '-' {
+			if eol := bytes.IndexByte(buf[i:], '\n'); eol >= 0 {
+				i += eol + 1
+			} else {
+				i = l
+			}
+			continue
+		}
+
+		if buf[i] == '/' && i+1 < l && buf[i+1] == '*' {
+			if end := scanBlockComment(l, buf, i); end != i {
+				i = end
+				continue
+			}
+		}
+
+		return i
+	}
+	return l
+}
+
+// Block comments nest in postgres, unlike in C. Returns pos if the comment is not closed.
+func scanBlockComment(l int, buf []byte, pos int) int {
+	var depth = 0
+	for i := pos; i+1 < l; i++ {
+		if buf[i] == '/' && buf[i+1] == '*' {
+			depth++
+			i++
+		} else if buf[i] == '*' && buf[i+1] == '/' {
+			depth--
+			i++
+			if depth == 0 {
+				return i + 1
+			}
 		}
 	}
-	return len(buf)
+	return pos
 }
 
 func nextToken(buf []byte, last *Token) *Token {
@@ -275,45 +323,59 @@ func nextToken(buf []byte, last *Token) *Token {
 
 	var l = len(buf)
 
-	var last_pos = 0
+	// Lines and columns are counted from the start of the previous token
+	var last_pos, from, line, column = 0, 0, 1, 1
 	if last != nil {
 		last_pos = last.Pos + len(last.Bytes)
+		from, line, column = last.Pos, last.Line, last.Column
 	}
 
-	if last_pos >= l {
-		if last != nil && last.IsEOF() {
-			return last
-		}
+	if last_pos >= l && last != nil && last.IsEOF() {
+		return last
+	}
+
+	var start_pos = last_pos
+	if start_pos < l {
+		start_pos = skipWhitespace(buf, last_pos)
+	}
+	line, column = advancePosition(buf, from, start_pos, line, column)
 
+	var token = func(kind TokenType, end int) *Token {
+		var bytes []byte
+		if kind != T_EOF {
+			bytes = buf[start_pos:end]
+		}
 		return &Token{
-			Kind:  T_EOF,
-			Pos:   last_pos,
-			Bytes: nil,
+			Kind:   kind,
+			Pos:    start_pos,
+			End:    end,
+			Line:   line,
+			Column: column,
+			Bytes:  bytes,
 		}
 	}
 
-	var start_pos = skipWhitespace(buf, last_pos)
-
 	if start_pos >= l {
-		return &Token{
-			Kind:  T_EOF,
-			Pos:   start_pos,
-			Bytes: nil,
-		}
+		return token(T_EOF, start_pos)
 	}
 
 	var cur = buf[start_pos]
 
+	// .5 is a number, not a member access
+	if cur == '.' && start_pos+1 < l && isDigit(buf[start_pos+1]) {
+		return token(T_NUMBER, scanNumber(l, buf, start_pos))
+	}
+
 	if kind, ok := single_char_tokens[cur]; ok {
-		return &Token{
-			Kind:  kind,
-			Pos:   start_pos,
-			Bytes: buf[start_pos : start_pos+1],
-		}
+		return token(kind, start_pos+1)
 	}
 
 	if cur == '"' || cur == '\'' {
-		var end_pos = scanString(l, buf, cur, start_pos+1)
+		var end_pos, closed = scanString(l, buf, cur, start_pos+1)
+		if !closed {
+			// Reported at the opening quote rather than up to the end of the input
+			return token(T_ILLEGAL, end_pos)
+		}
 
 		kind := T_STRING
 		if cur == '"' {
@@ -321,27 +383,38 @@ func nextToken(buf []byte, last *Token) *Token {
 			kind = T_IDENT
 		}
 
-		return &Token{
-			Kind:  kind,
-			Pos:   start_pos,
-			Bytes: buf[start_pos:end_pos],
+		return token(kind, end_pos)
+	}
+
+	if end_pos, closed := scanPrefixedString(l, buf, start_pos); end_pos != start_pos {
+		if !closed {
+			return token(T_ILLEGAL, end_pos)
+		}
+		kind := T_STRING
+		if buf[end_pos-1] == '"' {
+			kind = T_IDENT
 		}
+		return token(kind, end_pos)
 	}
 
-	if num := scanOperator(l, buf, start_pos); num != start_pos {
-		return &Token{
-			Kind:  T_OPERATOR,
-			Pos:   start_pos,
-			Bytes: buf[start_pos:num],
+	if end_pos, closed := scanDollarString(l, buf, start_pos); end_pos != start_pos {
+		if !closed {
+			// Reported at the opening tag rather than up to the end of the input
+			return token(T_ILLEGAL, end_pos)
 		}
+		return token(T_STRING, end_pos)
+	}
+
+	if end_pos := scanParam(l, buf, start_pos); end_pos != start_pos {
+		return token(T_PARAM, end_pos)
+	}
+
+	if num := scanOperator(l, buf, start_pos); num != start_pos {
+		return token(T_OPERATOR, num)
 	}
 
 	if num := scanNumber(l, buf, start_pos); num != start_pos {
-		return &Token{
-			Kind:  T_NUMBER,
-			Pos:   start_pos,
-			Bytes: buf[start_pos:num],
-		}
+		return token(T_NUMBER, num)
 	}
 
 	if ident := scanIdentifier(l, buf, start_pos); ident != start_pos {
@@ -366,29 +439,69 @@ func nextToken(buf []byte, last *Token) *Token {
 			kind = T_OPERATOR
 		}
 
-		return &Token{
-			Kind:  kind,
-			Pos:   start_pos,
-			Bytes: buf[start_pos:ident],
+		return token(kind, ident)
+	}
+
+	// The whole character is illegal, and not only its first byte
+	_, size := utf8.DecodeRune(buf[start_pos:])
+	return token(T_ILLEGAL, start_pos+size)
+}
+
+// Move line and column, which are those of from, up to the offset to.
+func advancePosition(buf []byte, from int, to int, line int, column int) (int, int) {
+	for i := from; i < to; {
+		if buf[i] == '\n' {
+			line++
+			column = 1
+			i++
+			continue
 		}
+		_, size := utf8.DecodeRune(buf[i:])
+		column++
+		i += size
 	}
+	return line, column
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
 
-	return &Token{
-		Kind:  T_ILLEGAL,
-		Pos:   start_pos,
-		Bytes: buf[start_pos : start_pos+1],
+func scanDigits(l int, buf []byte, pos int) int {
+	for pos < l && isDigit(buf[pos]) {
+		pos++
 	}
+	return pos
 }
 
 // scanNumber scans a number in the buffer, returning the index of the first non-number character.
+// Numbers are digits with an optional decimal part and exponent, like 12, 1.5, .5, 1. or 6.02e23
 func scanNumber(l int, buf []byte, pos int) int {
-	for i := pos; i < l; i++ {
-		var c = buf[i]
-		if c < '0' || c > '9' || c == '.' && i == pos {
+	var i = scanDigits(l, buf, pos)
+
+	if i < l && buf[i] == '.' {
+		// 1..2 is not a number followed by .2
+		if i+1 < l && buf[i+1] == '.' {
 			return i
 		}
+		i = scanDigits(l, buf, i+1)
 	}
-	return l
+
+	if i == pos || i == pos+1 && buf[pos] == '.' {
+		return pos
+	}
+
+	if i < l && (buf[i] == 'e' || buf[i] == 'E') {
+		var exp = i + 1
+		if exp < l && (buf[exp] == '+' || buf[exp] == '-') {
+			exp++
+		}
+		if end := scanDigits(l, buf, exp); end != exp {
+			return end
+		}
+	}
+
+	return i
 }
 
 /*
@@ -410,48 +523,41 @@ func scanOperator(len int, buf []byte, pos int) int {
 		return pos + 1
 	}
 
-	for {
+	for pos < len {
 		var c = buf[pos]
 
 		// special cases to avoid comments
 		if c == '-' && pos+1 < len && buf[pos+1] == '-' {
-			return pos
+			break
 		}
 
 		if c == '/' && pos+1 < len && buf[pos+1] == '*' {
-			return pos
+			break
 		}
 
 		if operator_allowed_wonky_end[c] {
 			has_allowed_wonky = true
 		}
 
-		if operator_char[c] {
-			pos++
-		} else {
-			// Postgres has this funky rule where an operator cannot end by '-' or '+' unless it has one of the wonky operator characters somewhere, so that « @- is a valid operator but *- is not. »
-			if has_allowed_wonky {
-				return pos
-			}
-			for {
-				if pos-1 <= start {
-					break
-				}
-				last := buf[pos-1]
-				if (last == '-' || last == '+') && pos-1 > start {
-					pos--
-				} else {
-					break
-				}
-			}
-			return pos
+		if !operator_char[c] {
+			break
 		}
+		pos++
 
 		cnt++
 		if cnt >= 63 {
 			return pos
 		}
 	}
+
+	// Postgres has this funky rule where an operator cannot end by '-' or '+' unless it has one of the wonky operator characters somewhere, so that « @- is a valid operator but *- is not. »
+	if has_allowed_wonky {
+		return pos
+	}
+	for pos-1 > start && (buf[pos-1] == '-' || buf[pos-1] == '+') {
+		pos--
+	}
+	return pos
 }
 
 var operator_allowed_wonky_end = map[byte]bool{
@@ -486,32 +592,87 @@ var operator_char = [256]bool{
 	'?': true,
 }
 
+// scanParam scans $1 and :name parameters, returning pos if there is none.
+// :name is only a parameter when the colon is not stuck to what comes before it, so that alias:expr and
+// subscripts like a[1:n] still have a colon. Right after a [ it is a slice without lower bound, as in a[:n],
+// and the parser turns it back into a parameter when the bracket opens an array, as in array[:n].
+func scanParam(l int, buf []byte, pos int) int {
+	if buf[pos] == '$' {
+		if end := scanDigits(l, buf, pos+1); end != pos+1 {
+			return end
+		}
+		return pos
+	}
+
+	if buf[pos] != ':' || pos+1 >= l {
+		return pos
+	}
+
+	// Something that ends a value right before the colon
+	if pos > 0 && (isIdentifierByte(buf[pos-1]) || buf[pos-1] == '"' || buf[pos-1] == ']' || buf[pos-1] == ')') {
+		return pos
+	}
+
+	var before = pos - 1
+	for before >= 0 && whitespace[buf[before]] {
+		before--
+	}
+	if before >= 0 && buf[before] == '[' {
+		return pos
+	}
+
+	var c = buf[pos+1]
+	if c != '_' && !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z') {
+		return pos
+	}
+
+	var i = pos + 1
+	for i < l && isIdentifierByte(buf[i]) && buf[i] != '$' {
+		i++
+	}
+	return i
+}
+
+func isIdentifierByte(c byte) bool {
+	return c == '_' || c == '$' || isDigit(c) || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
+
 func scanIdentifier(l int, buf []byte, pos int) int {
 	var quoted = false
 
+	// $ may only appear after the first character
+	if pos < l && buf[pos] == '$' {
+		return pos
+	}
+
 	if pos < l && buf[pos] == '"' {
 		quoted = true
 		pos++
 	}
 
-	var size = 1
-	for i := pos; i < l; i += size {
+	for i := pos; i < l; {
 		var c = buf[i]
 		if quoted {
 			if c == '"' {
 				return i
 			}
-		} else {
-			rune, size := utf8.DecodeRune(buf[i:])
-			if !unicode.IsLetter(rune) && !unicode.IsDigit(rune) && c != '_' && c != '$' {
-				return i + size - 1
-			}
+			i++
+			continue
+		}
+
+		// Letters can take several bytes, which all belong to the identifier
+		r, size := utf8.DecodeRune(buf[i:])
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && c != '_' && c != '$' {
+			return i
 		}
+		i += size
 	}
 	return l
 }
 
-func scanString(l int, buf []byte, start byte, pos int) int {
+// scanString scans the rest of a string or quoted identifier opened at pos-1.
+// When the closing quote is missing, it returns pos and false.
+func scanString(l int, buf []byte, start byte, pos int) (int, bool) {
 	for i := pos; i < l; i++ {
 		var c = buf[i]
 		if c == start {
@@ -520,8 +681,77 @@ func scanString(l int, buf []byte, start byte, pos int) int {
 				i++
 				continue
 			}
-			return i + 1
+			return i + 1, true
 		}
 	}
-	return l
+	return pos, false
+}
+
+// scanEscapeString scans the rest of an E'...' string, where a backslash escapes the next character.
+// When the closing quote is missing, it returns pos and false.
+func scanEscapeString(l int, buf []byte, pos int) (int, bool) {
+	for i := pos; i < l; i++ {
+		switch buf[i] {
+		case '\\':
+			i++
+		case '\'':
+			if i+1 < l && buf[i+1] == '\'' {
+				i++
+				continue
+			}
+			return i + 1, true
+		}
+	}
+	return pos, false
+}
+
+// scanPrefixedString scans E'...' escape strings, B'...' and X'...' bit strings and U&'...' or U&"..." unicode
+// strings and identifiers, returning pos if there is none.
+// When the closing quote is missing, it returns the end of the opening quote and false.
+func scanPrefixedString(l int, buf []byte, pos int) (int, bool) {
+	if pos+2 >= l {
+		return pos, false
+	}
+
+	switch buf[pos] {
+	case 'e', 'E':
+		if buf[pos+1] == '\'' {
+			return scanEscapeString(l, buf, pos+2)
+		}
+	case 'b', 'B', 'x', 'X':
+		if buf[pos+1] == '\'' {
+			return scanString(l, buf, '\'', pos+2)
+		}
+	case 'u', 'U':
+		if buf[pos+1] == '&' && (buf[pos+2] == '\'' || buf[pos+2] == '"') && pos+3 <= l {
+			return scanString(l, buf, buf[pos+2], pos+3)
+		}
+	}
+	return pos, false
+}
+
+// scanDollarString scans $$...$$ or $tag$...$tag$, returning pos if there is none.
+// When the closing tag is missing, it returns the end of the opening tag and false.
+func scanDollarString(l int, buf []byte, pos int) (int, bool) {
+	if buf[pos] != '$' {
+		return pos, false
+	}
+
+	var i = pos + 1
+	for i < l && buf[i] != '$' {
+		var c = buf[i]
+		if !(c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80 || i > pos+1 && isDigit(c)) {
+			return pos, false
+		}
+		i++
+	}
+	if i >= l {
+		return pos, false
+	}
+
+	var tag = buf[pos : i+1]
+	if end := bytes.Index(buf[i+1:], tag); end >= 0 {
+		return i + 1 + end + len(tag), true
+	}
+	return i + 1, false
 }
@@ -0,0 +1,336 @@
+// Copyright 2025 Christophe Eymard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package relql
+
+import (
+	"strings"
+	"testing"
+)
+
+// The tokens of source, as Kind(bytes), up to but not including EOF
+func lexAll(source string) string {
+	var lexer = NewLexer([]byte(source))
+	var res []string
+	for {
+		var tk = lexer.Next()
+		if tk.IsEOF() {
+			break
+		}
+		res = append(res, tk.Name()+"("+string(tk.Bytes)+")")
+		if tk.IsIllegal() {
+			break
+		}
+	}
+	return strings.Join(res, " ")
+}
+
+func TestLexDollarStrings(t *testing.T) {
+	var tests = []struct {
+		source string
+		tokens string
+	}{
+		{`$$abc$$`, `String($$abc$$)`},
+		{`$a$it's $$ here$a$ x`, `String($a$it's $$ here$a$) Ident(x)`},
+		{`$$abc`, `Illegal($$)`},
+		{`$a$abc`, `Illegal($a$)`},
+		{`$a$abc$b$`, `Illegal($a$)`},
+	}
+
+	for _, test := range tests {
+		if got := lexAll(test.source); got != test.tokens {
+			t.Errorf("%s: got %s, expected %s", test.source, got, test.tokens)
+		}
+	}
+}
+
+func TestLexUnterminatedStrings(t *testing.T) {
+	var tests = []struct {
+		source string
+		tokens string
+	}{
+		{`'abc`, `Illegal(')`},
+		{`'abc''`, `Illegal(')`},
+		{`E'abc\'`, `Illegal(E')`},
+		{`U&"abc`, `Illegal(U&")`},
+		{`"abc`, `Illegal(")`},
+		{`a 'b' 'c`, `Ident(a) String('b') Illegal(')`},
+	}
+
+	for _, test := range tests {
+		if got := lexAll(test.source); got != test.tokens {
+			t.Errorf("%s: got %s, expected %s", test.source, got, test.tokens)
+		}
+	}
+}
+
+func TestDollarStringValue(t *testing.T) {
+	var tests = []struct {
+		source string
+		value  string
+		err    bool
+	}{
+		{`$$abc$$`, "abc", false},
+		{`$$$$`, "", false},
+		{`$tag$a $$ b$tag$`, "a $$ b", false},
+		{`$$abc`, "", true},
+		{`$a$abc`, "", true},
+		{`$a$`, "", true},
+		{`$a$abc$b$`, "", true},
+	}
+
+	for _, test := range tests {
+		// The lexer refuses unterminated strings, the tokens are built by hand to check StringValue on its own
+		var tk = &Token{Kind: T_STRING, Bytes: []byte(test.source), End: len(test.source), Line: 1, Column: 1}
+		value, err := tk.StringValue()
+		if test.err {
+			if err == nil {
+				t.Errorf("%s: expected an error, got %q", test.source, value)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", test.source, err)
+		} else if value != test.value {
+			t.Errorf("%s: got %q, expected %q", test.source, value, test.value)
+		}
+	}
+}
+
+func TestLexParams(t *testing.T) {
+	var tests = []struct {
+		source string
+		tokens string
+	}{
+		{`$1`, `Param($1)`},
+		{`id = $12`, `Ident(id) Operator(=) Param($12)`},
+		{`:name`, `Param(:name)`},
+		{`id = :user_id`, `Ident(id) Operator(=) Param(:user_id)`},
+		{`f(:a, :b)`, `Ident(f) LeftParen(() Param(:a) Comma(,) Param(:b) RightParen())`},
+		{`a[1:n]`, `Ident(a) LeftBracket([) Number(1) Operator(:) Ident(n) RightBracket(])`},
+		{`a[:n]`, `Ident(a) LeftBracket([) Operator(:) Ident(n) RightBracket(])`},
+		{`a[ :n]`, `Ident(a) LeftBracket([) Operator(:) Ident(n) RightBracket(])`},
+		{`a[:$1]`, `Ident(a) LeftBracket([) Operator(:) Param($1) RightBracket(])`},
+		{`total: price`, `Ident(total) Operator(:) Ident(price)`},
+		{`x::int`, `Ident(x) Operator(::) Ident(int)`},
+	}
+
+	for _, test := range tests {
+		if got := lexAll(test.source); got != test.tokens {
+			t.Errorf("%s: got %s, expected %s", test.source, got, test.tokens)
+		}
+	}
+}
+
+// Operators stop at the end of the input and before comments, and can't end with + or - unless
+// they have one of ~ ! @ # % ^ & | ` ?
+func TestLexOperators(t *testing.T) {
+	var tests = []struct {
+		source string
+		tokens string
+	}{
+		{`a -`, `Ident(a) Operator(-)`},
+		{`a *`, `Ident(a) Operator(*)`},
+		{`x/`, `Ident(x) Operator(/)`},
+		{`a <=`, `Ident(a) Operator(<=)`},
+		{`a *-`, `Ident(a) Operator(*) Operator(-)`},
+		{`a @-`, `Ident(a) Operator(@-)`},
+		{`a *- b`, `Ident(a) Operator(*) Operator(-) Ident(b)`},
+		{`a @- b`, `Ident(a) Operator(@-) Ident(b)`},
+		{`a >= -1`, `Ident(a) Operator(>=) Operator(-) Number(1)`},
+		{`a -- comment`, `Ident(a)`},
+		{`a +-- comment`, `Ident(a) Operator(+)`},
+		{`a */* comment */ b`, `Ident(a) Operator(*) Ident(b)`},
+		{`a::`, `Ident(a) Operator(::)`},
+		{`a.`, `Ident(a) Operator(.)`},
+	}
+
+	for _, test := range tests {
+		if got := lexAll(test.source); got != test.tokens {
+			t.Errorf("%s: got %s, expected %s", test.source, got, test.tokens)
+		}
+	}
+}
+
+func TestLexComments(t *testing.T) {
+	var tests = []struct {
+		source string
+		tokens string
+	}{
+		{"a -- to the end\nb", `Ident(a) Ident(b)`},
+		{"a /* block */ b", `Ident(a) Ident(b)`},
+		{"a /* outer /* inner */ still outer */ b", `Ident(a) Ident(b)`},
+		{"a /* -- not a line comment */ b", `Ident(a) Ident(b)`},
+		{"a -- /* not a block comment\nb", `Ident(a) Ident(b)`},
+		{"a /* unclosed /* nested */ b", `Ident(a) Illegal(/)`},
+	}
+
+	for _, test := range tests {
+		if got := lexAll(test.source); got != test.tokens {
+			t.Errorf("%q: got %s, expected %s", test.source, got, test.tokens)
+		}
+	}
+}
+
+// Comments and dollar strings can span lines, which the tokens after them have to account for
+func TestLexPositionsAcrossLines(t *testing.T) {
+	var tests = []struct {
+		source string
+		line   int
+		column int
+	}{
+		{"$$x\n\ny$$ z", 3, 5},
+		{"a /* x\ny */ b", 2, 6},
+		{"a -- é\nb", 2, 1},
+		{"a /* é */ b", 1, 11},
+	}
+
+	for _, test := range tests {
+		var lexer = NewLexer([]byte(test.source))
+		lexer.Next()
+		if tk := lexer.Next(); tk.Line != test.line || tk.Column != test.column {
+			t.Errorf("%q: %s is at %d:%d, expected %d:%d", test.source, tk.String(), tk.Line, tk.Column, test.line, test.column)
+		}
+	}
+}
+
+func TestLexNumbers(t *testing.T) {
+	var tests = []struct {
+		source string
+		tokens string
+	}{
+		{`42`, `Number(42)`},
+		{`3.5`, `Number(3.5)`},
+		{`.5`, `Number(.5)`},
+		{`5.`, `Number(5.)`},
+		{`1e10`, `Number(1e10)`},
+		{`1.5E-3`, `Number(1.5E-3)`},
+		{`2e+3`, `Number(2e+3)`},
+		{`1e`, `Number(1) Ident(e)`},
+		{`a.b`, `Ident(a) Operator(.) Ident(b)`},
+	}
+
+	for _, test := range tests {
+		if got := lexAll(test.source); got != test.tokens {
+			t.Errorf("%s: got %s, expected %s", test.source, got, test.tokens)
+		}
+	}
+}
+
+func TestStringValue(t *testing.T) {
+	var tests = []struct {
+		source string
+		value  string
+		err    bool
+	}{
+		{`'it''s'`, "it's", false},
+		{`E'a\nb'`, "a\nb", false},
+		{`e'tab\there'`, "tab\there", false},
+		{`E'it\'s'`, "it's", false},
+		{`E'\\'`, `\`, false},
+		{`E'\x41\x4a'`, "AJ", false},
+		{`E'\x4'`, "\x04", false},
+		{`E'\x'`, "x", false},
+		{`E'\xg'`, "xg", false},
+		{`E'\101'`, "A", false},
+		{`E'é'`, "é", false},
+		{`E'\U0001F600'`, "😀", false},
+		{`E'\u00'`, "", true},
+		{`E'\xff'`, "", true},
+		{`U&'d\0061t\+000061'`, "data", false},
+		{`U&'\\'`, `\`, false},
+		{`U&'\00'`, "", true},
+		{`B'0101'`, "0101", false},
+		{`X'1F'`, "1F", false},
+		{`x'aB09'`, "aB09", false},
+		{`B''`, "", false},
+		{`B'012'`, "", true},
+		{`B'1 0'`, "", true},
+		{`X'zz'`, "", true},
+		{`X'1g'`, "", true},
+		{`'abc`, "", true},
+		{`'abc''`, "", true},
+		{`E'abc\'`, "", true},
+		{`B'01`, "", true},
+	}
+
+	for _, test := range tests {
+		var tokens = lexAll(test.source)
+		if test.err && strings.HasPrefix(tokens, "Illegal(") {
+			continue
+		}
+		if tokens != "String("+test.source+")" {
+			t.Errorf("%s: lexed as %s", test.source, tokens)
+			continue
+		}
+
+		var tk = NewLexer([]byte(test.source)).Next()
+		value, err := tk.StringValue()
+		if test.err {
+			if err == nil {
+				t.Errorf("%s: expected an error, got %q", test.source, value)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", test.source, err)
+		} else if value != test.value {
+			t.Errorf("%s: got %q, expected %q", test.source, value, test.value)
+		}
+	}
+}
+
+func TestIdentValue(t *testing.T) {
+	var tests = []struct {
+		source string
+		value  string
+	}{
+		{`Orders`, "orders"},
+		{`"Orders"`, "Orders"},
+		{`"a""b"`, `a"b`},
+		{`U&"d\0061ta"`, "data"},
+		{`u&"\+01F600"`, "😀"},
+	}
+
+	for _, test := range tests {
+		var tk = NewLexer([]byte(test.source)).Next()
+		value, err := tk.IdentValue()
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", test.source, err)
+		} else if value != test.value {
+			t.Errorf("%s: got %q, expected %q", test.source, value, test.value)
+		}
+	}
+}
+
+func TestLexIdentifiers(t *testing.T) {
+	var tests = []struct {
+		source string
+		tokens string
+	}{
+		{`orders`, `Ident(orders)`},
+		{`a_1$b`, `Ident(a_1$b)`},
+		{`naïve_été`, `Ident(naïve_été)`},
+		{`日本 x`, `Ident(日本) Ident(x)`},
+		{`"a b" c`, `Ident("a b") Ident(c)`},
+		{`a → b`, `Ident(a) Illegal(→)`},
+		{`é→`, `Ident(é) Illegal(→)`},
+	}
+
+	for _, test := range tests {
+		if got := lexAll(test.source); got != test.tokens {
+			t.Errorf("%s: got %s, expected %s", test.source, got, test.tokens)
+		}
+	}
+}
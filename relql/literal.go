@@ -0,0 +1,201 @@
+// Copyright 2025 Christophe Eymard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package relql
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// The value of a string token, with its quotes removed and its escapes interpreted.
+// Bit strings give their digits, without telling whether they were binary or hexadecimal.
+func (t *Token) StringValue() (string, error) {
+	if t.Kind != T_STRING {
+		return "", t.ErrorMessage("expected a string")
+	}
+
+	var b = t.Bytes
+	switch {
+	case b[0] == '$':
+		var tag = bytes.IndexByte(b[1:], '$') + 2
+		if tag < 2 || len(b) < 2*tag || !bytes.HasSuffix(b, b[:tag]) {
+			return "", t.ErrorMessage("unterminated dollar-quoted string")
+		}
+		return string(b[tag : len(b)-tag]), nil
+
+	case b[0] == 'e' || b[0] == 'E':
+		inner, err := t.quoted(b[1:], '\'')
+		if err != nil {
+			return "", err
+		}
+		return t.unescapeBackslashes(inner)
+
+	case b[0] == 'u' || b[0] == 'U':
+		inner, err := t.quoted(b[2:], '\'')
+		if err != nil {
+			return "", err
+		}
+		return t.unescapeUnicode(inner)
+
+	case b[0] == 'b' || b[0] == 'B' || b[0] == 'x' || b[0] == 'X':
+		digits, err := t.quoted(b[1:], '\'')
+		if err != nil {
+			return "", err
+		}
+		var hex = b[0] == 'x' || b[0] == 'X'
+		for i := 0; i < len(digits); i++ {
+			if hex && !isHexDigit(digits[i]) {
+				return "", t.ErrorMessage(`"` + string(digits[i]) + `" is not a valid hexadecimal digit`)
+			}
+			if !hex && digits[i] != '0' && digits[i] != '1' {
+				return "", t.ErrorMessage(`"` + string(digits[i]) + `" is not a valid binary digit`)
+			}
+		}
+		return digits, nil
+	}
+
+	return t.quoted(b, '\'')
+}
+
+// The name an identifier token refers to. Unquoted identifiers are folded to lower case like postgres does.
+func (t *Token) IdentValue() (string, error) {
+	if t.Kind != T_IDENT {
+		return "", t.ErrorMessage("expected an identifier")
+	}
+
+	var b = t.Bytes
+	switch b[0] {
+	case '"':
+		return t.quoted(b, '"')
+	case 'u', 'U':
+		if len(b) > 2 && b[1] == '&' {
+			inner, err := t.quoted(b[2:], '"')
+			if err != nil {
+				return "", err
+			}
+			return t.unescapeUnicode(inner)
+		}
+	}
+	return strings.ToLower(string(b)), nil
+}
+
+// Remove the quotes around b, and turn doubled quotes into one
+func (t *Token) quoted(b []byte, quote byte) (string, error) {
+	if len(b) < 2 || b[0] != quote || b[len(b)-1] != quote {
+		return "", t.ErrorMessage("unterminated quoted string")
+	}
+	var q = string(quote)
+	return strings.ReplaceAll(string(b[1:len(b)-1]), q+q, q), nil
+}
+
+// Escapes of E'...' strings
+func (t *Token) unescapeBackslashes(s string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 >= len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+
+		i++
+		switch c := s[i]; c {
+		case 'b':
+			b.WriteByte('\b')
+		case 'f':
+			b.WriteByte('\f')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case 'x', 'u', 'U':
+			var size = map[byte]int{'x': 2, 'u': 4, 'U': 8}[c]
+			var end = i + 1
+			for end < len(s) && end < i+1+size && isHexDigit(s[end]) {
+				end++
+			}
+			if c == 'x' && end == i+1 {
+				// Like postgres, \x without hexadecimal digits is an x
+				b.WriteByte(c)
+				continue
+			}
+			if c != 'x' && end != i+1+size {
+				return "", t.ErrorMessage("invalid escape \\" + string(c))
+			}
+			n, _ := strconv.ParseUint(s[i+1:end], 16, 32)
+			if c == 'x' {
+				b.WriteByte(byte(n))
+			} else {
+				b.WriteRune(rune(n))
+			}
+			i = end - 1
+		case '0', '1', '2', '3', '4', '5', '6', '7':
+			var end = i
+			for end < len(s) && end < i+3 && s[end] >= '0' && s[end] <= '7' {
+				end++
+			}
+			n, _ := strconv.ParseUint(s[i:end], 8, 32)
+			b.WriteByte(byte(n))
+			i = end - 1
+		default:
+			// \\, \' and any other character stand for themselves
+			b.WriteByte(c)
+		}
+	}
+
+	if !utf8.ValidString(b.String()) {
+		return "", t.ErrorMessage("invalid byte sequence in escape string")
+	}
+	return b.String(), nil
+}
+
+// Escapes of U&'...' strings and U&"..." identifiers, \XXXX and \+XXXXXX
+func (t *Token) unescapeUnicode(s string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			b.WriteByte(s[i])
+			continue
+		}
+
+		if i+1 < len(s) && s[i+1] == '\\' {
+			b.WriteByte('\\')
+			i++
+			continue
+		}
+
+		var start, size = i + 1, 4
+		if start < len(s) && s[start] == '+' {
+			start, size = start+1, 6
+		}
+		if start+size > len(s) {
+			return "", t.ErrorMessage("invalid unicode escape")
+		}
+		n, err := strconv.ParseUint(s[start:start+size], 16, 32)
+		if err != nil || !utf8.ValidRune(rune(n)) {
+			return "", t.ErrorMessage("invalid unicode escape")
+		}
+		b.WriteRune(rune(n))
+		i = start + size - 1
+	}
+	return b.String(), nil
+}
+
+func isHexDigit(c byte) bool {
+	return isDigit(c) || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
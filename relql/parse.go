@@ -13,3 +13,772 @@
 // limitations under the License.
 
 package relql
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/ceymard/pgrel/relql/ast"
+)
+
+// Binding powers of the expression parser, from the loosest to the tightest, following the precedence of postgres.
+// See https://www.postgresql.org/docs/current/sql-syntax-lexical.html#SQL-PRECEDENCE
+const (
+	BP_LOWEST         = 0
+	BP_OR             = 10
+	BP_AND            = 20
+	BP_NOT            = 30
+	BP_IS             = 40 // IS, ISNULL, NOTNULL
+	BP_COMPARISON     = 50
+	BP_PATTERN        = 60 // BETWEEN, IN, LIKE, ILIKE, SIMILAR TO
+	BP_OPERATOR       = 70 // Any other operator
+	BP_ADDITIVE       = 80
+	BP_MULTIPLICATIVE = 90
+	BP_EXPONENT       = 100
+	BP_UNARY          = 110
+	BP_SUBSCRIPT      = 120
+	BP_CAST           = 130
+	BP_MEMBER         = 140 // . and function calls
+)
+
+// All operators are left associative, so their right side is parsed with their own binding power, which makes
+// a - b - c parse as (a - b) - c. Comparisons are the exception, they can't be chained, see infix.
+func operator(name string, bp int) TokenDef {
+	return TokenDef{Kind: T_OPERATOR, Name: name, Lbp: bp, Rbp: bp}
+}
+
+var infix_operators = map[string]TokenDef{
+	"or":      operator("or", BP_OR),
+	"and":     operator("and", BP_AND),
+	"is":      operator("is", BP_IS),
+	"isnull":  operator("isnull", BP_IS),
+	"notnull": operator("notnull", BP_IS),
+	"=":       operator("=", BP_COMPARISON),
+	"<":       operator("<", BP_COMPARISON),
+	">":       operator(">", BP_COMPARISON),
+	"<=":      operator("<=", BP_COMPARISON),
+	">=":      operator(">=", BP_COMPARISON),
+	"<>":      operator("<>", BP_COMPARISON),
+	"!=":      operator("!=", BP_COMPARISON),
+	"between": operator("between", BP_PATTERN),
+	"in":      operator("in", BP_PATTERN),
+	"like":    operator("like", BP_PATTERN),
+	"ilike":   operator("ilike", BP_PATTERN),
+	"similar": operator("similar", BP_PATTERN),
+	"+":       operator("+", BP_ADDITIVE),
+	"-":       operator("-", BP_ADDITIVE),
+	"*":       operator("*", BP_MULTIPLICATIVE),
+	"/":       operator("/", BP_MULTIPLICATIVE),
+	"%":       operator("%", BP_MULTIPLICATIVE),
+	"^":       operator("^", BP_EXPONENT),
+	"::":      operator("::", BP_CAST),
+	".":       operator(".", BP_MEMBER),
+}
+
+// Words that end an expression, or that are part of a construct, and that can't be used as names unless quoted
+var reserved_words = map[string]bool{
+	"when":   true,
+	"then":   true,
+	"else":   true,
+	"end":    true,
+	"where":  true,
+	"order":  true,
+	"limit":  true,
+	"offset": true,
+	"by":     true,
+	"from":   true,
+	"as":     true,
+}
+
+type Parser struct {
+	source []byte
+	lexer  *Lexer
+}
+
+func NewParser(source []byte) *Parser {
+	return &Parser{
+		source: source,
+		lexer:  NewLexer(source),
+	}
+}
+
+// Parse source as a single expression, like a filter
+func ParseExpression(source []byte) (ast.IAstExpression, error) {
+	var p = NewParser(source)
+	expr, err := p.Expression(BP_LOWEST)
+	if err != nil {
+		return nil, err
+	}
+	if tk := p.lexer.Peek(); !tk.IsEOF() {
+		return nil, tk.ErrorMessage("unexpected token after the expression")
+	}
+	return expr, nil
+}
+
+// Parse an expression whose operators bind tighter than rbp
+func (p *Parser) Expression(rbp int) (ast.IAstExpression, error) {
+	left, err := p.prefix()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		def, ok := p.infixDef(left)
+		if !ok || def.Lbp <= rbp {
+			return left, nil
+		}
+		if left, err = p.infix(left, def); err != nil {
+			return nil, err
+		}
+	}
+}
+
+//----------------------------------------------------------------------------------
+
+func isKeyword(tk *Token, words ...string) bool {
+	if (tk.Kind != T_IDENT && tk.Kind != T_OPERATOR) || len(tk.Bytes) == 0 || tk.Bytes[0] == '"' {
+		return false
+	}
+	for _, w := range words {
+		if strings.EqualFold(tk.String(), w) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Parser) consumeKeyword(words ...string) *Token {
+	var tk = p.lexer.Peek()
+	if !isKeyword(tk, words...) {
+		return nil
+	}
+	p.lexer.SetPosition(tk)
+	return tk
+}
+
+func (p *Parser) expectKeyword(word string) error {
+	if p.consumeKeyword(word) == nil {
+		return p.lexer.Peek().ErrorMessage("expected " + strings.ToUpper(word))
+	}
+	return nil
+}
+
+func (p *Parser) expect(kind TokenType, what string) (*Token, error) {
+	var tk = p.lexer.Consume(kind)
+	if tk == nil {
+		return nil, p.lexer.Peek().ErrorMessage("expected " + what)
+	}
+	return tk, nil
+}
+
+// The token after the next one
+func (p *Parser) peekSecond() *Token {
+	return p.peekAt(1)
+}
+
+// The token n tokens after the next one
+func (p *Parser) peekAt(n int) *Token {
+	var saved = p.lexer.last
+	for i := 0; i < n; i++ {
+		p.lexer.SetPosition(p.lexer.Peek())
+	}
+	var tk = p.lexer.Peek()
+	p.lexer.SetPosition(saved)
+	return tk
+}
+
+func tokenSpan(tk *Token) ast.Span {
+	return ast.Span{Pos: tk.Pos, End: tk.End, Line: tk.Line, Column: tk.Column}
+}
+
+// A span that goes from the start of from to the last token that was consumed
+func (p *Parser) spanFrom(from ast.Span) ast.Span {
+	if p.lexer.last != nil {
+		from.End = p.lexer.last.End
+	}
+	return from
+}
+
+// Parse expressions separated by commas up to the closing token, which is consumed
+func (p *Parser) expressionList(end TokenType, what string) ([]ast.IAstExpression, error) {
+	var res []ast.IAstExpression
+	if p.lexer.Consume(end) != nil {
+		return res, nil
+	}
+
+	for {
+		expr, err := p.Expression(BP_LOWEST)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, expr)
+
+		if p.lexer.Consume(T_COMMA) != nil {
+			continue
+		}
+		if _, err := p.expect(end, what); err != nil {
+			return nil, err
+		}
+		return res, nil
+	}
+}
+
+//----------------------------------------------------------------------------------
+
+func (p *Parser) prefix() (ast.IAstExpression, error) {
+	var tk = p.lexer.Next()
+	var span = tokenSpan(tk)
+
+	switch tk.Kind {
+	case T_NUMBER:
+		return &ast.AstNumber{Span: span, Value: tk.String()}, nil
+
+	case T_STRING:
+		return p.stringLiteral(tk)
+
+	case T_PARAM:
+		if tk.Bytes[0] == ':' {
+			return &ast.AstNamedParameter{Span: span, Name: string(tk.Bytes[1:])}, nil
+		}
+		index, err := strconv.Atoi(string(tk.Bytes[1:]))
+		if err != nil || index < 1 {
+			return nil, tk.ErrorMessage("invalid parameter number")
+		}
+		return &ast.AstPositionalParameter{Span: span, Index: index}, nil
+
+	case T_LPAREN:
+		elements, err := p.expressionList(T_RPAREN, "a closing parenthesis")
+		if err != nil {
+			return nil, err
+		}
+		switch len(elements) {
+		case 0:
+			return nil, tk.ErrorMessage("empty parenthesis")
+		case 1:
+			return elements[0], nil
+		}
+		return &ast.AstRowExpression{Span: p.spanFrom(span), Elements: elements}, nil
+
+	case T_OPERATOR:
+		var name = tk.String()
+		switch {
+		case isKeyword(tk, "not"):
+			return p.unary(span, "not", BP_NOT)
+		case name == "-" || name == "+":
+			return p.unary(span, name, BP_UNARY)
+		case name == ":" && p.isStuckIdentifier(tk):
+			// :name right after a [, which the lexer leaves to the parser, see scanParam
+			var ident = p.lexer.Next()
+			return &ast.AstNamedParameter{Span: p.spanFrom(span), Name: ident.String()}, nil
+		case name == "." || name == ":" || name == "::" || isKeyword(tk, "and", "or", "is", "isnull", "notnull", "between", "in", "like", "ilike", "similar"):
+			return nil, tk.ErrorMessage("expected an expression")
+		}
+		return p.unary(span, name, BP_OPERATOR)
+
+	case T_IDENT:
+		return p.identifier(tk)
+	}
+
+	return nil, tk.ErrorMessage("expected an expression")
+}
+
+func (p *Parser) unary(span ast.Span, operator string, bp int) (ast.IAstExpression, error) {
+	operand, err := p.Expression(bp)
+	if err != nil {
+		return nil, err
+	}
+	return &ast.AstUnaryExpression{Span: p.spanFrom(span), Operator: operator, Operand: operand}, nil
+}
+
+func (p *Parser) stringLiteral(tk *Token) (ast.IAstExpression, error) {
+	value, err := tk.StringValue()
+	if err != nil {
+		return nil, err
+	}
+	var res = &ast.AstString{Span: tokenSpan(tk), Value: value}
+	switch tk.Bytes[0] {
+	case 'b', 'B':
+		res.IsBit = true
+	case 'x', 'X':
+		res.IsBit = true
+		res.IsHex = true
+	}
+	return res, nil
+}
+
+// Tell if the next token is an unquoted identifier that directly follows tk, without any space
+func (p *Parser) isStuckIdentifier(tk *Token) bool {
+	var next = p.lexer.Peek()
+	return next.Kind == T_IDENT && next.Pos == tk.End && next.Bytes[0] != '"' && !(len(next.Bytes) > 1 && next.Bytes[1] == '&')
+}
+
+func (p *Parser) identifier(tk *Token) (ast.IAstExpression, error) {
+	var span = tokenSpan(tk)
+
+	name, err := tk.IdentValue()
+	if err != nil {
+		return nil, err
+	}
+	if tk.Bytes[0] == '"' || len(tk.Bytes) > 1 && tk.Bytes[1] == '&' {
+		return &ast.AstIdentifier{Span: span, Name: name, Quoted: true}, nil
+	}
+
+	var next = p.lexer.Peek()
+	switch {
+	case name == "null":
+		return &ast.AstNull{Span: span}, nil
+	case name == "true" || name == "false":
+		return &ast.AstBoolean{Span: span, Value: name == "true"}, nil
+	case name == "case":
+		return p.caseExpression(span)
+	case name == "array" && next.Kind == T_LBRACKET:
+		p.lexer.Next()
+		elements, err := p.expressionList(T_RBRACKET, "a closing bracket")
+		if err != nil {
+			return nil, err
+		}
+		return &ast.AstArrayExpression{Span: p.spanFrom(span), Elements: elements}, nil
+	case name == "row" && next.Kind == T_LPAREN:
+		p.lexer.Next()
+		elements, err := p.expressionList(T_RPAREN, "a closing parenthesis")
+		if err != nil {
+			return nil, err
+		}
+		return &ast.AstRowExpression{Span: p.spanFrom(span), Elements: elements}, nil
+	case name == "cast" && next.Kind == T_LPAREN:
+		return p.castFunction(span)
+	case reserved_words[name]:
+		return nil, tk.ErrorMessage("unexpected keyword, expected an expression")
+	case next.Kind == T_STRING:
+		// A typed literal, like date '2025-01-01'
+		var typ = &ast.AstTypeName{Span: span, Name: name}
+		literal, err := p.stringLiteral(p.lexer.Next())
+		if err != nil {
+			return nil, err
+		}
+		return &ast.AstCastExpression{Span: p.spanFrom(span), Expression: literal, Type: typ}, nil
+	}
+
+	return &ast.AstIdentifier{Span: span, Name: name}, nil
+}
+
+// CASE [subject] WHEN ... THEN ... [ELSE ...] END
+func (p *Parser) caseExpression(span ast.Span) (ast.IAstExpression, error) {
+	var res = &ast.AstCaseExpression{}
+	var err error
+
+	if !isKeyword(p.lexer.Peek(), "when") {
+		if res.Subject, err = p.Expression(BP_LOWEST); err != nil {
+			return nil, err
+		}
+	}
+
+	for p.consumeKeyword("when") != nil {
+		var when = &ast.AstWhen{}
+		if when.Condition, err = p.Expression(BP_LOWEST); err != nil {
+			return nil, err
+		}
+		if err := p.expectKeyword("then"); err != nil {
+			return nil, err
+		}
+		if when.Result, err = p.Expression(BP_LOWEST); err != nil {
+			return nil, err
+		}
+		res.Whens = append(res.Whens, when)
+	}
+
+	if len(res.Whens) == 0 {
+		return nil, p.lexer.Peek().ErrorMessage("expected WHEN")
+	}
+
+	if p.consumeKeyword("else") != nil {
+		if res.Else, err = p.Expression(BP_LOWEST); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := p.expectKeyword("end"); err != nil {
+		return nil, err
+	}
+
+	res.Span = p.spanFrom(span)
+	return res, nil
+}
+
+// CAST(expr AS type)
+func (p *Parser) castFunction(span ast.Span) (ast.IAstExpression, error) {
+	p.lexer.Next()
+
+	expr, err := p.Expression(BP_LOWEST)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expectKeyword("as"); err != nil {
+		return nil, err
+	}
+	typ, err := p.typeName()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.expect(T_RPAREN, "a closing parenthesis"); err != nil {
+		return nil, err
+	}
+
+	return &ast.AstCastExpression{Span: p.spanFrom(span), Expression: expr, Type: typ}, nil
+}
+
+// A type as written after :: or in CAST, like int, varchar(10), public.my_type, double precision,
+// timestamp(3) with time zone or text[]
+func (p *Parser) typeName() (*ast.AstTypeName, error) {
+	var tk = p.lexer.Next()
+	if tk.Kind != T_IDENT {
+		return nil, tk.ErrorMessage("expected a type name")
+	}
+
+	var span = tokenSpan(tk)
+	name, err := tk.IdentValue()
+	if err != nil {
+		return nil, err
+	}
+	var quoted = tk.Bytes[0] == '"'
+	var res = &ast.AstTypeName{Name: name}
+
+	if p.lexer.PeekString(".") != nil {
+		p.lexer.Next()
+		var tk = p.lexer.Next()
+		if tk.Kind != T_IDENT {
+			return nil, tk.ErrorMessage("expected a type name")
+		}
+		res.Schema = res.Name
+		if res.Name, err = tk.IdentValue(); err != nil {
+			return nil, err
+		}
+		quoted = tk.Bytes[0] == '"'
+	}
+
+	if !quoted {
+		switch res.Name {
+		case "double":
+			if err := p.expectKeyword("precision"); err != nil {
+				return nil, err
+			}
+			res.Name = "double precision"
+		case "character", "char", "bit", "national":
+			if res.Name == "national" {
+				if p.consumeKeyword("character", "char") == nil {
+					return nil, p.lexer.Peek().ErrorMessage("expected CHARACTER")
+				}
+				res.Name = "character"
+			}
+			if p.consumeKeyword("varying") != nil {
+				res.Name += " varying"
+			}
+		}
+	}
+
+	if p.lexer.Consume(T_LPAREN) != nil {
+		if res.Modifiers, err = p.expressionList(T_RPAREN, "a closing parenthesis"); err != nil {
+			return nil, err
+		}
+	}
+
+	if !quoted && (res.Name == "timestamp" || res.Name == "time") {
+		if kw := p.consumeKeyword("with", "without"); kw != nil {
+			if err := p.expectKeyword("time"); err != nil {
+				return nil, err
+			}
+			if err := p.expectKeyword("zone"); err != nil {
+				return nil, err
+			}
+			res.Name += " " + strings.ToLower(kw.String()) + " time zone"
+		}
+	}
+
+	for p.lexer.Consume(T_LBRACKET) != nil {
+		p.lexer.Consume(T_NUMBER)
+		if _, err := p.expect(T_RBRACKET, "a closing bracket"); err != nil {
+			return nil, err
+		}
+		res.ArrayDimensions++
+	}
+
+	res.Span = p.spanFrom(span)
+	return res, nil
+}
+
+//----------------------------------------------------------------------------------
+
+// Find what the next token does when it follows an expression, if anything
+func (p *Parser) infixDef(left ast.IAstExpression) (TokenDef, bool) {
+	var tk = p.lexer.Peek()
+
+	switch tk.Kind {
+	case T_LBRACKET:
+		return TokenDef{Kind: T_LBRACKET, Name: "[", Lbp: BP_SUBSCRIPT}, true
+	case T_LPAREN:
+		// Only names can be called
+		switch left.(type) {
+		case *ast.AstIdentifier, *ast.AstMemberExpression:
+			return TokenDef{Kind: T_LPAREN, Name: "(", Lbp: BP_MEMBER}, true
+		}
+		return TokenDef{}, false
+	case T_OPERATOR:
+	default:
+		return TokenDef{}, false
+	}
+
+	// Keywords are case insensitive
+	var name = tk.String()
+	if unicode.IsLetter(rune(tk.Bytes[0])) {
+		name = strings.ToLower(name)
+	}
+
+	switch name {
+	case ":":
+		return TokenDef{}, false
+	case "not":
+		// NOT BETWEEN, NOT IN, NOT LIKE...
+		if isKeyword(p.peekSecond(), "between", "in", "like", "ilike", "similar") {
+			return operator("not", BP_PATTERN), true
+		}
+		return TokenDef{}, false
+	}
+
+	if def, ok := infix_operators[name]; ok {
+		return def, true
+	}
+	return operator(name, BP_OPERATOR), true
+}
+
+func (p *Parser) infix(left ast.IAstExpression, def TokenDef) (ast.IAstExpression, error) {
+	var tk = p.lexer.Next()
+	var span = left.GetSpan()
+
+	switch def.Name {
+	case "[":
+		return p.subscript(left)
+	case "(":
+		return p.call(left)
+	case ".":
+		return p.member(left)
+	case "::":
+		typ, err := p.typeName()
+		if err != nil {
+			return nil, err
+		}
+		return &ast.AstCastExpression{Span: p.spanFrom(span), Expression: left, Type: typ}, nil
+	case "is":
+		return p.is(left)
+	case "isnull", "notnull":
+		return &ast.AstIsExpression{Span: p.spanFrom(span), Expression: left, Test: "null", Not: def.Name == "notnull"}, nil
+	case "not":
+		return p.pattern(left, p.lexer.Next(), true)
+	case "between", "in", "like", "ilike", "similar":
+		return p.pattern(left, tk, false)
+	}
+
+	right, err := p.Expression(def.Rbp)
+	if err != nil {
+		return nil, err
+	}
+	var res = &ast.AstBinaryExpression{Span: p.spanFrom(span), Left: left, Right: right, Operator: def.Name}
+
+	// Comparisons are not associative in postgres, which refuses a = b = c instead of reading (a = b) = c
+	if def.Lbp == BP_COMPARISON {
+		if next, ok := p.infixDef(res); ok && next.Lbp == BP_COMPARISON {
+			return nil, p.lexer.Peek().ErrorMessage("comparisons can't be chained, add parenthesis")
+		}
+	}
+	return res, nil
+}
+
+// expr[index] or expr[lower:upper], either bound of a slice being optional
+func (p *Parser) subscript(left ast.IAstExpression) (ast.IAstExpression, error) {
+	var res = &ast.AstSubscriptExpression{Expression: left}
+	var err error
+
+	if p.lexer.PeekString(":") == nil {
+		if res.Index, err = p.Expression(BP_LOWEST); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.lexer.ConsumeString(":") != nil {
+		res.IsSlice = true
+		if p.lexer.PeekKind(T_RBRACKET) == nil {
+			if res.Upper, err = p.Expression(BP_LOWEST); err != nil {
+				return nil, err
+			}
+		}
+	} else if res.Index == nil {
+		return nil, p.lexer.Peek().ErrorMessage("expected an index")
+	}
+
+	if _, err := p.expect(T_RBRACKET, "a closing bracket"); err != nil {
+		return nil, err
+	}
+
+	res.Span = p.spanFrom(left.GetSpan())
+	return res, nil
+}
+
+// fn(), fn(*), fn(DISTINCT a), fn(a, name => b)
+func (p *Parser) call(left ast.IAstExpression) (ast.IAstExpression, error) {
+	var res = &ast.AstFunctionCall{Function: left}
+
+	if p.lexer.PeekString("*") != nil && p.peekSecond().Kind == T_RPAREN {
+		p.lexer.Next()
+		p.lexer.Next()
+		res.Star = true
+		res.Span = p.spanFrom(left.GetSpan())
+		return res, nil
+	}
+
+	if p.lexer.Consume(T_RPAREN) == nil {
+		res.Distinct = p.consumeKeyword("distinct") != nil
+
+		for {
+			var tk = p.lexer.Peek()
+			if tk.Kind == T_IDENT && p.peekSecond().String() == "=>" {
+				p.lexer.Next()
+				p.lexer.Next()
+				name, err := tk.IdentValue()
+				if err != nil {
+					return nil, err
+				}
+				value, err := p.Expression(BP_LOWEST)
+				if err != nil {
+					return nil, err
+				}
+				res.Arguments = append(res.Arguments, &ast.AstNamedArgument{Span: p.spanFrom(tokenSpan(tk)), Name: name, Value: value})
+			} else {
+				arg, err := p.Expression(BP_LOWEST)
+				if err != nil {
+					return nil, err
+				}
+				res.Arguments = append(res.Arguments, arg)
+			}
+
+			if p.lexer.Consume(T_COMMA) != nil {
+				continue
+			}
+			if _, err := p.expect(T_RPAREN, "a closing parenthesis"); err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+
+	res.Span = p.spanFrom(left.GetSpan())
+	return res, nil
+}
+
+// expr.name or expr.*
+func (p *Parser) member(left ast.IAstExpression) (ast.IAstExpression, error) {
+	var tk = p.lexer.Next()
+	var res = &ast.AstMemberExpression{Expression: left}
+
+	switch {
+	case tk.Kind == T_IDENT:
+		name, err := tk.IdentValue()
+		if err != nil {
+			return nil, err
+		}
+		res.Member = name
+	case tk.String() == "*":
+		res.Member = "*"
+	default:
+		return nil, tk.ErrorMessage("expected a name after '.'")
+	}
+
+	res.Span = p.spanFrom(left.GetSpan())
+	return res, nil
+}
+
+// IS [NOT] NULL, TRUE, FALSE, UNKNOWN or DISTINCT FROM
+func (p *Parser) is(left ast.IAstExpression) (ast.IAstExpression, error) {
+	var not = p.consumeKeyword("not") != nil
+
+	if tk := p.consumeKeyword("null", "true", "false", "unknown"); tk != nil {
+		return &ast.AstIsExpression{Span: p.spanFrom(left.GetSpan()), Expression: left, Not: not, Test: strings.ToLower(tk.String())}, nil
+	}
+
+	if p.consumeKeyword("distinct") != nil {
+		if err := p.expectKeyword("from"); err != nil {
+			return nil, err
+		}
+		right, err := p.Expression(BP_IS)
+		if err != nil {
+			return nil, err
+		}
+		return &ast.AstDistinctExpression{Span: p.spanFrom(left.GetSpan()), Left: left, Right: right, Not: not}, nil
+	}
+
+	return nil, p.lexer.Peek().ErrorMessage("expected NULL, TRUE, FALSE, UNKNOWN or DISTINCT FROM")
+}
+
+// BETWEEN, IN, LIKE, ILIKE and SIMILAR TO, which tk is, possibly after a NOT
+func (p *Parser) pattern(left ast.IAstExpression, tk *Token, not bool) (ast.IAstExpression, error) {
+	var span = left.GetSpan()
+
+	switch strings.ToLower(tk.String()) {
+	case "between":
+		var res = &ast.AstBetweenExpression{Expression: left, Not: not}
+		if p.consumeKeyword("symmetric") != nil {
+			res.Symmetric = true
+		} else {
+			p.consumeKeyword("asymmetric")
+		}
+
+		var err error
+		// The bounds bind tighter than AND, so that the AND of BETWEEN is not taken for a boolean one
+		if res.Low, err = p.Expression(BP_PATTERN); err != nil {
+			return nil, err
+		}
+		if err := p.expectKeyword("and"); err != nil {
+			return nil, err
+		}
+		if res.High, err = p.Expression(BP_PATTERN); err != nil {
+			return nil, err
+		}
+		res.Span = p.spanFrom(span)
+		return res, nil
+
+	case "in":
+		if _, err := p.expect(T_LPAREN, "an opening parenthesis"); err != nil {
+			return nil, err
+		}
+		list, err := p.expressionList(T_RPAREN, "a closing parenthesis")
+		if err != nil {
+			return nil, err
+		}
+		if len(list) == 0 {
+			return nil, tk.ErrorMessage("IN needs at least one value")
+		}
+		return &ast.AstInExpression{Span: p.spanFrom(span), Expression: left, List: list, Not: not}, nil
+
+	case "like", "ilike", "similar":
+		var res = &ast.AstLikeExpression{Expression: left, Operator: strings.ToLower(tk.String()), Not: not}
+		if res.Operator == "similar" {
+			if err := p.expectKeyword("to"); err != nil {
+				return nil, err
+			}
+			res.Operator = "similar to"
+		}
+
+		var err error
+		if res.Pattern, err = p.Expression(BP_PATTERN); err != nil {
+			return nil, err
+		}
+		if p.consumeKeyword("escape") != nil {
+			if res.Escape, err = p.Expression(BP_PATTERN); err != nil {
+				return nil, err
+			}
+		}
+		res.Span = p.spanFrom(span)
+		return res, nil
+	}
+
+	return nil, tk.ErrorMessage("expected BETWEEN, IN, LIKE, ILIKE or SIMILAR TO")
+}
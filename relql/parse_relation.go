@@ -0,0 +1,336 @@
+// Copyright 2025 Christophe Eymard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package relql
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/ceymard/pgrel/relql/ast"
+)
+
+// Parse a query, which selects fields from a relation and from the ones it is related to, like
+//
+//	api.orders {
+//		id,
+//		total,
+//		customer { name },
+//		lines(order: position) { * },
+//		with_tax: total * 1.2,
+//	} where total > 100 order by id desc limit 20
+//
+// Nested relations take their filters and ordering as arguments : where, order, limit and offset.
+// order may be given several times.
+func Parse(source []byte) (*ast.AstRelation, error) {
+	var p = NewParser(source)
+	var start = tokenSpan(p.lexer.Peek())
+
+	alias, err := p.alias()
+	if err != nil {
+		return nil, err
+	}
+
+	rel, err := p.relation(start, alias)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.clauses(rel); err != nil {
+		return nil, err
+	}
+	rel.Span = p.spanFrom(start)
+
+	p.lexer.Consume(T_SEMICOLON)
+	if tk := p.lexer.Peek(); !tk.IsEOF() {
+		return nil, tk.ErrorMessage("unexpected token after the query")
+	}
+
+	return rel, nil
+}
+
+// alias: in front of a field or relation, or an empty string if there is none
+func (p *Parser) alias() (string, error) {
+	var tk = p.lexer.Peek()
+	if tk.Kind != T_IDENT || p.peekSecond().String() != ":" {
+		return "", nil
+	}
+	p.lexer.Next()
+	p.lexer.Next()
+	return tk.IdentValue()
+}
+
+// name or schema.name
+func (p *Parser) sqlIdentifier() (*ast.AstSqlIdentifier, error) {
+	var tk = p.lexer.Next()
+	if tk.Kind != T_IDENT {
+		return nil, tk.ErrorMessage("expected a name")
+	}
+
+	name, err := tk.IdentValue()
+	if err != nil {
+		return nil, err
+	}
+	var res = &ast.AstSqlIdentifier{Span: tokenSpan(tk), Name: name}
+
+	if p.lexer.PeekString(".") != nil && p.peekSecond().Kind == T_IDENT {
+		p.lexer.Next()
+		res.Schema = res.Name
+		if res.Name, err = p.lexer.Next().IdentValue(); err != nil {
+			return nil, err
+		}
+	}
+
+	res.Span = p.spanFrom(res.Span)
+	return res, nil
+}
+
+// Tell if what comes is a nested relation, which is a name followed by its fields in braces, with
+// arguments in parenthesis that are told apart from the ones of a function call by their colon.
+func (p *Parser) isRelationStart() bool {
+	if p.peekAt(0).Kind != T_IDENT {
+		return false
+	}
+
+	var i = 1
+	if p.peekAt(i).String() == "." && p.peekAt(i+1).Kind == T_IDENT {
+		i += 2
+	}
+
+	switch p.peekAt(i).Kind {
+	case T_LBRACE:
+		return true
+	case T_LPAREN:
+		var arg = p.peekAt(i + 1)
+		if arg.Kind == T_RPAREN {
+			return p.peekAt(i+2).Kind == T_LBRACE
+		}
+		return arg.Kind == T_IDENT && p.peekAt(i+2).String() == ":"
+	}
+	return false
+}
+
+// name [(arguments)] { fields }
+func (p *Parser) relation(start ast.Span, alias string) (*ast.AstRelation, error) {
+	id, err := p.sqlIdentifier()
+	if err != nil {
+		return nil, err
+	}
+	var res = &ast.AstRelation{Id: id, Alias: alias}
+
+	if p.lexer.Consume(T_LPAREN) != nil {
+		if err := p.relationArguments(res); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := p.expect(T_LBRACE, "'{'"); err != nil {
+		return nil, err
+	}
+
+	for p.lexer.Consume(T_RBRACE) == nil {
+		field, err := p.field()
+		if err != nil {
+			return nil, err
+		}
+		res.Fields = append(res.Fields, field)
+
+		if p.lexer.Consume(T_COMMA) != nil {
+			continue
+		}
+		if _, err := p.expect(T_RBRACE, "',' or '}'"); err != nil {
+			return nil, err
+		}
+		break
+	}
+
+	res.Span = p.spanFrom(start)
+	return res, nil
+}
+
+// *, [alias:] column, [alias:] relation { ... } or [alias:] expression
+func (p *Parser) field() (ast.IAstField, error) {
+	var tk = p.lexer.Peek()
+	var start = tokenSpan(tk)
+
+	if tk.Kind == T_OPERATOR && tk.String() == "*" {
+		p.lexer.Next()
+		return &ast.AstWildcard{Span: start}, nil
+	}
+
+	alias, err := p.alias()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.isRelationStart() {
+		return p.relation(start, alias)
+	}
+
+	expr, err := p.Expression(BP_LOWEST)
+	if err != nil {
+		return nil, err
+	}
+
+	if ident, ok := expr.(*ast.AstIdentifier); ok {
+		return &ast.AstField{
+			Span:  p.spanFrom(start),
+			Id:    &ast.AstSqlIdentifier{Span: ident.Span, Name: ident.Name},
+			Alias: alias,
+		}, nil
+	}
+
+	return &ast.AstComputedField{Span: p.spanFrom(start), Alias: alias, Expression: expr}, nil
+}
+
+// The arguments of a relation, after its opening parenthesis
+func (p *Parser) relationArguments(rel *ast.AstRelation) error {
+	for p.lexer.Consume(T_RPAREN) == nil {
+		var tk = p.lexer.Next()
+		if tk.Kind != T_IDENT || p.lexer.ConsumeString(":") == nil {
+			return tk.ErrorMessage("expected where:, order:, limit: or offset:")
+		}
+
+		var err error
+		switch strings.ToLower(tk.String()) {
+		case "where":
+			if rel.Where != nil {
+				return tk.ErrorMessage("where is given twice")
+			}
+			rel.Where, err = p.Expression(BP_LOWEST)
+		case "order":
+			var order *ast.AstOrderExpression
+			if order, err = p.orderExpression(); err == nil {
+				rel.Order = append(rel.Order, order)
+			}
+		case "limit":
+			if rel.Limit != nil {
+				return tk.ErrorMessage("limit is given twice")
+			}
+			rel.Limit, err = p.count()
+		case "offset":
+			if rel.Offset != nil {
+				return tk.ErrorMessage("offset is given twice")
+			}
+			rel.Offset, err = p.count()
+		default:
+			return tk.ErrorMessage("unknown argument, expected where, order, limit or offset")
+		}
+		if err != nil {
+			return err
+		}
+
+		if p.lexer.Consume(T_COMMA) != nil {
+			continue
+		}
+		if _, err := p.expect(T_RPAREN, "',' or ')'"); err != nil {
+			return err
+		}
+		break
+	}
+	return nil
+}
+
+// WHERE, ORDER BY, LIMIT and OFFSET after the fields of the top relation
+func (p *Parser) clauses(rel *ast.AstRelation) error {
+	var err error
+
+	if tk := p.consumeKeyword("where"); tk != nil {
+		if rel.Where != nil {
+			return tk.ErrorMessage("where is already given as an argument")
+		}
+		if rel.Where, err = p.Expression(BP_LOWEST); err != nil {
+			return err
+		}
+	}
+
+	if p.consumeKeyword("order") != nil {
+		if err := p.expectKeyword("by"); err != nil {
+			return err
+		}
+		for {
+			order, err := p.orderExpression()
+			if err != nil {
+				return err
+			}
+			rel.Order = append(rel.Order, order)
+			if p.lexer.Consume(T_COMMA) == nil {
+				break
+			}
+		}
+	}
+
+	if tk := p.consumeKeyword("limit"); tk != nil {
+		if rel.Limit != nil {
+			return tk.ErrorMessage("limit is already given as an argument")
+		}
+		if rel.Limit, err = p.count(); err != nil {
+			return err
+		}
+	}
+
+	if tk := p.consumeKeyword("offset"); tk != nil {
+		if rel.Offset != nil {
+			return tk.ErrorMessage("offset is already given as an argument")
+		}
+		if rel.Offset, err = p.count(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// expression [ASC | DESC] [NULLS FIRST | NULLS LAST]
+func (p *Parser) orderExpression() (*ast.AstOrderExpression, error) {
+	var start = tokenSpan(p.lexer.Peek())
+
+	expr, err := p.Expression(BP_LOWEST)
+	if err != nil {
+		return nil, err
+	}
+	var res = &ast.AstOrderExpression{Expression: expr}
+
+	if tk := p.consumeKeyword("asc", "desc"); tk != nil {
+		res.Desc = strings.EqualFold(tk.String(), "desc")
+	}
+
+	if p.consumeKeyword("nulls") != nil {
+		var tk = p.consumeKeyword("first", "last")
+		if tk == nil {
+			return nil, p.lexer.Peek().ErrorMessage("expected FIRST or LAST")
+		}
+		res.NullsFirst = strings.EqualFold(tk.String(), "first")
+		res.NullsLast = !res.NullsFirst
+	}
+
+	res.Span = p.spanFrom(start)
+	return res, nil
+}
+
+// The value of LIMIT or OFFSET, a positive integer or a parameter so that the query can be prepared once
+func (p *Parser) count() (ast.IAstExpression, error) {
+	var tk = p.lexer.Peek()
+	switch tk.Kind {
+	case T_PARAM:
+		return p.prefix()
+	case T_NUMBER:
+		p.lexer.Next()
+		if n, err := strconv.Atoi(tk.String()); err != nil || n < 0 {
+			return nil, tk.ErrorMessage("expected a positive integer")
+		}
+		return &ast.AstNumber{Span: tokenSpan(tk), Value: tk.String()}, nil
+	}
+	return nil, tk.ErrorMessage("expected a number or a parameter")
+}
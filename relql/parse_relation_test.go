@@ -0,0 +1,206 @@
+// Copyright 2025 Christophe Eymard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package relql
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ceymard/pgrel/relql/ast"
+)
+
+// A compact form of a parsed relation, with expressions given by sexpr
+func describeRelation(rel *ast.AstRelation) string {
+	var b strings.Builder
+	if rel.Alias != "" {
+		b.WriteString(rel.Alias + ": ")
+	}
+	b.WriteString(rel.Id.String())
+
+	var args []string
+	if rel.Where != nil {
+		args = append(args, "where "+sexpr(rel.Where))
+	}
+	for _, o := range rel.Order {
+		var order = o.(*ast.AstOrderExpression)
+		var s = "order " + sexpr(order.Expression)
+		if order.Desc {
+			s += " desc"
+		}
+		if order.NullsFirst {
+			s += " nulls first"
+		}
+		if order.NullsLast {
+			s += " nulls last"
+		}
+		args = append(args, s)
+	}
+	if rel.Limit != nil {
+		args = append(args, "limit "+sexpr(rel.Limit))
+	}
+	if rel.Offset != nil {
+		args = append(args, "offset "+sexpr(rel.Offset))
+	}
+	if len(args) > 0 {
+		b.WriteString("(" + strings.Join(args, ", ") + ")")
+	}
+
+	var fields []string
+	for _, field := range rel.Fields {
+		switch f := field.(type) {
+		case *ast.AstWildcard:
+			fields = append(fields, "*")
+		case *ast.AstField:
+			if f.Alias != "" {
+				fields = append(fields, f.Alias+": "+f.Id.Name)
+			} else {
+				fields = append(fields, f.Id.Name)
+			}
+		case *ast.AstComputedField:
+			if f.Alias != "" {
+				fields = append(fields, f.Alias+": "+sexpr(f.Expression))
+			} else {
+				fields = append(fields, sexpr(f.Expression))
+			}
+		case *ast.AstRelation:
+			fields = append(fields, describeRelation(f))
+		}
+	}
+	b.WriteString(" { " + strings.Join(fields, ", ") + " }")
+	return b.String()
+}
+
+func TestParse(t *testing.T) {
+	var tests = []struct {
+		source   string
+		expected string
+	}{
+		{`orders { * }`, `orders { * }`},
+		{`api.orders { id, total }`, `api.orders { id, total }`},
+		{`orders { *, n: id }`, `orders { *, n: id }`},
+		{`orders { id, }`, `orders { id }`},
+		{`orders {}`, `orders {  }`},
+		{`"Orders" { "Id" }`, `Orders { Id }`},
+
+		// Computed fields
+		{`orders { with_tax: total * 1.2 }`, `orders { with_tax: (* total 1.2) }`},
+		{`orders { total::int }`, `orders { (:: total int) }`},
+		{`orders { lower(name) }`, `orders { (call lower name) }`},
+		{`orders { n: count(*) }`, `orders { n: (call count *) }`},
+		{`orders { api.total(id) }`, `orders { (call (. api total) id) }`},
+		{`orders { f(where => 1) }`, `orders { (call f (=> where 1)) }`},
+
+		// Nested relations
+		{`orders { customer { name } }`, `orders { customer { name } }`},
+		{`orders { buyer: customer { name } }`, `orders { buyer: customer { name } }`},
+		{`orders { api.customers { name } }`, `orders { api.customers { name } }`},
+		{`orders { lines() { * } }`, `orders { lines { * } }`},
+		{`orders { lines(where: qty > 1, order: position desc nulls last, limit: 5, offset: 10) { * } }`,
+			`orders { lines(where (> qty 1), order position desc nulls last, limit 5, offset 10) { * } }`},
+		{`orders { lines(order: a, order: b asc) { id } }`, `orders { lines(order a, order b) { id } }`},
+		{`orders { lines(limit: :n, offset: $2) { id } }`, `orders { lines(limit :n, offset $2) { id } }`},
+		{`orders { lines { product { name } } }`, `orders { lines { product { name } } }`},
+
+		// Clauses of the top relation
+		{`orders { id } where total > 100`, `orders(where (> total 100)) { id }`},
+		{`orders { id } order by id desc, total`, `orders(order id desc, order total) { id }`},
+		{`orders { id } limit 20 offset 40`, `orders(limit 20, offset 40) { id }`},
+		{`orders { id } limit :n offset $1;`, `orders(limit :n, offset $1) { id }`},
+		{`orders { id } WHERE a ORDER BY b NULLS FIRST LIMIT 1`, `orders(where a, order b nulls first, limit 1) { id }`},
+		{`orders(where: a, order: b) { id } order by c limit 1`, `orders(where a, order b, order c, limit 1) { id }`},
+		{`top: orders { id }`, `top: orders { id }`},
+	}
+
+	for _, test := range tests {
+		rel, err := Parse([]byte(test.source))
+		if err != nil {
+			t.Errorf("%s: %s", test.source, err)
+			continue
+		}
+		if got := describeRelation(rel); got != test.expected {
+			t.Errorf("%s: got %s, expected %s", test.source, got, test.expected)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	var tests = []struct {
+		source string
+		err    string
+	}{
+		{``, "expected a name"},
+		{`orders`, "expected '{'"},
+		{`orders { id`, "expected ',' or '}'"},
+		{`orders { id name }`, "expected ',' or '}'"},
+		{`orders { id } where`, "expected an expression"},
+		{`orders { id } where a -`, "expected an expression"},
+		{`orders { id } limit`, "expected a number or a parameter"},
+		{`orders { id } limit -1`, "expected a number or a parameter"},
+		{`orders { id } limit 1.5`, "expected a positive integer"},
+		{`orders { id } limit 'a'`, "expected a number or a parameter"},
+		{`orders { id } order id`, "expected BY"},
+		{`orders { id } order by id nulls`, "expected FIRST or LAST"},
+		{`orders { id } extra`, "unexpected token after the query"},
+		{`orders { id } limit 1 limit 2`, "unexpected token after the query"},
+		{`orders { lines(where: a, where: b) { id } }`, "where is given twice"},
+		{`orders { lines(limit: 1, limit: 2) { id } }`, "limit is given twice"},
+		{`orders { lines(offset: 1, offset: 2) { id } }`, "offset is given twice"},
+		{`orders { lines(size: 1) { id } }`, "unknown argument, expected where, order, limit or offset"},
+		{`orders { lines(limit: 1, size) { id } }`, "expected where:, order:, limit: or offset:"},
+		{`orders { lines(where a) { id } }`, "unexpected keyword"},
+		{`orders { lines(limit: 1 { id } }`, "expected ',' or ')'"},
+		{`orders(where: a) { id } where b`, "where is already given as an argument"},
+		{`orders(limit: 1) { id } limit 2`, "limit is already given as an argument"},
+		{`orders(offset: 1) { id } offset 2`, "offset is already given as an argument"},
+	}
+
+	for _, test := range tests {
+		_, err := Parse([]byte(test.source))
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: expected %q, got %v", test.source, test.err, err)
+		}
+	}
+}
+
+// A name followed by parenthesis is a nested relation when braces or an argument with a colon come next,
+// and a function call otherwise
+func TestParseRelationOrCall(t *testing.T) {
+	var tests = []struct {
+		source   string
+		relation bool
+	}{
+		{`orders { lines { id } }`, true},
+		{`orders { api.lines { id } }`, true},
+		{`orders { lines() { id } }`, true},
+		{`orders { lines(limit: 1) { id } }`, true},
+		{`orders { lines(where: a = 1) { id } }`, true},
+		{`orders { lines() }`, false},
+		{`orders { lines(id) }`, false},
+		{`orders { api.lines(id) }`, false},
+		{`orders { lines(a => 1) }`, false},
+		{`orders { n: lines(id) }`, false},
+	}
+
+	for _, test := range tests {
+		rel, err := Parse([]byte(test.source))
+		if err != nil {
+			t.Errorf("%s: %s", test.source, err)
+			continue
+		}
+		if _, ok := rel.Fields[0].(*ast.AstRelation); ok != test.relation {
+			t.Errorf("%s: expected relation=%v, got %T", test.source, test.relation, rel.Fields[0])
+		}
+	}
+}
@@ -0,0 +1,262 @@
+// Copyright 2025 Christophe Eymard
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package relql
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/ceymard/pgrel/relql/ast"
+)
+
+func TestParseColonAfterBracket(t *testing.T) {
+	expr, err := ParseExpression([]byte(`a[:n]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub, ok := expr.(*ast.AstSubscriptExpression)
+	if !ok || !sub.IsSlice || sub.Index != nil {
+		t.Fatalf("a[:n] should be a slice without lower bound, got %#v", expr)
+	}
+	if upper, ok := sub.Upper.(*ast.AstIdentifier); !ok || upper.Name != "n" {
+		t.Errorf("a[:n] should be sliced up to the column n, got %#v", sub.Upper)
+	}
+
+	expr, err = ParseExpression([]byte(`array[:a, :b]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	arr, ok := expr.(*ast.AstArrayExpression)
+	if !ok || len(arr.Elements) != 2 {
+		t.Fatalf("expected an array of two elements, got %#v", expr)
+	}
+	for i, name := range []string{"a", "b"} {
+		if param, ok := arr.Elements[i].(*ast.AstNamedParameter); !ok || param.Name != name {
+			t.Errorf("element %d should be the parameter :%s, got %#v", i, name, arr.Elements[i])
+		}
+	}
+}
+
+// A parenthesized form of the tree, to check how an expression was grouped
+func sexpr(expr ast.IAstExpression) string {
+	var list = func(exprs []ast.IAstExpression) string {
+		var res []string
+		for _, e := range exprs {
+			res = append(res, sexpr(e))
+		}
+		return strings.Join(res, " ")
+	}
+	var not = func(not bool) string {
+		if not {
+			return "not "
+		}
+		return ""
+	}
+
+	switch e := expr.(type) {
+	case nil:
+		return "_"
+	case *ast.AstIdentifier:
+		return e.Name
+	case *ast.AstNumber:
+		return e.Value
+	case *ast.AstString:
+		return fmt.Sprintf("%q", e.Value)
+	case *ast.AstBoolean:
+		return fmt.Sprint(e.Value)
+	case *ast.AstNull:
+		return "null"
+	case *ast.AstStar:
+		return "*"
+	case *ast.AstNamedParameter:
+		return ":" + e.Name
+	case *ast.AstPositionalParameter:
+		return fmt.Sprintf("$%d", e.Index)
+	case *ast.AstBinaryExpression:
+		return "(" + e.Operator + " " + sexpr(e.Left) + " " + sexpr(e.Right) + ")"
+	case *ast.AstUnaryExpression:
+		return "(" + e.Operator + " " + sexpr(e.Operand) + ")"
+	case *ast.AstMemberExpression:
+		return "(. " + sexpr(e.Expression) + " " + e.Member + ")"
+	case *ast.AstCastExpression:
+		var typ = e.Type.Name
+		if e.Type.Schema != "" {
+			typ = e.Type.Schema + "." + typ
+		}
+		if len(e.Type.Modifiers) > 0 {
+			typ += "(" + list(e.Type.Modifiers) + ")"
+		}
+		return "(:: " + sexpr(e.Expression) + " " + typ + strings.Repeat("[]", e.Type.ArrayDimensions) + ")"
+	case *ast.AstIsExpression:
+		return "(is " + not(e.Not) + e.Test + " " + sexpr(e.Expression) + ")"
+	case *ast.AstDistinctExpression:
+		return "(is " + not(e.Not) + "distinct " + sexpr(e.Left) + " " + sexpr(e.Right) + ")"
+	case *ast.AstBetweenExpression:
+		return "(" + not(e.Not) + "between " + sexpr(e.Expression) + " " + sexpr(e.Low) + " " + sexpr(e.High) + ")"
+	case *ast.AstInExpression:
+		return "(" + not(e.Not) + "in " + sexpr(e.Expression) + " " + list(e.List) + ")"
+	case *ast.AstLikeExpression:
+		return "(" + not(e.Not) + e.Operator + " " + sexpr(e.Expression) + " " + sexpr(e.Pattern) + ")"
+	case *ast.AstSubscriptExpression:
+		if e.IsSlice {
+			return "([:] " + sexpr(e.Expression) + " " + sexpr(e.Index) + " " + sexpr(e.Upper) + ")"
+		}
+		return "([] " + sexpr(e.Expression) + " " + sexpr(e.Index) + ")"
+	case *ast.AstFunctionCall:
+		var res = "(call " + sexpr(e.Function)
+		if e.Star {
+			res += " *"
+		}
+		if e.Distinct {
+			res += " distinct"
+		}
+		if len(e.Arguments) > 0 {
+			res += " " + list(e.Arguments)
+		}
+		return res + ")"
+	case *ast.AstNamedArgument:
+		return "(=> " + e.Name + " " + sexpr(e.Value) + ")"
+	case *ast.AstCaseExpression:
+		var res = "(case"
+		if e.Subject != nil {
+			res += " " + sexpr(e.Subject)
+		}
+		for _, w := range e.Whens {
+			res += " (when " + sexpr(w.Condition) + " " + sexpr(w.Result) + ")"
+		}
+		if e.Else != nil {
+			res += " (else " + sexpr(e.Else) + ")"
+		}
+		return res + ")"
+	case *ast.AstArrayExpression:
+		return "(array " + list(e.Elements) + ")"
+	case *ast.AstRowExpression:
+		return "(row " + list(e.Elements) + ")"
+	}
+	return fmt.Sprintf("%T", expr)
+}
+
+func TestParseExpression(t *testing.T) {
+	var tests = []struct {
+		source   string
+		expected string
+	}{
+		// Precedence and associativity
+		{`a + b * c`, `(+ a (* b c))`},
+		{`a - b - c`, `(- (- a b) c)`},
+		{`a / b * c`, `(* (/ a b) c)`},
+		{`2 ^ 3 ^ 2`, `(^ (^ 2 3) 2)`},
+		{`-2 ^ 2`, `(^ (- 2) 2)`},
+		{`- a.b`, `(- (. a b))`},
+		{`a || b = c`, `(= (|| a b) c)`},
+		{`a + b || c`, `(|| (+ a b) c)`},
+		{`not a = b`, `(not (= a b))`},
+		{`not a and b`, `(and (not a) b)`},
+		{`a or b and c`, `(or a (and b c))`},
+		{`a = 1 or b = 2 and c`, `(or (= a 1) (and (= b 2) c))`},
+		{`a < b + 1`, `(< a (+ b 1))`},
+		{`(a = b) = c`, `(= (= a b) c)`},
+
+		// Keywords
+		{`a between 1 and 2 and b`, `(and (between a 1 2) b)`},
+		{`a not between 1 and 2`, `(not between a 1 2)`},
+		{`a between 1 + 1 and 2 * 3`, `(between a (+ 1 1) (* 2 3))`},
+		{`a in (1, 2) or b not in (3)`, `(or (in a 1 2) (not in b 3))`},
+		{`a like 'x%' and b not ilike 'y'`, `(and (like a "x%") (not ilike b "y"))`},
+		{`a is null`, `(is null a)`},
+		{`a = b is not true`, `(is not true (= a b))`},
+		{`a isnull or b notnull`, `(or (is null a) (is not null b))`},
+		{`a is distinct from b + 1`, `(is distinct a (+ b 1))`},
+		{`a is not distinct from b and c`, `(and (is not distinct a b) c)`},
+		{`A IS NOT DISTINCT FROM B`, `(is not distinct a b)`},
+
+		// Casts
+		{`a::int`, `(:: a int)`},
+		{`a::double precision`, `(:: a double precision)`},
+		{`a::text[]`, `(:: a text[])`},
+		{`a::int[][]`, `(:: a int[][])`},
+		{`a::varchar(10)[]`, `(:: a varchar(10)[])`},
+		{`a::character varying(3)`, `(:: a character varying(3))`},
+		{`a::timestamp with time zone`, `(:: a timestamp with time zone)`},
+		{`a::public.my_type`, `(:: a public.my_type)`},
+		{`-a::int`, `(- (:: a int))`},
+		{`a + b::int`, `(+ a (:: b int))`},
+		{`a::text::int`, `(:: (:: a text) int)`},
+		{`cast(a as double precision)`, `(:: a double precision)`},
+
+		// Calls, subscripts and constructors
+		{`count(*)`, `(call count *)`},
+		{`count(distinct a)`, `(call count distinct a)`},
+		{`f()`, `(call f)`},
+		{`f(a, b + 1)`, `(call f a (+ b 1))`},
+		{`f(a, name => b, other => 1 + 2)`, `(call f a (=> name b) (=> other (+ 1 2)))`},
+		{`api.f(a)`, `(call (. api f) a)`},
+		{`a[1]`, `([] a 1)`},
+		{`a[1:2]`, `([:] a 1 2)`},
+		{`a[1:]`, `([:] a 1 _)`},
+		{`f(a)[1]`, `([] (call f a) 1)`},
+		{`array[1, 2]`, `(array 1 2)`},
+		{`(a, b)`, `(row a b)`},
+		{`case when a then 1 when b then 2 else 3 end`, `(case (when a 1) (when b 2) (else 3))`},
+		{`case a when 1 then 'x' end`, `(case a (when 1 "x"))`},
+		{`case when a = 1 then b + 1 end * 2`, `(* (case (when (= a 1) (+ b 1))) 2)`},
+
+		// Literals and parameters
+		{`true and null`, `(and true null)`},
+		{`'it''s'`, `"it's"`},
+		{`$1 + :n`, `(+ $1 :n)`},
+		{`a @> b and c`, `(and (@> a b) c)`},
+	}
+
+	for _, test := range tests {
+		expr, err := ParseExpression([]byte(test.source))
+		if err != nil {
+			t.Errorf("%s: %s", test.source, err)
+			continue
+		}
+		if got := sexpr(expr); got != test.expected {
+			t.Errorf("%s: got %s, expected %s", test.source, got, test.expected)
+		}
+	}
+}
+
+func TestParseExpressionErrors(t *testing.T) {
+	var tests = []struct {
+		source string
+		err    string
+	}{
+		{`a = b = c`, "comparisons can't be chained"},
+		{`a < b <> c`, "comparisons can't be chained"},
+		{`a = b >= c + 1`, "comparisons can't be chained"},
+		{`a +`, "expected an expression"},
+		{`a -`, "expected an expression"},
+		{`f(a`, "expected a closing parenthesis"},
+		{`a[]`, "expected an expression"},
+		{`a is b`, "expected NULL, TRUE, FALSE, UNKNOWN or DISTINCT FROM"},
+		{`a::`, "expected a type name"},
+		{`a::double`, "expected PRECISION"},
+		{`case when a then 1`, "expected END"},
+		{`()`, "empty parenthesis"},
+		{`a b`, "unexpected token after the expression"},
+	}
+
+	for _, test := range tests {
+		_, err := ParseExpression([]byte(test.source))
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: expected %q, got %v", test.source, test.err, err)
+		}
+	}
+}
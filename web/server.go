@@ -14,11 +14,34 @@
 
 package web
 
+import "github.com/ceymard/pgrel/pg"
+
+// A source of catalog, either a *pg.DbInfos or a *pg.Watcher that swaps it when the schema changes.
+// A request must call Infos once and keep using the result until it is done, so that it does not
+// see two different catalogs if a reload happens in the meantime.
 type IDb interface {
-	Infos() error
+	Infos() *pg.DbInfos
 }
 
 type Server struct {
 	// /rel -> some postgres
 	endpoints map[string]IDb
 }
+
+func NewServer() *Server {
+	return &Server{
+		endpoints: make(map[string]IDb),
+	}
+}
+
+func (s *Server) Mount(path string, db IDb) {
+	s.endpoints[path] = db
+}
+
+// The catalog to use for a request on an endpoint, or nil if there is no such endpoint
+func (s *Server) Infos(path string) *pg.DbInfos {
+	if db, ok := s.endpoints[path]; ok {
+		return db.Infos()
+	}
+	return nil
+}